@@ -0,0 +1,277 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// DefaultIgnoreHeaders change on every response, so are never compared
+var DefaultIgnoreHeaders = []string{
+	"Date",
+	"Content-Length",
+	"Transfer-Encoding",
+	"Connection",
+}
+
+// Difference is a single way in which the target's response differs from the fixture
+type Difference struct {
+	Location string `json:"location"`
+	Name     string `json:"name,omitempty"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (d Difference) String() string {
+	location := d.Location
+	if d.Name != "" {
+		location += " " + d.Name
+	}
+	return fmt.Sprintf("%v: expected %v, but was %v", location, d.Expected, d.Actual)
+}
+
+// ignoreRule is a parsed --ignore-field JSONPath. Recursive rules (i.e. $..lastModified) ignore the field at any depth.
+type ignoreRule struct {
+	keys      []string
+	recursive bool
+}
+
+func parseIgnoreRules(fields []string) []ignoreRule {
+	rules := make([]ignoreRule, 0, len(fields))
+	for _, f := range fields {
+		if strings.HasPrefix(f, "$..") {
+			rules = append(rules, ignoreRule{keys: v2.SplitJSONPath("$." + f[3:]), recursive: true})
+			continue
+		}
+		rules = append(rules, ignoreRule{keys: v2.SplitJSONPath(f)})
+	}
+	return rules
+}
+
+// ignores returns true if the rule matches the location, or any of its parents. A * matches any object key or array index.
+func (rule ignoreRule) ignores(keys []string) bool {
+	if rule.recursive {
+		for i := range keys {
+			if rule.matches(keys[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return rule.matches(keys)
+}
+
+func (rule ignoreRule) matches(keys []string) bool {
+	if len(rule.keys) == 0 || len(keys) < len(rule.keys) {
+		return false
+	}
+
+	for i, k := range rule.keys {
+		if k != "*" && k != keys[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// compareStatus compares the status code, treating an unset status as 200
+func compareStatus(expected v2.Response, actual int) []Difference {
+	status := expected.Status
+	if status == 0 {
+		if expected.Template {
+			return nil // the status is rendered from the request, so cannot be known in advance
+		}
+		status = http.StatusOK
+	}
+
+	if status != actual {
+		return []Difference{{Location: "status", Expected: strconv.Itoa(status), Actual: strconv.Itoa(actual)}}
+	}
+	return nil
+}
+
+// compareHeaders compares every header configured in the fixture response, other than those ignored. Content types are compared without their parameters.
+func compareHeaders(expected map[string]string, actual http.Header, ignore []string) []Difference {
+	differences := make([]Difference, 0)
+	for k, v := range expected {
+		name := http.CanonicalHeaderKey(k)
+		if ignoredHeader(name, ignore) || strings.Contains(v, "{{") {
+			continue
+		}
+
+		got := actual.Get(name)
+		switch {
+		case got == "":
+			differences = append(differences, Difference{Location: "headers", Name: name, Expected: quote(v), Actual: "missing"})
+		case name == "Content-Type":
+			if mediaType(v) != mediaType(got) {
+				differences = append(differences, Difference{Location: "headers", Name: name, Expected: quote(v), Actual: quote(got)})
+			}
+		case v != got:
+			differences = append(differences, Difference{Location: "headers", Name: name, Expected: quote(v), Actual: quote(got)})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool {
+		return differences[i].Name < differences[j].Name
+	})
+	return differences
+}
+
+func ignoredHeader(name string, ignore []string) bool {
+	for _, h := range ignore {
+		if strings.EqualFold(h, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareBody compares the structure of a JSON (or YAML) response body to the fixture body, reporting fields which are missing, unexpected, or have a different type. Values are not compared, and only the first element of each array is compared. Text bodies are not compared.
+func compareBody(expected interface{}, contentType string, actual []byte, rules []ignoreRule) []Difference {
+	if text, ok := expected.(string); ok {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+			return nil
+		}
+		expected = parsed
+	}
+
+	if expected == nil {
+		return nil
+	}
+
+	if t := mediaType(contentType); strings.HasSuffix(t, "yaml") || strings.HasSuffix(t, "yml") {
+		if converted, err := yaml.YAMLToJSON(actual); err == nil {
+			actual = converted
+		}
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(actual))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return []Difference{{Location: "body", Name: "$", Expected: kind(expected), Actual: "a body which is not JSON"}}
+	}
+
+	differences := make([]Difference, 0)
+	compareStructure([]string{}, expected, doc, rules, &differences)
+	return differences
+}
+
+func compareStructure(keys []string, expected interface{}, actual interface{}, rules []ignoreRule, differences *[]Difference) {
+	for _, rule := range rules {
+		if rule.ignores(keys) {
+			return
+		}
+	}
+
+	if kind(expected) != kind(actual) {
+		*differences = append(*differences, Difference{Location: "body", Name: jsonPath(keys), Expected: kind(expected), Actual: kind(actual)})
+		return
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a := actual.(map[string]interface{})
+		for _, k := range union(e, a) {
+			child := append(append([]string{}, keys...), k)
+			ev, inExpected := e[k]
+			av, inActual := a[k]
+
+			switch {
+			case !inActual:
+				appendUnlessIgnored(child, kind(ev), "missing", rules, differences)
+			case !inExpected:
+				appendUnlessIgnored(child, "missing", kind(av), rules, differences)
+			default:
+				compareStructure(child, ev, av, rules, differences)
+			}
+		}
+	case []interface{}:
+		a := actual.([]interface{})
+		if len(e) > 0 && len(a) > 0 {
+			compareStructure(append(append([]string{}, keys...), "0"), e[0], a[0], rules, differences)
+		}
+	}
+}
+
+func appendUnlessIgnored(keys []string, expected string, actual string, rules []ignoreRule, differences *[]Difference) {
+	for _, rule := range rules {
+		if rule.ignores(keys) {
+			return
+		}
+	}
+	*differences = append(*differences, Difference{Location: "body", Name: jsonPath(keys), Expected: expected, Actual: actual})
+}
+
+func union(a map[string]interface{}, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// kind describes the JSON type of the value
+func kind(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64, int:
+		return "number"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonPath formats the keys as a JSONPath, i.e. $.items[0].title
+func jsonPath(keys []string) string {
+	path := "$"
+	for _, k := range keys {
+		if _, err := strconv.Atoi(k); err == nil {
+			path += "[" + k + "]"
+			continue
+		}
+
+		if strings.ContainsAny(k, ".[]' ") {
+			path += "['" + k + "']"
+			continue
+		}
+		path += "." + k
+	}
+	return path
+}
+
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return t
+}
+
+func quote(v string) string {
+	return "'" + v + "'"
+}
@@ -0,0 +1,83 @@
+package diff
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareStatus(t *testing.T) {
+	assert.Empty(t, compareStatus(v2.Response{}, 200))
+	assert.Empty(t, compareStatus(v2.Response{Template: true}, 418))
+	assert.Equal(t, []Difference{{Location: "status", Expected: "200", Actual: "404"}}, compareStatus(v2.Response{Status: 200}, 404))
+}
+
+func TestCompareHeaders(t *testing.T) {
+	expected := map[string]string{
+		"content-type":   "application/json",
+		"Cache-Control":  "max-age=10",
+		"X-Request-Id":   "{{ .Request.Header \"X-Request-Id\" }}",
+		"Etag":           "abc",
+		"Last-Modified":  "yesterday",
+		"Content-Length": "10",
+	}
+
+	actual := http.Header{}
+	actual.Set("Content-Type", "application/json; charset=utf-8")
+	actual.Set("Cache-Control", "no-cache")
+	actual.Set("Last-Modified", "today")
+
+	differences := compareHeaders(expected, actual, append(DefaultIgnoreHeaders, "Last-Modified"))
+	assert.Equal(t, []Difference{
+		{Location: "headers", Name: "Cache-Control", Expected: "'max-age=10'", Actual: "'no-cache'"},
+		{Location: "headers", Name: "Etag", Expected: "'abc'", Actual: "missing"},
+	}, differences)
+}
+
+func TestCompareBody__Structure(t *testing.T) {
+	expected := map[string]interface{}{
+		"title":     "Example",
+		"published": "2018-01-01T00:00:00Z",
+		"count":     float64(2),
+		"author":    map[string]interface{}{"name": "Someone"},
+		"items":     []interface{}{map[string]interface{}{"id": "1"}},
+	}
+
+	actual := `{"title":1,"count":5,"author":null,"items":[{"id":"2","extra":true}],"new":"field"}`
+
+	differences := compareBody(expected, "application/json", []byte(actual), nil)
+	assert.Equal(t, []Difference{
+		{Location: "body", Name: "$.author", Expected: "object", Actual: "null"},
+		{Location: "body", Name: "$.items[0].extra", Expected: "missing", Actual: "boolean"},
+		{Location: "body", Name: "$.new", Expected: "missing", Actual: "string"},
+		{Location: "body", Name: "$.published", Expected: "string", Actual: "missing"},
+		{Location: "body", Name: "$.title", Expected: "string", Actual: "number"},
+	}, differences)
+}
+
+func TestCompareBody__Ignore(t *testing.T) {
+	expected := map[string]interface{}{
+		"published": "2018-01-01T00:00:00Z",
+		"meta":      map[string]interface{}{"lastModified": "today"},
+		"items":     []interface{}{map[string]interface{}{"id": "1", "lastModified": "today"}},
+	}
+
+	actual := `{"meta":{"lastModified":1},"items":[{"id":1,"lastModified":null}]}`
+
+	rules := parseIgnoreRules([]string{"$.published", "$.items[*].id", "$..lastModified"})
+	assert.Empty(t, compareBody(expected, "application/json", []byte(actual), rules))
+}
+
+func TestCompareBody__Text(t *testing.T) {
+	assert.Empty(t, compareBody("OK", "text/plain", []byte("Healthy"), nil))
+	assert.Empty(t, compareBody(nil, "application/json", []byte(`{"a":1}`), nil))
+	assert.Equal(t, []Difference{{Location: "body", Name: "$", Expected: "object", Actual: "a body which is not JSON"}},
+		compareBody(`{"a":1}`, "text/plain", []byte("Healthy"), nil))
+}
+
+func TestCompareBody__YAML(t *testing.T) {
+	expected := map[string]interface{}{"title": "Example"}
+	assert.Empty(t, compareBody(expected, "application/x-yaml", []byte("title: Another\n"), nil))
+}
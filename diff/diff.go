@@ -0,0 +1,162 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// DefaultTimeout is used for every replayed request, unless another client is provided
+const DefaultTimeout = 30 * time.Second
+
+// Options configures how fixtures are replayed, and which differences are ignored
+type Options struct {
+	// IgnoreHeaders are never compared. These are used in addition to the DefaultIgnoreHeaders.
+	IgnoreHeaders []string
+	// IgnoreFields are JSONPaths of response body fields which are expected to differ, i.e. $.publishedDate, $.items[*].id or $..lastModified
+	IgnoreFields []string
+	// Client sends the replayed requests. Redirects should not be followed, so they can be compared with the fixture.
+	Client *http.Client
+}
+
+// Result is the outcome of replaying a single fixture (or discriminator) against the target
+type Result struct {
+	Fixture       string       `json:"fixture"`
+	Method        string       `json:"method"`
+	Discriminator *int         `json:"discriminator,omitempty"`
+	URL           string       `json:"url,omitempty"`
+	Skipped       string       `json:"skipped,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	Differences   []Difference `json:"differences,omitempty"`
+}
+
+// Drifted returns true if the target's response differs from the fixture, or the request could not be made
+func (r Result) Drifted() bool {
+	return r.Error != "" || len(r.Differences) > 0
+}
+
+// Drifted returns true if any of the results have drifted
+func Drifted(results []Result) bool {
+	for _, r := range results {
+		if r.Drifted() {
+			return true
+		}
+	}
+	return false
+}
+
+// Run replays a request for every fixture against the target, and compares the responses with the fixtures
+func Run(target *url.URL, fixtures v2.Fixtures, opts Options) []Result {
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: DefaultTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	}
+
+	ignoreHeaders := append(append([]string{}, DefaultIgnoreHeaders...), opts.IgnoreHeaders...)
+	rules := parseIgnoreRules(opts.IgnoreFields)
+
+	results := make([]Result, 0)
+	for _, r := range Replays(fixtures) {
+		result := Result{Fixture: r.Fixture, Method: r.Method, Discriminator: r.Discriminator, Skipped: r.Skipped}
+		if r.Skipped != "" {
+			results = append(results, result)
+			continue
+		}
+
+		u := *target
+		u.Path = strings.TrimSuffix(target.Path, "/") + r.Path
+		u.RawQuery = r.Query.Encode()
+		result.URL = u.String()
+
+		status, headers, body, err := send(client, r, u.String())
+		if err != nil {
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.Differences = compareStatus(r.Expected, status)
+		result.Differences = append(result.Differences, compareHeaders(r.Expected.Headers, headers, ignoreHeaders)...)
+		result.Differences = append(result.Differences, compareBody(r.Expected.Body, headers.Get("Content-Type"), body, rules)...)
+		results = append(results, result)
+	}
+	return results
+}
+
+func send(client *http.Client, r Replay, u string) (int, http.Header, []byte, error) {
+	req, err := http.NewRequest(r.Method, u, bytes.NewReader(r.Body))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	for k, v := range r.Headers {
+		req.Header[k] = v
+	}
+
+	if host := r.Headers.Get("Host"); host != "" {
+		req.Host = host
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+// Write reports the outcome of every replayed fixture, followed by a summary
+func Write(w io.Writer, results []Result) error {
+	ok, drifted, skipped := 0, 0, 0
+	for _, r := range results {
+		name := r.Method + " " + r.Fixture
+		if r.Discriminator != nil {
+			name += fmt.Sprintf(" (discriminator %v)", *r.Discriminator)
+		}
+
+		var err error
+		switch {
+		case r.Skipped != "":
+			skipped++
+			_, err = fmt.Fprintf(w, "SKIP   %v: %v\n", name, r.Skipped)
+		case r.Error != "":
+			drifted++
+			_, err = fmt.Fprintf(w, "ERROR  %v: %v\n", name, r.Error)
+		case len(r.Differences) > 0:
+			drifted++
+			_, err = fmt.Fprintf(w, "DRIFT  %v\n", name)
+			for _, d := range r.Differences {
+				if err == nil {
+					_, err = fmt.Fprintf(w, "       %v\n", d)
+				}
+			}
+		default:
+			ok++
+			_, err = fmt.Fprintf(w, "OK     %v\n", name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "\n%v fixtures replayed: %v ok, %v drifted, %v skipped\n", len(results), ok, drifted, skipped)
+	return err
+}
@@ -0,0 +1,134 @@
+package diff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffTestYAML = `
+/content/{uuid}:
+  get:
+    - when:
+        pathParams:
+          uuid: "1234"
+        headers:
+          X-Policy: INTERNAL
+      response:
+        status: 200
+        headers:
+          Content-Type: application/json
+        body:
+          uuid: "1234"
+          title: Example
+          publishedDate: "2018-01-01T00:00:00Z"
+    - when:
+        pathParams:
+          uuid: "5678"
+      response:
+        status: 404
+/content:
+  post:
+    - when:
+        body:
+          json:
+            $.title: Example
+      response:
+        status: 201
+/__health:
+  get:
+    fault: emptyResponse
+`
+
+func diffTestServer(t *testing.T, requests *[]*http.Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		*requests = append(*requests, r)
+
+		switch {
+		case r.URL.Path == "/api/content/1234" && r.Header.Get("X-Policy") == "INTERNAL":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"uuid":"1234","title":"Another","publishedDate":1514764800}`))
+		case r.URL.Path == "/api/content" && string(body) == `{"title":"Example"}`:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestRun(t *testing.T) {
+	requests := make([]*http.Request, 0)
+	server := diffTestServer(t, &requests)
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/api/")
+	require.NoError(t, err)
+
+	results := Run(target, loadFixtures(t, diffTestYAML), Options{})
+	require.Len(t, results, 4)
+
+	assert.Equal(t, "/__health", results[0].Fixture)
+	assert.Equal(t, "the response simulates a fault", results[0].Skipped)
+
+	assert.Equal(t, "POST", results[1].Method)
+	assert.False(t, results[1].Drifted())
+
+	assert.Equal(t, server.URL+"/api/content/1234", results[2].URL)
+	assert.Equal(t, []Difference{{Location: "body", Name: "$.publishedDate", Expected: "string", Actual: "number"}}, results[2].Differences)
+	assert.True(t, results[2].Drifted())
+
+	assert.False(t, results[3].Drifted())
+	assert.True(t, Drifted(results))
+	assert.Len(t, requests, 3)
+}
+
+func TestRun__Ignore(t *testing.T) {
+	requests := make([]*http.Request, 0)
+	server := diffTestServer(t, &requests)
+	defer server.Close()
+
+	target, err := url.Parse(server.URL + "/api")
+	require.NoError(t, err)
+
+	results := Run(target, loadFixtures(t, diffTestYAML), Options{IgnoreFields: []string{"$.publishedDate"}})
+	assert.False(t, Drifted(results))
+}
+
+func TestRun__Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	server.Close()
+
+	results := Run(target, loadFixtures(t, diffTestYAML), Options{})
+	assert.NotEmpty(t, results[1].Error)
+	assert.True(t, Drifted(results))
+}
+
+func TestWrite(t *testing.T) {
+	zero := 0
+	results := []Result{
+		{Fixture: "/__health", Method: "GET", Skipped: "the response simulates a fault"},
+		{Fixture: "/content/{uuid}", Method: "GET", Discriminator: &zero, Differences: []Difference{{Location: "status", Expected: "200", Actual: "404"}}},
+		{Fixture: "/content", Method: "POST"},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, results))
+	assert.Equal(t, `SKIP   GET /__health: the response simulates a fault
+DRIFT  GET /content/{uuid} (discriminator 0)
+       status: expected 200, but was 404
+OK     POST /content
+
+3 fixtures replayed: 1 ok, 1 drifted, 1 skipped
+`, buf.String())
+}
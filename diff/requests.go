@@ -0,0 +1,329 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// Replay is a request generated from a fixture, and the response the fixture expects in return
+type Replay struct {
+	Fixture       string
+	Method        string
+	Discriminator *int
+
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Body    []byte
+
+	Expected v2.Response
+
+	// Skipped explains why no request could be generated for the fixture
+	Skipped string
+}
+
+// Replays generates a request for every fixture, and for every discriminator of each fixture, which satisfies the discriminator's path parameters, headers, query parameters and body
+func Replays(fixtures v2.Fixtures) []Replay {
+	paths := make([]string, 0, len(fixtures))
+	for p := range fixtures {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	replays := make([]Replay, 0)
+	for _, p := range paths {
+		for _, method := range sortedMethods(fixtures[p]) {
+			res := fixtures[p][method]
+			if len(res.Discriminators) == 0 {
+				expected := res.Response
+				if !res.Sequence.IsEmpty() {
+					expected = res.Sequence.Responses[0]
+				}
+				replays = append(replays, replay(p, method, nil, v2.NewRequestDiscriminator(), expected))
+				continue
+			}
+
+			for i, d := range res.Discriminators {
+				index := i
+				expected := d.Response
+				if !d.Sequence.IsEmpty() {
+					expected = d.Sequence.Responses[0]
+				}
+
+				r := replay(p, method, &index, d.When, expected)
+				if r.Skipped == "" && d.RequiredState != "" && d.RequiredState != v2.StartedState {
+					r.Skipped = fmt.Sprintf("requires the scenario '%v' to be in the state '%v'", d.ScenarioName(), d.RequiredState)
+				}
+				replays = append(replays, r)
+			}
+		}
+	}
+	return replays
+}
+
+// sortedMethods orders the standard methods first, followed by any others alphabetically
+func sortedMethods(p v2.Path) []string {
+	sorted := make([]string, 0, len(p))
+	for _, m := range v2.Methods {
+		if _, ok := p[m]; ok {
+			sorted = append(sorted, m)
+		}
+	}
+
+	others := make([]string, 0)
+	for m := range p {
+		if !v2.Contains(m, v2.Methods...) {
+			others = append(others, m)
+		}
+	}
+	sort.Strings(others)
+	return append(sorted, others...)
+}
+
+func replay(fixture string, method string, index *int, when v2.RequestDiscriminator, expected v2.Response) Replay {
+	r := Replay{
+		Fixture:       fixture,
+		Method:        strings.ToUpper(method),
+		Discriminator: index,
+		Query:         make(url.Values),
+		Headers:       make(http.Header),
+		Expected:      expected,
+	}
+
+	switch {
+	case expected.Fault != nil:
+		r.Skipped = "the response simulates a fault"
+		return r
+	case expected.Proxy != nil:
+		r.Skipped = "the response is proxied"
+		return r
+	case !when.ClientCert.IsEmpty():
+		r.Skipped = "the discriminator requires a client certificate"
+		return r
+	case !when.Protocol.IsEmpty():
+		r.Skipped = "the discriminator requires a particular protocol"
+		return r
+	}
+
+	params, err := values("pathParams", when.PathParams.Values, when.PathParams.TemplatedValues, when.PathParams.Expressions)
+	if err != nil {
+		r.Skipped = err.Error()
+		return r
+	}
+
+	path, missing := v2.ParseRoute(fixture).Expand(params)
+	if missing != "" {
+		r.Skipped = fmt.Sprintf("no value for the path parameter '%v', please add a pathParams discriminator", missing)
+		return r
+	}
+	r.Path = path
+
+	headers, err := values("headers", when.Headers.MIMEHeader, when.Headers.TemplatedValues, when.Headers.Expressions)
+	if err != nil {
+		r.Skipped = err.Error()
+		return r
+	}
+	for k, v := range headers {
+		r.Headers.Set(k, v)
+	}
+
+	query, err := values("queryParams", when.QueryParams.Values, when.QueryParams.TemplatedValues, when.QueryParams.Expressions)
+	if err != nil {
+		r.Skipped = err.Error()
+		return r
+	}
+	for k, v := range query {
+		r.Query.Set(k, v)
+	}
+
+	body, contentType, err := requestBody(when.Body)
+	if err != nil {
+		r.Skipped = err.Error()
+		return r
+	}
+
+	r.Body = body
+	if contentType != "" && r.Headers.Get("Content-Type") == "" {
+		r.Headers.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// values picks a value for every exact and templated value. Templated values which are satisfied by an empty value (i.e. ${missing}) are left out.
+func values(location string, exact map[string][]string, templated v2.TemplatedValues, expressions v2.Expressions) (map[string]string, error) {
+	picked := make(map[string]string)
+	for k, v := range exact {
+		if len(v) > 0 {
+			picked[k] = v[0]
+		}
+	}
+
+	for k, fn := range templated {
+		v, ok := sample(fn, expressions[k])
+		if !ok {
+			return nil, fmt.Errorf("unable to generate a value for the %v '%v' which satisfies '%v'", location, k, expressions.Describe(k))
+		}
+
+		if v != "" {
+			picked[k] = v
+		}
+	}
+	return picked, nil
+}
+
+// sample finds a value which satisfies the templated function, trying values suggested by its expression first
+func sample(fn v2.TemplatedFunction, expr string) (string, bool) {
+	candidates := append(suggest(expr), "ersatz", "1", "0", "true", "")
+	for _, c := range candidates {
+		if fn(c) {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+// suggest returns likely values for the ${...} expression, i.e. Bearer ersatz for ${prefix:Bearer }
+func suggest(expr string) []string {
+	if !v2.IsTemplated(expr) {
+		return nil
+	}
+
+	name, arg := expr[2:len(expr)-1], ""
+	if i := strings.Index(name, ":"); i != -1 {
+		name, arg = name[:i], name[i+1:]
+	}
+
+	switch name {
+	case "prefix":
+		return []string{arg + "ersatz"}
+	case "contains", "equalsIgnoreCase":
+		return []string{arg}
+	case "oneOf":
+		options := strings.Split(arg, ",")
+		for i, o := range options {
+			options[i] = strings.TrimSpace(o)
+		}
+		return options
+	case "gt", "lt":
+		n, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return nil
+		}
+
+		if name == "gt" {
+			n = n + 1
+		} else {
+			n = n - 1
+		}
+		return []string{strconv.FormatFloat(n, 'f', -1, 64)}
+	}
+	return nil
+}
+
+// requestBody generates a body which satisfies the body discriminator, and the content type it should be sent with
+func requestBody(b v2.Body) ([]byte, string, error) {
+	if b.IsEmpty() {
+		return nil, "", nil
+	}
+
+	if len(b.JSON.Values) > 0 || len(b.JSON.TemplatedValues) > 0 {
+		fields, err := values("body.json", b.JSON.Values, b.JSON.TemplatedValues, b.JSON.Expressions)
+		if err != nil {
+			return nil, "", err
+		}
+
+		var doc interface{} = make(map[string]interface{})
+		for path, v := range fields {
+			doc = set(doc, v2.SplitJSONPath(path), scalar(v))
+		}
+
+		body, err := json.Marshal(doc)
+		return body, "application/json", err
+	}
+
+	if len(b.Form.Values) > 0 || len(b.Form.TemplatedValues) > 0 {
+		fields, err := values("body.form", b.Form.Values, b.Form.TemplatedValues, b.Form.Expressions)
+		if err != nil {
+			return nil, "", err
+		}
+
+		form := make(url.Values)
+		for k, v := range fields {
+			form.Set(k, v)
+		}
+		return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	if b.Text.Regex != nil {
+		return nil, "", fmt.Errorf("unable to generate a body which satisfies the regex '%v'", b.Text.Regex.String())
+	}
+
+	text := b.Text.Equals
+	if text == "" {
+		text = b.Text.Contains
+	}
+
+	templated, err := values("body.text", nil, b.Text.TemplatedValues, b.Text.Expressions)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, v := range templated {
+		if text == "" {
+			text = v
+		}
+	}
+	return []byte(text), "text/plain", nil
+}
+
+// set places the value at the location given by the keys, creating objects (or arrays, for numeric keys) along the way
+func set(node interface{}, keys []string, value interface{}) interface{} {
+	if len(keys) == 0 {
+		return value
+	}
+
+	if i, err := strconv.Atoi(keys[0]); err == nil && i >= 0 {
+		arr, _ := node.([]interface{})
+		for len(arr) <= i {
+			arr = append(arr, nil)
+		}
+		arr[i] = set(arr[i], keys[1:], value)
+		return arr
+	}
+
+	obj, ok := node.(map[string]interface{})
+	if !ok {
+		obj = make(map[string]interface{})
+	}
+	obj[keys[0]] = set(obj[keys[0]], keys[1:], value)
+	return obj
+}
+
+// scalar converts the value back into the number, boolean or null it was written as, as JSON body discriminators are compared as strings
+func scalar(v string) interface{} {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(v)))
+	decoder.UseNumber()
+
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return v
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return v
+	}
+
+	switch parsed.(type) {
+	case json.Number, bool, nil:
+		return parsed
+	}
+	return v
+}
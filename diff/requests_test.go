@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadFixtures(t *testing.T, yml string) v2.Fixtures {
+	f := make(v2.Fixtures)
+	require.NoError(t, yaml.Unmarshal([]byte(yml), &f))
+	return f
+}
+
+func TestReplays__SimpleResponse(t *testing.T) {
+	replays := Replays(loadFixtures(t, `
+/__health:
+  get:
+    status: 200
+`))
+
+	require.Len(t, replays, 1)
+	assert.Equal(t, "GET", replays[0].Method)
+	assert.Equal(t, "/__health", replays[0].Path)
+	assert.Nil(t, replays[0].Discriminator)
+	assert.Empty(t, replays[0].Skipped)
+	assert.Equal(t, 200, replays[0].Expected.Status)
+}
+
+func TestReplays__Discriminators(t *testing.T) {
+	replays := Replays(loadFixtures(t, `
+/content/{uuid}:
+  get:
+    - when:
+        pathParams:
+          uuid: "1234"
+        headers:
+          Authorization: ${prefix:Bearer }
+          X-Policy: ${oneOf:INTERNAL, EXTERNAL}
+          X-Debug: ${missing}
+        queryParams:
+          limit: ${gt:10}
+      response:
+        status: 200
+    - when:
+        pathParams:
+          uuid: ${exists}
+      response:
+        status: 404
+`))
+
+	require.Len(t, replays, 2)
+
+	assert.Equal(t, 0, *replays[0].Discriminator)
+	assert.Equal(t, "/content/1234", replays[0].Path)
+	assert.Equal(t, "Bearer ersatz", replays[0].Headers.Get("Authorization"))
+	assert.Equal(t, "INTERNAL", replays[0].Headers.Get("X-Policy"))
+	assert.NotContains(t, replays[0].Headers, "X-Debug")
+	assert.Equal(t, "11", replays[0].Query.Get("limit"))
+
+	assert.Equal(t, 1, *replays[1].Discriminator)
+	assert.Equal(t, "/content/ersatz", replays[1].Path)
+	assert.Equal(t, 404, replays[1].Expected.Status)
+}
+
+func TestReplays__Body(t *testing.T) {
+	replays := Replays(loadFixtures(t, `
+/content:
+  post:
+    - when:
+        body:
+          json:
+            $.content.type: Article
+            $.content.count: 2
+            $.tags[0]: news
+      response:
+        status: 201
+  put:
+    - when:
+        body:
+          form:
+            grant_type: client_credentials
+      response:
+        status: 200
+`))
+
+	require.Len(t, replays, 2)
+	assert.Equal(t, "POST", replays[0].Method)
+	assert.JSONEq(t, `{"content":{"type":"Article","count":2},"tags":["news"]}`, string(replays[0].Body))
+	assert.Equal(t, "application/json", replays[0].Headers.Get("Content-Type"))
+
+	assert.Equal(t, "PUT", replays[1].Method)
+	assert.Equal(t, "grant_type=client_credentials", string(replays[1].Body))
+	assert.Equal(t, "application/x-www-form-urlencoded", replays[1].Headers.Get("Content-Type"))
+}
+
+func TestReplays__Skipped(t *testing.T) {
+	replays := Replays(loadFixtures(t, `
+/content/{uuid}:
+  get:
+    status: 200
+/faulty:
+  get:
+    fault: connectionReset
+/regex:
+  get:
+    - when:
+        headers:
+          X-Request-Id: ${regex:^tid_[a-z]+$}
+      response:
+        status: 200
+/scenario:
+  get:
+    - requiredState: Published
+      response:
+        status: 200
+/secure:
+  get:
+    - when:
+        clientCert:
+          commonName: content-api
+      response:
+        status: 200
+/streams:
+  get:
+    - when:
+        protocol: h2
+      response:
+        status: 200
+`))
+
+	require.Len(t, replays, 6)
+	assert.Contains(t, replays[0].Skipped, "'uuid'")
+	assert.Equal(t, "the response simulates a fault", replays[1].Skipped)
+	assert.Contains(t, replays[2].Skipped, "${regex:^tid_[a-z]+$}")
+	assert.Equal(t, "requires the scenario 'default' to be in the state 'Published'", replays[3].Skipped)
+	assert.Equal(t, "the discriminator requires a client certificate", replays[4].Skipped)
+	assert.Equal(t, "the discriminator requires a particular protocol", replays[5].Skipped)
+}
+
+func TestReplays__Sequence(t *testing.T) {
+	replays := Replays(loadFixtures(t, `
+/jobs:
+  get:
+    sequence:
+      - status: 202
+      - status: 200
+`))
+
+	require.Len(t, replays, 1)
+	assert.Equal(t, 202, replays[0].Expected.Status)
+}
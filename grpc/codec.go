@@ -0,0 +1,32 @@
+package grpc
+
+import (
+	"encoding/json"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// decode returns the proto3 JSON form of a message, using the JSON names of its fields
+func decode(md *desc.MessageDescriptor, data []byte) ([]byte, error) {
+	m := dynamic.NewMessage(md)
+	if err := m.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	return m.MarshalJSONPB(&jsonpb.Marshaler{})
+}
+
+// encode converts the proto3 JSON form of a message (i.e. the body of a fixture) to the message. Fields may use either their JSON or .proto names.
+func encode(md *desc.MessageDescriptor, v interface{}) ([]byte, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	m := dynamic.NewMessage(md)
+	if err := m.UnmarshalJSONPB(&jsonpb.Unmarshaler{}, j); err != nil {
+		return nil, err
+	}
+	return m.Marshal()
+}
@@ -0,0 +1,140 @@
+package grpc
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeJSON(t *testing.T, j string) interface{} {
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(j), &v))
+	return v
+}
+
+func TestEncode(t *testing.T) {
+	r := loadContent(t)
+
+	msg, err := encode(message(t, r, "content.v1.ListContentRequest"), decodeJSON(t, `{"limit":150}`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x08, 0x96, 0x01}, msg)
+
+	msg, err = encode(message(t, r, "content.v1.GetContentRequest"), decodeJSON(t, `{"uuid":"testing"}`))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x0a, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}, msg)
+}
+
+func TestEncode__RoundTrip(t *testing.T) {
+	r := loadContent(t)
+	content := message(t, r, "content.v1.Content")
+
+	expected := `{
+		"uuid": "c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d",
+		"title": "Markets rally",
+		"views": "9007199254740993",
+		"tags": ["markets", "equities"],
+		"type": "VIDEO",
+		"metadata": {"section": "markets", "author": "FT"},
+		"publishedDate": "2018-08-09T10:00:00.000000500Z",
+		"thumbnail": "AQID",
+		"scores": [1, -2, 3]
+	}`
+
+	msg, err := encode(content, decodeJSON(t, expected))
+	require.NoError(t, err)
+
+	decoded, err := decode(content, msg)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(decoded))
+}
+
+func TestEncode__ProtoNamesAndNumbers(t *testing.T) {
+	r := loadContent(t)
+	content := message(t, r, "content.v1.Content")
+
+	byJSONName, err := encode(content, decodeJSON(t, `{"publishedDate":"1970-01-01T00:00:10Z","type":"VIDEO","views":42}`))
+	require.NoError(t, err)
+
+	byProtoName, err := encode(content, decodeJSON(t, `{"published_date":"1970-01-01T00:00:10Z","type":1,"views":"42"}`))
+	require.NoError(t, err)
+	assert.Equal(t, byJSONName, byProtoName)
+}
+
+func TestEncode__LargeNumbers(t *testing.T) {
+	r := loadContent(t)
+	content := message(t, r, "content.v1.Content")
+
+	f := Response{}
+	require.NoError(t, json.Unmarshal([]byte(`{"body":{"views":9007199254740993}}`), &f))
+
+	msg, err := encode(content, f.Body)
+	require.NoError(t, err)
+
+	decoded, err := decode(content, msg)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"views":"9007199254740993"}`, string(decoded), "64 bit integers should not be rounded")
+}
+
+func TestEncode__Errors(t *testing.T) {
+	r := loadContent(t)
+	content := message(t, r, "content.v1.Content")
+
+	tests := map[string]string{
+		`{"body":"text"}`:               "body",
+		`{"type":"PODCAST"}`:            "PODCAST",
+		`{"views":"many"}`:              "many",
+		`{"thumbnail":"not base64!"}`:   "illegal base64 data",
+		`{"tags":[{"name":"markets"}]}`: "expecting string",
+		`{"publishedDate":"yesterday"}`: "yesterday",
+		`"content"`:                     "expecting start of JSON object",
+	}
+
+	for body, expected := range tests {
+		_, err := encode(content, decodeJSON(t, body))
+		require.Error(t, err, body)
+		assert.Contains(t, err.Error(), expected, body)
+	}
+}
+
+func TestDecode__PackedAndUnpacked(t *testing.T) {
+	r := loadContent(t)
+	content := message(t, r, "content.v1.Content")
+
+	packed := []byte{0x4a, 0x02, 0x01, 0x02}
+	unpacked := []byte{0x48, 0x01, 0x48, 0x02}
+
+	for _, msg := range [][]byte{packed, unpacked} {
+		decoded, err := decode(content, msg)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"scores":[1,2]}`, string(decoded))
+	}
+
+	msg, err := encode(content, decodeJSON(t, `{"scores":[1,2]}`))
+	require.NoError(t, err)
+	assert.Equal(t, packed, msg, "proto3 repeated scalars should be packed")
+}
+
+func TestDecode__IgnoresUnknownFields(t *testing.T) {
+	r := loadContent(t)
+
+	decoded, err := decode(message(t, r, "content.v1.GetContentRequest"), []byte{0x0a, 0x01, 'a', 0x78, 0x01})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"uuid":"a"}`, string(decoded))
+}
+
+func TestDecode__Truncated(t *testing.T) {
+	r := loadContent(t)
+
+	_, err := decode(message(t, r, "content.v1.GetContentRequest"), []byte{0x0a, 0x07, 't', 'e'})
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestDecode__WrongWireType(t *testing.T) {
+	r := loadContent(t)
+
+	_, err := decode(message(t, r, "content.v1.GetContentRequest"), []byte{0x08, 0x01})
+	assert.Error(t, err)
+}
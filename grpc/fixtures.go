@@ -0,0 +1,197 @@
+package grpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// Fixtures declares the gRPC services to serve, and the responses to their methods
+type Fixtures struct {
+	// Protos are .proto files or FileDescriptorSets (i.e. from protoc --descriptor_set_out --include_imports) which declare the services
+	Protos []string `json:"protos"`
+	// ImportPaths are searched for the files imported by the .proto files. By default, imports are relative to each .proto file.
+	ImportPaths []string `json:"importPaths"`
+	// Fixtures maps each method, i.e. content.ContentService/GetContent, to its responses
+	Fixtures map[string]Resource `json:"fixtures"`
+}
+
+// Resource is either a single response to every call of a method, or discriminators which choose the response for each call
+type Resource struct {
+	Discriminators []Discriminator
+	Response       Response
+}
+
+// Discriminator responds to the calls whose metadata (headers) and request message (body) satisfy its when
+type Discriminator struct {
+	When     v2.RequestDiscriminator `json:"when"`
+	Response Response                `json:"response"`
+}
+
+// Response is the status and messages sent in response to a call. Server streaming methods may respond with a list of messages.
+type Response struct {
+	Status   Code              `json:"status"`
+	Message  string            `json:"message"`
+	Headers  map[string]string `json:"headers"`
+	Trailers map[string]string `json:"trailers"`
+	Body     interface{}       `json:"body"`
+	Delay    *v2.Delay         `json:"delay"`
+
+	messages [][]byte
+}
+
+// UnmarshalJSON accepts a single response, or a list of discriminators
+func (r *Resource) UnmarshalJSON(d []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(d), []byte("[")) {
+		return json.Unmarshal(d, &r.Discriminators)
+	}
+	return json.Unmarshal(d, &r.Response)
+}
+
+// UnmarshalJSON keeps the numbers in the body as they are written, so 64 bit integers are not rounded
+func (r *Response) UnmarshalJSON(d []byte) error {
+	type plain Response
+	aux := struct {
+		*plain
+		Body json.RawMessage `json:"body"`
+	}{plain: (*plain)(r)}
+
+	if err := json.Unmarshal(d, &aux); err != nil {
+		return err
+	}
+
+	r.Body = nil
+	if len(aux.Body) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(aux.Body))
+	decoder.UseNumber()
+	return decoder.Decode(&r.Body)
+}
+
+// encode encodes the body of the response as the output messages of the method
+func (r *Response) encode(m *Method) error {
+	r.messages = nil
+	if r.Body == nil {
+		if !m.ServerStreaming && r.Status == OK {
+			r.messages = [][]byte{{}}
+		}
+		return nil
+	}
+
+	bodies := []interface{}{r.Body}
+	if list, ok := r.Body.([]interface{}); ok {
+		if !m.ServerStreaming {
+			return fmt.Errorf("'%v' is not a server streaming method, so it must respond with a single message", m.Name)
+		}
+		bodies = list
+	}
+
+	for _, body := range bodies {
+		msg, err := encode(m.output, body)
+		if err != nil {
+			return fmt.Errorf("invalid response to '%v': %v", m.Name, err)
+		}
+		r.messages = append(r.messages, msg)
+	}
+	return nil
+}
+
+// resolve loads the protos (relative to dir), and encodes every response using the types of its method
+func (f Fixtures) resolve(dir string) (*registry, map[string]Resource, []string, error) {
+	protos := relativePaths(dir, f.Protos)
+	r, sources, err := load(protos, relativePaths(dir, f.ImportPaths))
+	if err != nil {
+		return nil, nil, sources, err
+	}
+
+	resources := make(map[string]Resource)
+	for name, res := range f.Fixtures {
+		name = strings.TrimPrefix(name, "/")
+		m, ok := r.methods[name]
+		if !ok {
+			return nil, nil, sources, fmt.Errorf("no method '%v' is declared by the protos %v, expected a method such as package.Service/Method", name, f.Protos)
+		}
+
+		if err := res.Response.encode(m); err != nil {
+			return nil, nil, sources, err
+		}
+
+		for i := range res.Discriminators {
+			if err := res.Discriminators[i].Response.encode(m); err != nil {
+				return nil, nil, sources, err
+			}
+		}
+		resources[name] = res
+	}
+	return r, resources, sources, nil
+}
+
+func relativePaths(dir string, paths []string) []string {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		resolved = append(resolved, p)
+	}
+	return resolved
+}
+
+// Code is a gRPC status code
+type Code int
+
+const (
+	OK Code = iota
+	Cancelled
+	Unknown
+	InvalidArgument
+	DeadlineExceeded
+	NotFound
+	AlreadyExists
+	PermissionDenied
+	ResourceExhausted
+	FailedPrecondition
+	Aborted
+	OutOfRange
+	Unimplemented
+	Internal
+	Unavailable
+	DataLoss
+	Unauthenticated
+)
+
+var codeNames = []string{"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED"}
+
+func (c Code) String() string {
+	if c >= 0 && int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return strconv.Itoa(int(c))
+}
+
+// UnmarshalJSON accepts the name of a status code (i.e. NOT_FOUND), or its number
+func (c *Code) UnmarshalJSON(d []byte) error {
+	var n int
+	if err := json.Unmarshal(d, &n); err == nil {
+		*c = Code(n)
+		return nil
+	}
+
+	var name string
+	if err := json.Unmarshal(d, &name); err == nil {
+		for i, code := range codeNames {
+			if strings.EqualFold(code, name) {
+				*c = Code(i)
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("expected status to be a gRPC status code, i.e. NOT_FOUND, but was '%v'", string(d))
+}
@@ -0,0 +1,159 @@
+package grpc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const grpcFixturesTestYAML = `
+protos:
+  - content.proto
+fixtures:
+  content.v1.ContentService/GetContent:
+    - when:
+        body:
+          json:
+            $.uuid: c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d
+      response:
+        body:
+          uuid: c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d
+          title: Markets rally
+          views: 9007199254740993
+    - when:
+        headers:
+          authorization: Bearer expired
+      response:
+        status: UNAUTHENTICATED
+        message: the token has expired
+    - response:
+        status: NOT_FOUND
+        message: content not found
+  /content.v1.ContentService/ListContent:
+    headers:
+      x-request-id: tid_testing
+    trailers:
+      x-total: "2"
+    body:
+      - title: First
+      - title: Second
+  content.v1.ContentService/UploadContent:
+    body:
+      count: 2
+`
+
+func resolveFixtures(t *testing.T, yml string) (*registry, map[string]Resource, error) {
+	dir := writeProtos(t, map[string]string{"content.proto": contentProto})
+	defer os.RemoveAll(dir)
+
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(yml), &f))
+
+	r, resources, sources, err := f.resolve(dir)
+	if err == nil {
+		assert.Equal(t, []string{filepath.Join(dir, "content.proto")}, sources)
+	}
+	return r, resources, err
+}
+
+func TestFixtures(t *testing.T) {
+	r, resources, err := resolveFixtures(t, grpcFixturesTestYAML)
+	require.NoError(t, err)
+
+	get := resources["content.v1.ContentService/GetContent"]
+	require.Len(t, get.Discriminators, 3)
+	assert.True(t, get.Discriminators[0].When.Body.JSON.Validate([]byte(`{"uuid":"c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d"}`)))
+	assert.Equal(t, OK, get.Discriminators[0].Response.Status)
+	assert.Equal(t, Unauthenticated, get.Discriminators[1].Response.Status)
+	assert.Equal(t, "the token has expired", get.Discriminators[1].Response.Message)
+	assert.Empty(t, get.Discriminators[1].Response.messages)
+
+	expected, err := encode(message(t, r, "content.v1.Content"), map[string]interface{}{"uuid": "c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d", "title": "Markets rally", "views": "9007199254740993"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{expected}, get.Discriminators[0].Response.messages)
+
+	list, ok := resources["content.v1.ContentService/ListContent"]
+	require.True(t, ok, "the leading slash should be removed")
+	assert.Len(t, list.Response.messages, 2)
+	assert.Equal(t, map[string]string{"x-request-id": "tid_testing"}, list.Response.Headers)
+	assert.Equal(t, map[string]string{"x-total": "2"}, list.Response.Trailers)
+
+	upload := resources["content.v1.ContentService/UploadContent"]
+	assert.Equal(t, [][]byte{{0x08, 0x02}}, upload.Response.messages)
+}
+
+func TestFixtures__EmptyResponse(t *testing.T) {
+	_, resources, err := resolveFixtures(t, `
+protos: [content.proto]
+fixtures:
+  content.v1.ContentService/GetContent:
+    status: OK
+  content.v1.ContentService/ListContent:
+    status: OK
+`)
+	require.NoError(t, err)
+
+	assert.Equal(t, [][]byte{{}}, resources["content.v1.ContentService/GetContent"].Response.messages, "unary methods must respond with a message")
+	assert.Empty(t, resources["content.v1.ContentService/ListContent"].Response.messages)
+}
+
+func TestFixtures__UnknownMethod(t *testing.T) {
+	_, _, err := resolveFixtures(t, `
+protos: [content.proto]
+fixtures:
+  content.v1.ContentService/DeleteContent:
+    status: OK
+`)
+	assert.EqualError(t, err, "no method 'content.v1.ContentService/DeleteContent' is declared by the protos [content.proto], expected a method such as package.Service/Method")
+}
+
+func TestFixtures__ListForUnaryMethod(t *testing.T) {
+	_, _, err := resolveFixtures(t, `
+protos: [content.proto]
+fixtures:
+  content.v1.ContentService/GetContent:
+    body:
+      - title: First
+`)
+	assert.EqualError(t, err, "'content.v1.ContentService/GetContent' is not a server streaming method, so it must respond with a single message")
+}
+
+func TestFixtures__InvalidBody(t *testing.T) {
+	_, _, err := resolveFixtures(t, `
+protos: [content.proto]
+fixtures:
+  content.v1.ContentService/GetContent:
+    body:
+      headline: Markets rally
+`)
+	assert.EqualError(t, err, "invalid response to 'content.v1.ContentService/GetContent': message type content.v1.Content has no known field named headline")
+}
+
+func TestCode__UnmarshalJSON(t *testing.T) {
+	tests := map[string]Code{
+		`"NOT_FOUND"`:   NotFound,
+		`"not_found"`:   NotFound,
+		`5`:             NotFound,
+		`"OK"`:          OK,
+		`"UNAVAILABLE"`: Unavailable,
+	}
+
+	for j, expected := range tests {
+		var c Code
+		require.NoError(t, c.UnmarshalJSON([]byte(j)), j)
+		assert.Equal(t, expected, c, j)
+	}
+
+	var c Code
+	assert.EqualError(t, c.UnmarshalJSON([]byte(`"MISSING"`)), `expected status to be a gRPC status code, i.e. NOT_FOUND, but was '"MISSING"'`)
+}
+
+func TestCode__String(t *testing.T) {
+	assert.Equal(t, "NOT_FOUND", NotFound.String())
+	assert.Equal(t, "UNAUTHENTICATED", Unauthenticated.String())
+	assert.Equal(t, "99", Code(99).String())
+}
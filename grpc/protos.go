@@ -0,0 +1,224 @@
+package grpc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+// Method is a method of a service, i.e. content.ContentService/GetContent
+type Method struct {
+	Name            string
+	ClientStreaming bool
+	ServerStreaming bool
+
+	input  *desc.MessageDescriptor
+	output *desc.MessageDescriptor
+}
+
+// registry holds every file that was loaded (including their imports), and the methods of the services they declare
+type registry struct {
+	files    map[string]*desc.FileDescriptor
+	methods  map[string]*Method
+	services []string
+}
+
+func newRegistry(files []*desc.FileDescriptor) *registry {
+	r := &registry{files: make(map[string]*desc.FileDescriptor), methods: make(map[string]*Method)}
+	for _, fd := range files {
+		r.add(fd)
+	}
+
+	sort.Strings(r.services)
+	return r
+}
+
+func (r *registry) add(fd *desc.FileDescriptor) {
+	if _, ok := r.files[fd.GetName()]; ok {
+		return
+	}
+	r.files[fd.GetName()] = fd
+
+	for _, dep := range fd.GetDependencies() {
+		r.add(dep)
+	}
+
+	for _, s := range fd.GetServices() {
+		r.services = append(r.services, s.GetFullyQualifiedName())
+		for _, m := range s.GetMethods() {
+			name := s.GetFullyQualifiedName() + "/" + m.GetName()
+			r.methods[name] = &Method{Name: name, ClientStreaming: m.IsClientStreaming(), ServerStreaming: m.IsServerStreaming(), input: m.GetInputType(), output: m.GetOutputType()}
+		}
+	}
+}
+
+// findSymbol returns the service, method, message or enum with the fully qualified name, or nil if no file declares it
+func (r *registry) findSymbol(name string) desc.Descriptor {
+	for _, fd := range r.files {
+		if d := fd.FindSymbol(name); d != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// dependencies returns the file and every file it imports, directly or indirectly, with imports before the files which import them
+func (r *registry) dependencies(name string) []*desc.FileDescriptor {
+	files := make([]*desc.FileDescriptor, 0)
+	seen := make(map[string]bool)
+
+	var visit func(*desc.FileDescriptor)
+	visit = func(fd *desc.FileDescriptor) {
+		if seen[fd.GetName()] {
+			return
+		}
+		seen[fd.GetName()] = true
+
+		for _, dep := range fd.GetDependencies() {
+			visit(dep)
+		}
+		files = append(files, fd)
+	}
+
+	if fd, ok := r.files[name]; ok {
+		visit(fd)
+	}
+	return files
+}
+
+// load reads the services, messages and enums declared by .proto files (whose imports are found in the import paths, or the directory of the file) and FileDescriptorSets (i.e. from protoc --descriptor_set_out --include_imports). It returns the registry of every type, along with every file it read so they can be watched for changes.
+func load(paths []string, importPaths []string) (*registry, []string, error) {
+	files, err := parseReflectionProtos()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the files of descriptor sets and the reflection services can be imported by the .proto files
+	compiled := make(map[string]*desc.FileDescriptor)
+	for _, fd := range files {
+		compiled[fd.GetName()] = fd
+	}
+
+	sources := make([]string, 0)
+	protos := make([]string, 0)
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".proto") {
+			protos = append(protos, path)
+			continue
+		}
+
+		sources = append(sources, path)
+		set, err := loadDescriptorSet(path)
+		if err != nil {
+			return nil, sources, err
+		}
+
+		for _, fd := range set {
+			compiled[fd.GetName()] = fd
+			files = append(files, fd)
+		}
+	}
+
+	if len(protos) == 0 {
+		return newRegistry(files), sources, nil
+	}
+
+	importPaths, names := protoNames(protos, importPaths)
+	parser := protoparse.Parser{
+		ImportPaths: importPaths,
+		Accessor: func(path string) (io.ReadCloser, error) {
+			f, err := os.Open(path)
+			if err == nil {
+				sources = append(sources, path)
+			}
+			return f, err
+		},
+		LookupImport: func(name string) (*desc.FileDescriptor, error) {
+			if fd, ok := compiled[name]; ok {
+				return fd, nil
+			}
+			return desc.LoadFileDescriptor(name)
+		},
+	}
+
+	parsed, err := parser.ParseFiles(names...)
+	if err != nil {
+		return nil, sources, err
+	}
+	return newRegistry(append(files, parsed...)), sources, nil
+}
+
+// protoNames returns the name of each .proto file relative to the first import path it is found in. Files which are not in any of the import paths are relative to their own directory, which is added to the import paths.
+func protoNames(protos []string, importPaths []string) ([]string, []string) {
+	paths := append([]string{}, importPaths...)
+	names := make([]string, 0, len(protos))
+	for _, path := range protos {
+		name := ""
+		for _, dir := range paths {
+			rel, err := filepath.Rel(dir, path)
+			if err == nil && !strings.HasPrefix(rel, "..") {
+				name = filepath.ToSlash(rel)
+				break
+			}
+		}
+
+		if name == "" {
+			paths = append(paths, filepath.Dir(path))
+			name = filepath.Base(path)
+		}
+		names = append(names, name)
+	}
+	return paths, names
+}
+
+func loadDescriptorSet(path string) ([]*desc.FileDescriptor, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &descriptor.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to read the FileDescriptorSet '%v': %v", path, err)
+	}
+
+	for i, fd := range set.File {
+		if fd.GetName() == "" {
+			return nil, fmt.Errorf("failed to read the FileDescriptorSet '%v': file %v has no name", path, i+1)
+		}
+	}
+
+	files, err := desc.CreateFileDescriptorsFromSet(set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the FileDescriptorSet '%v': %v", path, err)
+	}
+
+	loaded := make([]*desc.FileDescriptor, 0, len(set.File))
+	for _, fd := range set.File {
+		loaded = append(loaded, files[fd.GetName()])
+	}
+	return loaded, nil
+}
+
+// parseReflectionProtos parses the files of the reflection services, which are served along with the services of the protos
+func parseReflectionProtos() ([]*desc.FileDescriptor, error) {
+	parser := protoparse.Parser{
+		Accessor: func(name string) (io.ReadCloser, error) {
+			source, ok := reflectionProtos[name]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return ioutil.NopCloser(strings.NewReader(source)), nil
+		},
+	}
+	return parser.ParseFiles(reflectionFiles...)
+}
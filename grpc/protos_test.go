@@ -0,0 +1,273 @@
+package grpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const contentProto = `
+syntax = "proto3";
+
+package content.v1;
+
+import "google/protobuf/timestamp.proto";
+
+// ContentService serves published content
+service ContentService {
+  rpc GetContent(GetContentRequest) returns (Content);
+  rpc ListContent(ListContentRequest) returns (stream Content);
+  rpc UploadContent(stream Content) returns (UploadSummary);
+}
+
+message GetContentRequest {
+  string uuid = 1;
+}
+
+message ListContentRequest {
+  int32 limit = 1;
+}
+
+message UploadSummary {
+  int32 count = 1;
+}
+
+message Content {
+  enum Type {
+    ARTICLE = 0;
+    VIDEO = 1;
+  }
+
+  string uuid = 1;
+  string title = 2;
+  int64 views = 3;
+  repeated string tags = 4;
+  Type type = 5;
+  map<string, string> metadata = 6;
+  google.protobuf.Timestamp published_date = 7;
+  bytes thumbnail = 8;
+  repeated int32 scores = 9;
+}
+`
+
+// writeProtos writes the files into a temporary directory, which the caller must remove
+func writeProtos(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "ersatz-grpc")
+	require.NoError(t, err)
+
+	for name, source := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(source), 0644))
+	}
+	return dir
+}
+
+func loadContent(t *testing.T) *registry {
+	dir := writeProtos(t, map[string]string{"content.proto": contentProto})
+	defer os.RemoveAll(dir)
+
+	r, _, err := load([]string{filepath.Join(dir, "content.proto")}, nil)
+	require.NoError(t, err)
+	return r
+}
+
+// message returns the message type declared by one of the loaded files
+func message(t *testing.T, r *registry, name string) *desc.MessageDescriptor {
+	md, ok := r.findSymbol(name).(*desc.MessageDescriptor)
+	require.True(t, ok, name)
+	return md
+}
+
+func TestLoad__Proto(t *testing.T) {
+	r := loadContent(t)
+
+	assert.Equal(t, []string{"content.v1.ContentService", "grpc.reflection.v1.ServerReflection", "grpc.reflection.v1alpha.ServerReflection"}, r.services)
+
+	m := r.methods["content.v1.ContentService/ListContent"]
+	require.NotNil(t, m)
+	assert.False(t, m.ClientStreaming)
+	assert.True(t, m.ServerStreaming)
+	assert.Equal(t, "content.v1.ListContentRequest", m.input.GetFullyQualifiedName())
+	assert.Equal(t, "content.v1.Content", m.output.GetFullyQualifiedName())
+
+	content := message(t, r, "content.v1.Content")
+	assert.Equal(t, "publishedDate", content.FindFieldByNumber(7).GetJSONName())
+	assert.Equal(t, "google.protobuf.Timestamp", content.FindFieldByNumber(7).GetMessageType().GetFullyQualifiedName())
+	assert.Equal(t, "content.v1.Content.Type", content.FindFieldByNumber(5).GetEnumType().GetFullyQualifiedName())
+	assert.True(t, content.FindFieldByNumber(6).IsMap())
+
+	assert.Equal(t, "content.proto", r.findSymbol("content.v1.ContentService.GetContent").GetFile().GetName())
+	assert.Equal(t, "google/protobuf/timestamp.proto", r.findSymbol("google.protobuf.Timestamp").GetFile().GetName())
+	assert.Nil(t, r.findSymbol("content.v1.Missing"))
+}
+
+func TestLoad__ImportPaths(t *testing.T) {
+	dir := writeProtos(t, map[string]string{
+		"protos/content/v1/content.proto": `
+syntax = "proto3";
+package content.v1;
+import "common/v1/common.proto";
+service ContentService {
+  rpc GetContent(common.v1.Request) returns (common.v1.Response);
+}`,
+		"protos/common/v1/common.proto": `
+syntax = "proto3";
+package common.v1;
+message Request { string uuid = 1; }
+message Response { string title = 1; }`,
+	})
+	defer os.RemoveAll(dir)
+
+	protos := filepath.Join(dir, "protos")
+	r, sources, err := load([]string{filepath.Join(protos, "content", "v1", "content.proto")}, []string{protos})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(protos, "content", "v1", "content.proto"), filepath.Join(protos, "common", "v1", "common.proto")}, sources)
+	assert.Contains(t, r.files, "content/v1/content.proto")
+	assert.Equal(t, []string{"common/v1/common.proto"}, r.files["content/v1/content.proto"].AsFileDescriptorProto().Dependency)
+	assert.Equal(t, "common.v1.Request", r.methods["content.v1.ContentService/GetContent"].input.GetFullyQualifiedName())
+}
+
+func TestLoad__MissingImport(t *testing.T) {
+	dir := writeProtos(t, map[string]string{"content.proto": `
+syntax = "proto3";
+import "missing.proto";`})
+	defer os.RemoveAll(dir)
+
+	_, _, err := load([]string{filepath.Join(dir, "content.proto")}, nil)
+	assert.EqualError(t, err, "content.proto:3:8: open "+filepath.Join(dir, "missing.proto")+": no such file or directory")
+}
+
+func TestLoad__ImportCycle(t *testing.T) {
+	dir := writeProtos(t, map[string]string{
+		"a.proto": `syntax = "proto3"; import "b.proto";`,
+		"b.proto": `syntax = "proto3"; import "a.proto";`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, _, err := load([]string{filepath.Join(dir, "a.proto")}, nil)
+	assert.EqualError(t, err, `a.proto:1:27: cycle found in imports: "a.proto" -> "b.proto" -> "a.proto"`)
+}
+
+func TestLoad__UnknownType(t *testing.T) {
+	dir := writeProtos(t, map[string]string{"content.proto": `
+syntax = "proto3";
+package content;
+message Content { Author author = 1; }`})
+	defer os.RemoveAll(dir)
+
+	_, _, err := load([]string{filepath.Join(dir, "content.proto")}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Author")
+}
+
+// descriptorSet encodes a FileDescriptorSet of the files, in the same way as protoc --descriptor_set_out
+func descriptorSet(t *testing.T, files ...*descriptor.FileDescriptorProto) string {
+	set, err := proto.Marshal(&descriptor.FileDescriptorSet{File: files})
+	require.NoError(t, err)
+	return string(set)
+}
+
+func TestLoad__DescriptorSet(t *testing.T) {
+	r := loadContent(t)
+
+	files := make([]*descriptor.FileDescriptorProto, 0)
+	for _, f := range r.dependencies("content.proto") {
+		files = append(files, f.AsFileDescriptorProto())
+	}
+
+	dir := writeProtos(t, map[string]string{"content.protoset": descriptorSet(t, files...)})
+	defer os.RemoveAll(dir)
+
+	loaded, sources, err := load([]string{filepath.Join(dir, "content.protoset")}, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{filepath.Join(dir, "content.protoset")}, sources)
+	assert.Equal(t, r.services, loaded.services)
+	assert.True(t, proto.Equal(r.files["content.proto"].AsFileDescriptorProto(), loaded.files["content.proto"].AsFileDescriptorProto()))
+
+	m := loaded.methods["content.v1.ContentService/GetContent"]
+	require.NotNil(t, m)
+	assert.Equal(t, "content.v1.GetContentRequest", m.input.GetFullyQualifiedName())
+	assert.Equal(t, "content.v1.Content.Type", message(t, loaded, "content.v1.Content").FindFieldByNumber(5).GetEnumType().GetFullyQualifiedName())
+}
+
+func TestLoad__DescriptorSetWithoutImports(t *testing.T) {
+	r := loadContent(t)
+
+	dir := writeProtos(t, map[string]string{"content.protoset": descriptorSet(t, r.files["content.proto"].AsFileDescriptorProto())})
+	defer os.RemoveAll(dir)
+
+	_, _, err := load([]string{filepath.Join(dir, "content.protoset")}, nil)
+	assert.EqualError(t, err, "failed to read the FileDescriptorSet '"+filepath.Join(dir, "content.protoset")+"': no such file: \"google/protobuf/timestamp.proto\"")
+}
+
+func TestLoad__DescriptorSetWithoutName(t *testing.T) {
+	dir := writeProtos(t, map[string]string{"content.protoset": descriptorSet(t, &descriptor.FileDescriptorProto{Package: proto.String("content")})})
+	defer os.RemoveAll(dir)
+
+	_, _, err := load([]string{filepath.Join(dir, "content.protoset")}, nil)
+	assert.EqualError(t, err, "failed to read the FileDescriptorSet '"+filepath.Join(dir, "content.protoset")+"': file 1 has no name")
+}
+
+func TestLoad__WellKnownTypes(t *testing.T) {
+	dir := writeProtos(t, map[string]string{"types.proto": `
+syntax = "proto3";
+package types;
+import "google/protobuf/any.proto";
+import "google/protobuf/duration.proto";
+import "google/protobuf/empty.proto";
+import "google/protobuf/field_mask.proto";
+import "google/protobuf/struct.proto";
+import "google/protobuf/wrappers.proto";
+import "google/protobuf/descriptor.proto";
+message Types {
+  google.protobuf.Any any = 1;
+  google.protobuf.Duration duration = 2;
+  google.protobuf.Empty empty = 3;
+  google.protobuf.FieldMask mask = 4;
+  google.protobuf.Struct struct = 5;
+  google.protobuf.StringValue wrapper = 6;
+  google.protobuf.FileDescriptorProto file = 7;
+}`})
+	defer os.RemoveAll(dir)
+
+	r, _, err := load([]string{filepath.Join(dir, "types.proto")}, nil)
+	require.NoError(t, err)
+
+	types := message(t, r, "types.Types")
+	msg, err := encode(types, decodeJSON(t, `{"duration":"1.5s","struct":{"title":"Markets rally","tags":["markets"]},"wrapper":"FT","mask":{"paths":["title"]},"file":{"name":"content.proto"}}`))
+	require.NoError(t, err)
+
+	decoded, err := decode(types, msg)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"duration":"1.500s","struct":{"title":"Markets rally","tags":["markets"]},"wrapper":"FT","mask":{"paths":["title"]},"file":{"name":"content.proto"}}`, string(decoded))
+}
+
+func TestLoad__InvalidDescriptorSet(t *testing.T) {
+	dir := writeProtos(t, map[string]string{"content.protoset": "not a descriptor set"})
+	defer os.RemoveAll(dir)
+
+	_, _, err := load([]string{filepath.Join(dir, "content.protoset")}, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read the FileDescriptorSet")
+}
+
+func TestDependencies(t *testing.T) {
+	r := loadContent(t)
+
+	files := r.dependencies("content.proto")
+	require.Len(t, files, 2)
+	assert.Equal(t, "google/protobuf/timestamp.proto", files[0].GetName())
+	assert.Equal(t, "content.proto", files[1].GetName())
+
+	assert.Empty(t, r.dependencies("missing.proto"))
+}
@@ -0,0 +1,189 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jhump/protoreflect/desc"
+	log "github.com/sirupsen/logrus"
+)
+
+// reflectionFiles declare the v1 and v1alpha reflection services, which are served along with the services of the protos so clients such as grpcurl can discover them
+var reflectionFiles = []string{"grpc/reflection/v1/reflection.proto", "grpc/reflection/v1alpha/reflection.proto"}
+
+// reflectionProtos are the sources of the reflection files, which can be imported by the protos without being in an import path
+var reflectionProtos = map[string]string{
+	"grpc/reflection/v1/reflection.proto":      strings.Replace(reflectionProto, "VERSION", "v1", 1),
+	"grpc/reflection/v1alpha/reflection.proto": strings.Replace(reflectionProto, "VERSION", "v1alpha", 1),
+}
+
+const reflectionProto = `
+syntax = "proto3";
+package grpc.reflection.VERSION;
+service ServerReflection {
+  rpc ServerReflectionInfo(stream ServerReflectionRequest) returns (stream ServerReflectionResponse);
+}
+message ServerReflectionRequest {
+  string host = 1;
+  oneof message_request {
+    string file_by_filename = 3;
+    string file_containing_symbol = 4;
+    ExtensionRequest file_containing_extension = 5;
+    string all_extension_numbers_of_type = 6;
+    string list_services = 7;
+  }
+}
+message ExtensionRequest {
+  string containing_type = 1;
+  int32 extension_number = 2;
+}
+message ServerReflectionResponse {
+  string valid_host = 1;
+  ServerReflectionRequest original_request = 2;
+  oneof message_response {
+    FileDescriptorResponse file_descriptor_response = 4;
+    ExtensionNumberResponse all_extension_numbers_response = 5;
+    ListServiceResponse list_services_response = 6;
+    ErrorResponse error_response = 7;
+  }
+}
+message FileDescriptorResponse {
+  repeated bytes file_descriptor_proto = 1;
+}
+message ExtensionNumberResponse {
+  string base_type_name = 1;
+  repeated int32 extension_number = 2;
+}
+message ListServiceResponse {
+  repeated ServiceResponse service = 1;
+}
+message ServiceResponse {
+  string name = 1;
+}
+message ErrorResponse {
+  int32 error_code = 1;
+  string error_message = 2;
+}`
+
+func isReflection(name string) bool {
+	return name == "grpc.reflection.v1.ServerReflection/ServerReflectionInfo" || name == "grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+}
+
+// IsReflection returns true if the request is a call to the reflection service. Calls stream in both directions, so they must be served before the request body has been read.
+func IsReflection(r *http.Request) bool {
+	return IsGRPC(r) && isReflection(strings.TrimPrefix(r.URL.Path, "/"))
+}
+
+// reflect responds to each reflection request as it is received, until the client closes the stream
+func (s *Server) reflect(w http.ResponseWriter, r *http.Request) {
+	m := s.registry.methods[strings.TrimPrefix(r.URL.Path, "/")]
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	status, message := OK, ""
+	for {
+		msg, err := readMessage(r.Body, r.Header.Get("Grpc-Encoding"))
+		if err != nil {
+			if err != io.EOF {
+				status, message = Internal, fmt.Sprintf("failed to read the request: %v", err)
+			}
+			break
+		}
+
+		req := make(map[string]interface{})
+		j, err := decode(m.input, msg)
+		if err == nil {
+			err = json.Unmarshal(j, &req)
+		}
+
+		if err != nil {
+			status, message = Internal, fmt.Sprintf("failed to decode the request: %v", err)
+			break
+		}
+
+		res, err := encode(m.output, s.reflection(req))
+		if err == nil {
+			err = writeMessage(w, res)
+		}
+
+		if err != nil {
+			log.WithError(err).Warn("Failed to write the reflection response")
+			return
+		}
+	}
+
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", fmt.Sprint(int(status)))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(message))
+	}
+}
+
+// reflection returns the JSON form of the response to a reflection request
+func (s *Server) reflection(req map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{"validHost": req["host"], "originalRequest": req}
+
+	if _, ok := req["listServices"]; ok {
+		services := make([]interface{}, 0, len(s.registry.services))
+		for _, name := range s.registry.services {
+			services = append(services, map[string]interface{}{"name": name})
+		}
+		res["listServicesResponse"] = map[string]interface{}{"service": services}
+		return res
+	}
+
+	if name, ok := req["fileByFilename"].(string); ok {
+		if _, ok := s.registry.files[name]; !ok {
+			return reflectionError(res, NotFound, fmt.Sprintf("file '%v' not found", name))
+		}
+		return s.fileDescriptors(res, name)
+	}
+
+	if symbol, ok := req["fileContainingSymbol"].(string); ok {
+		d := s.registry.findSymbol(strings.TrimPrefix(symbol, "."))
+		if d == nil {
+			return reflectionError(res, NotFound, fmt.Sprintf("symbol '%v' not found", symbol))
+		}
+		return s.fileDescriptors(res, d.GetFile().GetName())
+	}
+
+	if typ, ok := req["allExtensionNumbersOfType"].(string); ok {
+		if _, ok := s.registry.findSymbol(typ).(*desc.MessageDescriptor); !ok {
+			return reflectionError(res, NotFound, fmt.Sprintf("type '%v' not found", typ))
+		}
+		res["allExtensionNumbersResponse"] = map[string]interface{}{"baseTypeName": typ, "extensionNumber": []interface{}{}}
+		return res
+	}
+
+	if _, ok := req["fileContainingExtension"]; ok {
+		return reflectionError(res, NotFound, "extensions are not supported")
+	}
+	return reflectionError(res, Unimplemented, "unsupported reflection request")
+}
+
+// fileDescriptors responds with the encoded descriptors of the file, followed by everything it imports
+func (s *Server) fileDescriptors(res map[string]interface{}, name string) map[string]interface{} {
+	dependencies := s.registry.dependencies(name)
+	files := make([]interface{}, 0, len(dependencies))
+	for i := len(dependencies) - 1; i >= 0; i-- {
+		raw, err := proto.Marshal(dependencies[i].AsFileDescriptorProto())
+		if err != nil {
+			return reflectionError(res, Internal, fmt.Sprintf("failed to encode '%v': %v", dependencies[i].GetName(), err))
+		}
+		files = append(files, base64.StdEncoding.EncodeToString(raw))
+	}
+	res["fileDescriptorResponse"] = map[string]interface{}{"fileDescriptorProto": files}
+	return res
+}
+
+func reflectionError(res map[string]interface{}, code Code, message string) map[string]interface{} {
+	res["errorResponse"] = map[string]interface{}{"errorCode": int(code), "errorMessage": message}
+	return res
+}
@@ -0,0 +1,127 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reflectionMethod = "grpc.reflection.v1.ServerReflection/ServerReflectionInfo"
+
+// reflectionCall sends each reflection request in a single call, and returns the JSON form of each response
+func reflectionCall(t *testing.T, s *Server, method string, requests ...map[string]interface{}) ([]map[string]interface{}, string) {
+	m := s.registry.methods[method]
+	messages := make([][]byte, 0, len(requests))
+	for _, req := range requests {
+		msg, err := encode(m.input, req)
+		require.NoError(t, err)
+		messages = append(messages, msg)
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, grpcRequest(t, method, messages...))
+
+	res := w.Result()
+	responses := make([]map[string]interface{}, 0)
+	for {
+		msg, err := readMessage(res.Body, "")
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		decoded, err := decode(m.output, msg)
+		require.NoError(t, err)
+
+		res := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(decoded, &res))
+		responses = append(responses, res)
+	}
+	return responses, res.Trailer.Get("Grpc-Status")
+}
+
+func TestReflection__ListServices(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	for _, method := range []string{reflectionMethod, "grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"} {
+		responses, status := reflectionCall(t, s, method, map[string]interface{}{"host": "localhost", "listServices": ""})
+		assert.Equal(t, "0", status)
+		require.Len(t, responses, 1)
+
+		assert.Equal(t, "localhost", responses[0]["validHost"])
+		assert.Equal(t, map[string]interface{}{"service": []interface{}{
+			map[string]interface{}{"name": "content.v1.ContentService"},
+			map[string]interface{}{"name": "grpc.reflection.v1.ServerReflection"},
+			map[string]interface{}{"name": "grpc.reflection.v1alpha.ServerReflection"},
+		}}, responses[0]["listServicesResponse"])
+	}
+}
+
+func TestReflection__FileContainingSymbol(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	responses, status := reflectionCall(t, s, reflectionMethod,
+		map[string]interface{}{"fileContainingSymbol": "content.v1.ContentService"},
+		map[string]interface{}{"fileContainingSymbol": "content.v1.Content.Type"},
+		map[string]interface{}{"fileByFilename": "google/protobuf/timestamp.proto"},
+	)
+	assert.Equal(t, "0", status)
+	require.Len(t, responses, 3)
+
+	for _, res := range responses[:2] {
+		files := res["fileDescriptorResponse"].(map[string]interface{})["fileDescriptorProto"].([]interface{})
+		require.Len(t, files, 2)
+
+		names := make([]string, 0, len(files))
+		for _, f := range files {
+			raw, err := base64.StdEncoding.DecodeString(f.(string))
+			require.NoError(t, err)
+
+			fd := &descriptor.FileDescriptorProto{}
+			require.NoError(t, proto.Unmarshal(raw, fd))
+			names = append(names, fd.GetName())
+		}
+		assert.Equal(t, []string{"content.proto", "google/protobuf/timestamp.proto"}, names, "the file should be followed by its imports")
+	}
+
+	files := responses[2]["fileDescriptorResponse"].(map[string]interface{})["fileDescriptorProto"].([]interface{})
+	assert.Len(t, files, 1)
+}
+
+func TestReflection__Errors(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	responses, status := reflectionCall(t, s, reflectionMethod,
+		map[string]interface{}{"fileContainingSymbol": "content.v1.Missing"},
+		map[string]interface{}{"fileByFilename": "missing.proto"},
+		map[string]interface{}{"fileContainingExtension": map[string]interface{}{"containingType": "content.v1.Content", "extensionNumber": 100}},
+	)
+	assert.Equal(t, "0", status)
+	require.Len(t, responses, 3)
+
+	assert.Equal(t, map[string]interface{}{"errorCode": float64(5), "errorMessage": "symbol 'content.v1.Missing' not found"}, responses[0]["errorResponse"])
+	assert.Equal(t, map[string]interface{}{"errorCode": float64(5), "errorMessage": "file 'missing.proto' not found"}, responses[1]["errorResponse"])
+	assert.Equal(t, map[string]interface{}{"errorCode": float64(5), "errorMessage": "extensions are not supported"}, responses[2]["errorResponse"])
+}
+
+func TestReflection__AllExtensionNumbersOfType(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	responses, _ := reflectionCall(t, s, reflectionMethod, map[string]interface{}{"allExtensionNumbersOfType": "content.v1.Content"})
+	require.Len(t, responses, 1)
+	assert.Equal(t, map[string]interface{}{"baseTypeName": "content.v1.Content"}, responses[0]["allExtensionNumbersResponse"])
+}
+
+func TestIsReflection(t *testing.T) {
+	assert.True(t, IsReflection(grpcRequest(t, reflectionMethod)))
+	assert.True(t, IsReflection(grpcRequest(t, "grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo")))
+	assert.False(t, IsReflection(grpcRequest(t, "content.v1.ContentService/GetContent")))
+	assert.False(t, IsReflection(httptest.NewRequest("POST", "/"+reflectionMethod, nil)))
+}
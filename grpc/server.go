@@ -0,0 +1,258 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/peteclark-ft/ersatz/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMessageSize is the largest request message ersatz accepts, which is the default of most gRPC servers
+const maxMessageSize = 4 << 20
+
+var (
+	ErrMessageTooLarge = errors.New("the request message is larger than 4MB")
+	ErrTruncated       = errors.New("the message is truncated")
+)
+
+// Server serves the gRPC services declared by the protos, responding to each call with the fixtures of its method
+type Server struct {
+	registry  *registry
+	resources map[string]Resource
+}
+
+// NewServer loads the protos of the fixtures (relative to dir), and encodes every response using the types of its method. It returns every file it read, so they can be watched for changes.
+func NewServer(f Fixtures, dir string) (*Server, []string, error) {
+	r, resources, sources, err := f.resolve(dir)
+	if err != nil {
+		return nil, sources, err
+	}
+	return &Server{registry: r, resources: resources}, sources, nil
+}
+
+// IsGRPC returns true if the request is a gRPC call
+func IsGRPC(r *http.Request) bool {
+	return r.Method == "POST" && r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc")
+}
+
+// Middleware serves gRPC calls, and passes every other request to next
+func (s *Server) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if IsGRPC(r) {
+			s.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if isReflection(name) {
+		s.reflect(w, r)
+		return
+	}
+
+	m, ok := s.registry.methods[name]
+	if !ok {
+		writeStatus(w, Unimplemented, fmt.Sprintf("unknown method '%v'", name))
+		return
+	}
+
+	messages, err := readMessages(r)
+	if err != nil {
+		writeStatus(w, Internal, fmt.Sprintf("failed to read the request: %v", err))
+		return
+	}
+
+	body, err := decodeRequest(m, messages)
+	if err != nil {
+		writeStatus(w, Internal, fmt.Sprintf("failed to decode the request: %v", err))
+		return
+	}
+	journal.SetBody(r, string(body))
+
+	res, ok := s.resources[name]
+	if !ok {
+		log.WithField("method", name).Warn("No fixtures are configured for the gRPC method")
+		writeStatus(w, Unimplemented, fmt.Sprintf("no fixtures are configured for '%v'", name))
+		return
+	}
+
+	journal.SetFixture(r, name, r.Method)
+	writeResponse(res.choose(r, body), w, r)
+}
+
+// choose returns the response of the first discriminator the call satisfies, or UNIMPLEMENTED if it satisfies none
+func (res Resource) choose(r *http.Request, body []byte) Response {
+	if len(res.Discriminators) == 0 {
+		return res.Response
+	}
+
+	req := r.WithContext(r.Context())
+	req.Header = make(http.Header)
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	discriminators := make(v2.Discriminators, 0, len(res.Discriminators))
+	for i, d := range res.Discriminators {
+		if d.When.SatisfiesDiscriminator(req) {
+			journal.SetDiscriminator(r, i)
+			return d.Response
+		}
+		discriminators = append(discriminators, v2.Discriminator{When: d.When})
+	}
+
+	report := discriminators.Diagnose(req, nil)
+	journal.SetDiagnostics(r, report)
+	return Response{Status: Unimplemented, Message: report.Message}
+}
+
+// decodeRequest returns the JSON form of the request message, or a list of the messages of a client streaming call
+func decodeRequest(m *Method, messages [][]byte) ([]byte, error) {
+	values := make([]json.RawMessage, 0, len(messages))
+	for _, msg := range messages {
+		v, err := decode(m.input, msg)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	if m.ClientStreaming {
+		return json.Marshal(values)
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf("expected a single request message, but received %v", len(values))
+	}
+	return values[0], nil
+}
+
+// readMessages reads every length prefixed message in the request body
+func readMessages(r *http.Request) ([][]byte, error) {
+	messages := make([][]byte, 0)
+	for {
+		msg, err := readMessage(r.Body, r.Header.Get("Grpc-Encoding"))
+		if err == io.EOF {
+			return messages, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+}
+
+func readMessage(body io.Reader, encoding string) ([]byte, error) {
+	prefix := make([]byte, 5)
+	if _, err := io.ReadFull(body, prefix); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, ErrTruncated
+	}
+
+	length := binary.BigEndian.Uint32(prefix[1:])
+	if length > maxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, ErrTruncated
+	}
+
+	if prefix[0] == 0 {
+		return msg, nil
+	}
+
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported message encoding '%v'", encoding)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(io.LimitReader(zr, maxMessageSize))
+}
+
+// writeMessage writes a length prefixed, uncompressed message, and flushes it to the client
+func writeMessage(w http.ResponseWriter, msg []byte) error {
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	if _, err := w.Write(append(prefix, msg...)); err != nil {
+		return err
+	}
+
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func writeResponse(res Response, w http.ResponseWriter, r *http.Request) {
+	if res.Delay != nil {
+		res.Delay.Wait(r.Context())
+	}
+
+	for k, v := range res.Headers {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.WriteHeader(http.StatusOK)
+
+	for _, msg := range res.messages {
+		if err := writeMessage(w, msg); err != nil {
+			log.WithError(err).Warn("Failed to write the gRPC response")
+			return
+		}
+	}
+
+	for k, v := range res.Trailers {
+		w.Header().Set(http.TrailerPrefix+k, v)
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(res.Status)))
+	if res.Message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(res.Message))
+	}
+}
+
+// writeStatus responds without any messages, so the status is sent in the headers
+func writeStatus(w http.ResponseWriter, code Code, message string) {
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Grpc-Status", strconv.Itoa(int(code)))
+	w.Header().Set("Grpc-Message", encodeMessage(message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeMessage percent encodes the status message, as required by the gRPC protocol
+func encodeMessage(message string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
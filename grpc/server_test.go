@@ -0,0 +1,254 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T, yml string) *Server {
+	dir := writeProtos(t, map[string]string{"content.proto": contentProto})
+	defer os.RemoveAll(dir)
+
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(yml), &f))
+
+	s, _, err := NewServer(f, dir)
+	require.NoError(t, err)
+	return s
+}
+
+// frame length prefixes the message, compressing it if compressed is true
+func frame(t *testing.T, msg []byte, compressed bool) []byte {
+	prefix := make([]byte, 5)
+	if compressed {
+		var b bytes.Buffer
+		zw := gzip.NewWriter(&b)
+		_, err := zw.Write(msg)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		prefix[0] = 1
+		msg = b.Bytes()
+	}
+
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(msg)))
+	return append(prefix, msg...)
+}
+
+func grpcRequest(t *testing.T, method string, messages ...[]byte) *http.Request {
+	body := make([]byte, 0)
+	for _, msg := range messages {
+		body = append(body, frame(t, msg, false)...)
+	}
+
+	req := httptest.NewRequest("POST", "/"+method, bytes.NewReader(body))
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	return req
+}
+
+// call records the call in a journal, and returns the response along with the messages it sent
+func call(t *testing.T, s *Server, req *http.Request) (*http.Response, [][]byte, journal.Entry) {
+	j := journal.New(10)
+	w := httptest.NewRecorder()
+	j.Record(s).ServeHTTP(w, req)
+
+	res := w.Result()
+	messages := make([][]byte, 0)
+	for {
+		msg, err := readMessage(res.Body, "")
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		messages = append(messages, msg)
+	}
+
+	entries := j.Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	return res, messages, entries[0]
+}
+
+func TestServer__Unary(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+	r := loadContent(t)
+
+	req, err := encode(message(t, r, "content.v1.GetContentRequest"), map[string]interface{}{"uuid": "c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d"})
+	require.NoError(t, err)
+
+	res, messages, entry := call(t, s, grpcRequest(t, "content.v1.ContentService/GetContent", req))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/grpc", res.Header.Get("Content-Type"))
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+
+	require.Len(t, messages, 1)
+	content, err := decode(message(t, r, "content.v1.Content"), messages[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"uuid":"c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d","title":"Markets rally","views":"9007199254740993"}`, string(content))
+
+	assert.JSONEq(t, `{"uuid":"c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d"}`, entry.Body)
+	require.NotNil(t, entry.Fixture)
+	assert.Equal(t, "content.v1.ContentService/GetContent", entry.Fixture.Path)
+	assert.Equal(t, 0, *entry.Fixture.Discriminator)
+}
+
+func TestServer__Metadata(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	req := grpcRequest(t, "content.v1.ContentService/GetContent", []byte{})
+	req.Header.Set("Authorization", "Bearer expired")
+
+	res, messages, entry := call(t, s, req)
+	assert.Empty(t, messages)
+	assert.Equal(t, "16", res.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "the token has expired", res.Trailer.Get("Grpc-Message"))
+	assert.Equal(t, 1, *entry.Fixture.Discriminator)
+}
+
+func TestServer__Fallthrough(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	res, _, entry := call(t, s, grpcRequest(t, "content.v1.ContentService/GetContent", []byte{0x0a, 0x01, 'a'}))
+	assert.Equal(t, "5", res.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "content not found", res.Trailer.Get("Grpc-Message"))
+	assert.Equal(t, 2, *entry.Fixture.Discriminator)
+	assert.False(t, entry.Unmatched())
+}
+
+func TestServer__NoDiscriminatorMatches(t *testing.T) {
+	s := newTestServer(t, `
+protos: [content.proto]
+fixtures:
+  content.v1.ContentService/GetContent:
+    - when:
+        body:
+          json:
+            uuid: c4ae6f2e-9c20-11e8-a9d0-4f2a9b9a8b6d
+      response:
+        body:
+          title: Markets rally
+`)
+
+	res, messages, entry := call(t, s, grpcRequest(t, "content.v1.ContentService/GetContent", []byte{0x0a, 0x01, 'a'}))
+	assert.Empty(t, messages)
+	assert.Equal(t, "12", res.Trailer.Get("Grpc-Status"))
+	assert.NotEmpty(t, res.Trailer.Get("Grpc-Message"))
+	assert.True(t, entry.Unmatched())
+	assert.NotNil(t, entry.Diagnostics)
+}
+
+func TestServer__ServerStreaming(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	res, messages, _ := call(t, s, grpcRequest(t, "content.v1.ContentService/ListContent", []byte{0x08, 0x02}))
+	assert.Equal(t, "tid_testing", res.Header.Get("X-Request-Id"))
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, "2", res.Trailer.Get("X-Total"))
+	assert.Equal(t, [][]byte{{0x12, 0x05, 'F', 'i', 'r', 's', 't'}, {0x12, 0x06, 'S', 'e', 'c', 'o', 'n', 'd'}}, messages)
+}
+
+func TestServer__ClientStreaming(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	res, messages, entry := call(t, s, grpcRequest(t, "content.v1.ContentService/UploadContent", []byte{0x12, 0x01, 'a'}, []byte{0x12, 0x01, 'b'}))
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+	assert.Equal(t, [][]byte{{0x08, 0x02}}, messages)
+	assert.JSONEq(t, `[{"title":"a"},{"title":"b"}]`, entry.Body)
+}
+
+func TestServer__CompressedRequest(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	req := httptest.NewRequest("POST", "/content.v1.ContentService/UploadContent", bytes.NewReader(frame(t, []byte{0x12, 0x01, 'a'}, true)))
+	req.ProtoMajor = 2
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Grpc-Encoding", "gzip")
+
+	res, _, entry := call(t, s, req)
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+	assert.JSONEq(t, `[{"title":"a"}]`, entry.Body)
+}
+
+func TestServer__UnknownMethod(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	res, messages, entry := call(t, s, grpcRequest(t, "content.v1.ContentService/DeleteContent", []byte{}))
+	assert.Empty(t, messages)
+	assert.Equal(t, "12", res.Header.Get("Grpc-Status"), "the status should be sent without any messages")
+	assert.Equal(t, "unknown method 'content.v1.ContentService/DeleteContent'", res.Header.Get("Grpc-Message"))
+	assert.True(t, entry.Unmatched())
+}
+
+func TestServer__NoFixtures(t *testing.T) {
+	s := newTestServer(t, `protos: [content.proto]`)
+
+	res, _, entry := call(t, s, grpcRequest(t, "content.v1.ContentService/GetContent", []byte{0x0a, 0x01, 'a'}))
+	assert.Equal(t, "12", res.Header.Get("Grpc-Status"))
+	assert.Equal(t, "no fixtures are configured for 'content.v1.ContentService/GetContent'", res.Header.Get("Grpc-Message"))
+	assert.JSONEq(t, `{"uuid":"a"}`, entry.Body, "the request message should be journalled even if it is unmatched")
+}
+
+func TestServer__TruncatedRequest(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	req := grpcRequest(t, "content.v1.ContentService/GetContent")
+	req.Body = ioutil.NopCloser(bytes.NewReader([]byte{0, 0, 0, 0, 9, 0x0a}))
+
+	res, _, _ := call(t, s, req)
+	assert.Equal(t, "13", res.Header.Get("Grpc-Status"))
+	assert.Equal(t, "failed to read the request: the message is truncated", res.Header.Get("Grpc-Message"))
+}
+
+func TestServer__UnaryWithoutMessage(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+
+	res, _, _ := call(t, s, grpcRequest(t, "content.v1.ContentService/GetContent"))
+	assert.Equal(t, "13", res.Header.Get("Grpc-Status"))
+	assert.Equal(t, "failed to decode the request: expected a single request message, but received 0", res.Header.Get("Grpc-Message"))
+}
+
+func TestMiddleware(t *testing.T) {
+	s := newTestServer(t, grpcFixturesTestYAML)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	w := httptest.NewRecorder()
+	s.Middleware(next).ServeHTTP(w, httptest.NewRequest("POST", "/content.v1.ContentService/GetContent", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code, "HTTP/1.1 requests are not gRPC calls")
+
+	w = httptest.NewRecorder()
+	s.Middleware(next).ServeHTTP(w, grpcRequest(t, "content.v1.ContentService/GetContent", []byte{}))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/grpc", w.Header().Get("Content-Type"))
+}
+
+func TestIsGRPC(t *testing.T) {
+	assert.True(t, IsGRPC(grpcRequest(t, "content.v1.ContentService/GetContent")))
+
+	req := grpcRequest(t, "content.v1.ContentService/GetContent")
+	req.Header.Set("Content-Type", "application/json")
+	assert.False(t, IsGRPC(req))
+
+	req = grpcRequest(t, "content.v1.ContentService/GetContent")
+	req.Method = "GET"
+	assert.False(t, IsGRPC(req))
+}
+
+func TestEncodeMessage(t *testing.T) {
+	assert.Equal(t, "content not found", encodeMessage("content not found"))
+	assert.Equal(t, "100%25 %E2%9C%93%0A", encodeMessage("100% ✓\n"))
+}
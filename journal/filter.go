@@ -0,0 +1,138 @@
+package journal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Filter selects journalled requests. Empty fields match every request.
+type Filter struct {
+	Method       string            `json:"method,omitempty"`
+	Host         string            `json:"host,omitempty"`
+	Path         string            `json:"path,omitempty"`
+	Protocol     string            `json:"protocol,omitempty"`
+	Fixture      string            `json:"fixture,omitempty"`
+	Status       int               `json:"status,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Query        map[string]string `json:"query,omitempty"`
+	BodyContains string            `json:"bodyContains,omitempty"`
+	Unmatched    bool              `json:"unmatched,omitempty"`
+	Limit        int               `json:"limit,omitempty"`
+}
+
+// FilterFromQuery creates a filter from query parameters, i.e. ?method=GET&fixture=/content/{uuid}&header=X-Request-Id:tid_1234&query=q:example
+func FilterFromQuery(q url.Values) (Filter, error) {
+	f := Filter{
+		Method:       q.Get("method"),
+		Host:         q.Get("host"),
+		Path:         q.Get("path"),
+		Protocol:     q.Get("protocol"),
+		Fixture:      q.Get("fixture"),
+		BodyContains: q.Get("bodyContains"),
+		Headers:      make(map[string]string),
+		Query:        make(map[string]string),
+	}
+
+	var err error
+	if f.Status, err = optionalInt(q, "status"); err != nil {
+		return f, err
+	}
+
+	if q.Get("unmatched") != "" {
+		if f.Unmatched, err = strconv.ParseBool(q.Get("unmatched")); err != nil {
+			return f, fmt.Errorf("expected 'unmatched' to be true or false, but was '%v'", q.Get("unmatched"))
+		}
+	}
+
+	if f.Limit, err = optionalInt(q, "limit"); err != nil {
+		return f, err
+	}
+
+	for _, h := range q["header"] {
+		k, v, err := splitPair(h)
+		if err != nil {
+			return f, err
+		}
+		f.Headers[k] = v
+	}
+
+	for _, p := range q["query"] {
+		k, v, err := splitPair(p)
+		if err != nil {
+			return f, err
+		}
+		f.Query[k] = v
+	}
+	return f, nil
+}
+
+// Matches returns true if the entry satisfies every field of the filter
+func (f Filter) Matches(e Entry) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, e.Method) {
+		return false
+	}
+
+	if f.Host != "" && !strings.EqualFold(f.Host, e.Host) {
+		return false
+	}
+
+	if f.Path != "" && f.Path != e.Path {
+		return false
+	}
+
+	if f.Protocol != "" && !strings.EqualFold(f.Protocol, e.Protocol) {
+		return false
+	}
+
+	if f.Fixture != "" && (e.Fixture == nil || e.Fixture.Path != f.Fixture) {
+		return false
+	}
+
+	if f.Status != 0 && f.Status != e.Status {
+		return false
+	}
+
+	for k, v := range f.Headers {
+		if e.Headers.Get(k) != v {
+			return false
+		}
+	}
+
+	for k, v := range f.Query {
+		if e.Query.Get(k) != v {
+			return false
+		}
+	}
+
+	if f.BodyContains != "" && !strings.Contains(e.Body, f.BodyContains) {
+		return false
+	}
+
+	if f.Unmatched && !e.Unmatched() {
+		return false
+	}
+	return true
+}
+
+func optionalInt(q url.Values, name string) (int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("expected '%v' to be a number, but was '%v'", name, v)
+	}
+	return i, nil
+}
+
+func splitPair(pair string) (string, string, error) {
+	i := strings.Index(pair, ":")
+	if i == -1 {
+		return "", "", fmt.Errorf("expected '%v' to be in the format name:value", pair)
+	}
+	return pair[:i], pair[i+1:], nil
+}
@@ -0,0 +1,91 @@
+package journal
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEntry() Entry {
+	return Entry{
+		Method:   "POST",
+		Host:     "content-api:8080",
+		Path:     "/content/1234",
+		Protocol: "h2",
+		Query:    url.Values{"q": {"example"}},
+		Headers:  http.Header{"X-Request-Id": {"tid_1234"}},
+		Body:     `{"title":"Example"}`,
+		Fixture:  &Fixture{Path: "/content/{uuid}", Method: "post"},
+		Status:   http.StatusCreated,
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	e := testEntry()
+
+	assert.True(t, Filter{}.Matches(e))
+	assert.True(t, Filter{Method: "post", Path: "/content/1234", Fixture: "/content/{uuid}", Status: 201}.Matches(e))
+	assert.True(t, Filter{Headers: map[string]string{"x-request-id": "tid_1234"}, Query: map[string]string{"q": "example"}}.Matches(e))
+	assert.True(t, Filter{BodyContains: `"title":"Example"`}.Matches(e))
+	assert.True(t, Filter{Host: "Content-API:8080"}.Matches(e))
+	assert.True(t, Filter{Protocol: "H2"}.Matches(e))
+
+	assert.False(t, Filter{Method: "GET"}.Matches(e))
+	assert.False(t, Filter{Path: "/content/5678"}.Matches(e))
+	assert.False(t, Filter{Host: "people-api:8080"}.Matches(e))
+	assert.False(t, Filter{Protocol: "h2c"}.Matches(e))
+	assert.False(t, Filter{Fixture: "/content"}.Matches(e))
+	assert.False(t, Filter{Status: 200}.Matches(e))
+	assert.False(t, Filter{Headers: map[string]string{"x-request-id": "tid_5678"}}.Matches(e))
+	assert.False(t, Filter{Query: map[string]string{"q": "other"}}.Matches(e))
+	assert.False(t, Filter{BodyContains: "Another"}.Matches(e))
+}
+
+func TestFilterMatches__NoFixture(t *testing.T) {
+	e := testEntry()
+	e.Fixture = nil
+
+	assert.False(t, Filter{Fixture: "/content/{uuid}"}.Matches(e))
+	assert.True(t, Filter{Unmatched: true}.Matches(e))
+}
+
+func TestFilterMatches__Unmatched(t *testing.T) {
+	e := testEntry()
+	assert.False(t, Filter{Unmatched: true}.Matches(e))
+
+	e.Diagnostics = "no discriminators matched"
+	assert.True(t, Filter{Unmatched: true}.Matches(e))
+}
+
+func TestFilterFromQuery(t *testing.T) {
+	q, err := url.ParseQuery("method=POST&host=content-api:8080&path=/content/1234&protocol=h2&fixture=/content/{uuid}&status=201&header=X-Request-Id:tid_1234&query=q:example&bodyContains=Example&limit=5")
+	require.NoError(t, err)
+
+	f, err := FilterFromQuery(q)
+	require.NoError(t, err)
+
+	assert.Equal(t, "POST", f.Method)
+	assert.Equal(t, "content-api:8080", f.Host)
+	assert.Equal(t, "/content/1234", f.Path)
+	assert.Equal(t, "h2", f.Protocol)
+	assert.Equal(t, "/content/{uuid}", f.Fixture)
+	assert.Equal(t, 201, f.Status)
+	assert.Equal(t, map[string]string{"X-Request-Id": "tid_1234"}, f.Headers)
+	assert.Equal(t, map[string]string{"q": "example"}, f.Query)
+	assert.Equal(t, "Example", f.BodyContains)
+	assert.Equal(t, 5, f.Limit)
+	assert.True(t, f.Matches(testEntry()))
+}
+
+func TestFilterFromQuery__Errors(t *testing.T) {
+	for _, query := range []string{"status=ok", "limit=all", "header=no-value", "query=no-value", "unmatched=maybe"} {
+		q, err := url.ParseQuery(query)
+		require.NoError(t, err)
+
+		_, err = FilterFromQuery(q)
+		assert.Error(t, err, query)
+	}
+}
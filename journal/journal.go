@@ -0,0 +1,313 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLimit is the default number of requests kept in the journal
+const DefaultLimit = 1000
+
+type entryKey struct{}
+
+// Entry is a single request received by ersatz, and the response it was given
+type Entry struct {
+	ID       int64       `json:"id"`
+	Time     time.Time   `json:"time"`
+	Method   string      `json:"method"`
+	Host     string      `json:"host"`
+	Path     string      `json:"path"`
+	Protocol string      `json:"protocol"`
+	Query    url.Values  `json:"query"`
+	Headers  http.Header `json:"headers"`
+	Body     string      `json:"body"`
+	Fixture  *Fixture    `json:"fixture,omitempty"`
+	Status   int         `json:"status"`
+
+	// Proxied is the upstream the request was forwarded to, if it was proxied
+	Proxied string `json:"proxied,omitempty"`
+
+	// Fault is the type of fault which was simulated instead of a normal response
+	Fault string `json:"fault,omitempty"`
+
+	// Aborted is true if the fault could not take over the connection, so the handler was aborted instead (which resets the stream over HTTP/2)
+	Aborted bool `json:"aborted,omitempty"`
+
+	// Diagnostics explains why the request could not be matched to a fixture
+	Diagnostics interface{} `json:"diagnostics,omitempty"`
+
+	// Validation explains why the request did not satisfy the OpenAPI document, if requests are being validated
+	Validation interface{} `json:"validation,omitempty"`
+
+	// Frames are the frames sent and received over a WebSocket connection, which is journalled once it closes
+	Frames []Frame `json:"frames,omitempty"`
+}
+
+// Frame is a single WebSocket frame. Data is the text of text frames, base64 encoded binary, the payload of pings and pongs, or the reason of close frames.
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Type      string    `json:"type"`
+	Data      string    `json:"data,omitempty"`
+	Code      int       `json:"code,omitempty"`
+	// Reply is the index of the reply which matched a received message, if any did
+	Reply *int `json:"reply,omitempty"`
+}
+
+// Unmatched returns true if no fixture was found for the request path and method, or if none of the fixture's discriminators matched the request
+func (e Entry) Unmatched() bool {
+	return e.Fixture == nil || e.Diagnostics != nil
+}
+
+// Protocol returns the protocol the request was sent with: http/1.0, http/1.1, h2 (HTTP/2 over TLS) or h2c (HTTP/2 over cleartext)
+func Protocol(r *http.Request) string {
+	if r.ProtoMajor != 2 {
+		return strings.ToLower(r.Proto)
+	}
+
+	if r.TLS != nil {
+		return "h2"
+	}
+	return "h2c"
+}
+
+// Fixture identifies the fixture which was used to respond to a request
+type Fixture struct {
+	Path          string `json:"path"`
+	Method        string `json:"method"`
+	Discriminator *int   `json:"discriminator,omitempty"`
+}
+
+// Journal is an in-memory record of the most recent requests received
+type Journal struct {
+	sync.RWMutex
+	entries []Entry
+	limit   int
+	nextID  int64
+}
+
+// New creates a journal which keeps the most recent limit requests
+func New(limit int) *Journal {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Journal{entries: make([]Entry, 0), limit: limit}
+}
+
+// Record is middleware which adds every request to the journal once it has been responded to
+func (j *Journal) Record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry := &Entry{
+			Time:     time.Now(),
+			Method:   r.Method,
+			Host:     r.Host,
+			Path:     r.URL.Path,
+			Protocol: Protocol(r),
+			Query:    r.URL.Query(),
+			Headers:  r.Header,
+			Status:   http.StatusOK,
+		}
+
+		if r.Body != nil {
+			body, err := ioutil.ReadAll(r.Body)
+			if err == nil {
+				r.Body.Close()
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				entry.Body = string(body)
+			}
+		}
+
+		// deferred, so requests are still journalled if the handler is aborted, i.e. by a fault over HTTP/2
+		defer func() {
+			j.add(*entry)
+		}()
+
+		ctx := context.WithValue(r.Context(), entryKey{}, entry)
+		next.ServeHTTP(&statusRecorder{ResponseWriter: w, entry: entry}, r.WithContext(ctx))
+	})
+}
+
+func (j *Journal) add(e Entry) {
+	j.Lock()
+	defer j.Unlock()
+
+	j.nextID++
+	e.ID = j.nextID
+
+	j.entries = append(j.entries, e)
+	if len(j.entries) > j.limit {
+		j.entries = j.entries[len(j.entries)-j.limit:]
+	}
+}
+
+// Entries returns the journalled requests which match the filter, oldest first
+func (j *Journal) Entries(f Filter) []Entry {
+	j.RLock()
+	defer j.RUnlock()
+
+	matches := make([]Entry, 0)
+	for _, e := range j.entries {
+		if f.Matches(e) {
+			matches = append(matches, e)
+		}
+	}
+
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[len(matches)-f.Limit:]
+	}
+	return matches
+}
+
+// Reset removes every request from the journal
+func (j *Journal) Reset() {
+	j.Lock()
+	defer j.Unlock()
+	j.entries = make([]Entry, 0)
+}
+
+// SetFixture records which fixture is responding to the request. It does nothing if the request is not being journalled.
+func SetFixture(r *http.Request, path string, method string) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Fixture = &Fixture{Path: path, Method: method}
+}
+
+// SetDiscriminator records the index of the discriminator which matched the request
+func SetDiscriminator(r *http.Request, index int) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok || entry.Fixture == nil {
+		return
+	}
+	entry.Fixture.Discriminator = &index
+}
+
+// SetDiagnostics records why the request could not be matched to a fixture
+func SetDiagnostics(r *http.Request, diagnostics interface{}) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Diagnostics = diagnostics
+}
+
+// SetValidation records why the request did not satisfy the OpenAPI document
+func SetValidation(r *http.Request, validation interface{}) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Validation = validation
+}
+
+// SetFault records the fault which was simulated in response to the request
+func SetFault(r *http.Request, fault string) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Fault = fault
+}
+
+// SetAborted records that the handler was aborted, rather than closing the connection as the fault intended
+func SetAborted(r *http.Request) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Aborted = true
+}
+
+// SetBody replaces the journalled request body, i.e. with the JSON form of a gRPC request
+func SetBody(r *http.Request, body string) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Body = body
+}
+
+// SetStatus records the status of a response written to a hijacked connection, i.e. 101 Switching Protocols
+func SetStatus(r *http.Request, status int) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Status = status
+}
+
+// SetFrames records the frames sent and received over a WebSocket connection
+func SetFrames(r *http.Request, frames []Frame) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Frames = frames
+}
+
+// SetProxied records the upstream the request was forwarded to
+func SetProxied(r *http.Request, target string) {
+	entry, ok := r.Context().Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	entry.Proxied = target
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	entry       *Entry
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.entry.Status = status
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// CloseNotify returns a channel which never receives if the response writer does not support close notifications
+func (s *statusRecorder) CloseNotify() <-chan bool {
+	if notifier, ok := s.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return make(chan bool)
+}
+
+func (s *statusRecorder) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := s.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
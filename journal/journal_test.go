@@ -0,0 +1,191 @@
+package journal
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	j := New(0)
+	h := j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"title":"Example"}`, string(body))
+
+		SetFixture(r, "/content/{uuid}", "put")
+		SetDiscriminator(r, 1)
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	r := httptest.NewRequest("PUT", "/content/1234?q=example", strings.NewReader(`{"title":"Example"}`))
+	r.Header.Set("X-Request-Id", "tid_1234")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	entries := j.Entries(Filter{})
+	require.Len(t, entries, 1)
+
+	e := entries[0]
+	assert.Equal(t, int64(1), e.ID)
+	assert.Equal(t, "PUT", e.Method)
+	assert.Equal(t, "example.com", e.Host)
+	assert.Equal(t, "/content/1234", e.Path)
+	assert.Equal(t, "http/1.1", e.Protocol)
+	assert.Equal(t, "example", e.Query.Get("q"))
+	assert.Equal(t, "tid_1234", e.Headers.Get("X-Request-Id"))
+	assert.Equal(t, `{"title":"Example"}`, e.Body)
+	assert.Equal(t, http.StatusCreated, e.Status)
+
+	require.NotNil(t, e.Fixture)
+	assert.Equal(t, "/content/{uuid}", e.Fixture.Path)
+	assert.Equal(t, "put", e.Fixture.Method)
+	require.NotNil(t, e.Fixture.Discriminator)
+	assert.Equal(t, 1, *e.Fixture.Discriminator)
+}
+
+func TestRecord__DefaultStatus(t *testing.T) {
+	j := New(0)
+	h := j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/__gtg", nil))
+
+	entries := j.Entries(Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
+	assert.Nil(t, entries[0].Fixture)
+}
+
+func TestRecord__Limit(t *testing.T) {
+	j := New(2)
+	h := j.Record(http.NotFoundHandler())
+
+	for _, p := range []string{"/first", "/second", "/third"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+	}
+
+	entries := j.Entries(Filter{})
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/second", entries[0].Path)
+	assert.Equal(t, "/third", entries[1].Path)
+	assert.Equal(t, int64(3), entries[1].ID)
+}
+
+func TestReset(t *testing.T) {
+	j := New(0)
+	j.Record(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	require.Len(t, j.Entries(Filter{}), 1)
+
+	j.Reset()
+	assert.Empty(t, j.Entries(Filter{}))
+}
+
+func TestSetFixture__NotJournalled(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	SetFixture(r, "/", "get")
+	SetDiscriminator(r, 0)
+}
+
+func TestProtocol(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	assert.Equal(t, "http/1.1", Protocol(r))
+
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+	assert.Equal(t, "h2c", Protocol(r))
+
+	r = httptest.NewRequest("GET", "https://example.com/", nil)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/2.0", 2, 0
+	assert.Equal(t, "h2", Protocol(r))
+}
+
+func TestRecord__WithFault(t *testing.T) {
+	j := New(0)
+	server := httptest.NewServer(j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetFault(r, "emptyResponse")
+
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok)
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		conn.Close()
+	})))
+	defer server.Close()
+
+	_, err := http.Get(server.URL)
+	assert.Error(t, err)
+
+	entries := j.Entries(Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "emptyResponse", entries[0].Fault)
+}
+
+type pushWriter struct {
+	*httptest.ResponseRecorder
+	pushed []string
+	closed chan bool
+}
+
+func (w *pushWriter) Push(target string, opts *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func (w *pushWriter) CloseNotify() <-chan bool {
+	return w.closed
+}
+
+func TestRecord__OptionalInterfaces(t *testing.T) {
+	j := New(0)
+	handler := j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, w.(http.Pusher).Push("/style.css", nil))
+		assert.True(t, <-w.(http.CloseNotifier).CloseNotify())
+	}))
+
+	w := &pushWriter{ResponseRecorder: httptest.NewRecorder(), closed: make(chan bool, 1)}
+	w.closed <- true
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, []string{"/style.css"}, w.pushed)
+
+	handler = j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.ErrNotSupported, w.(http.Pusher).Push("/style.css", nil))
+		assert.NotNil(t, w.(http.CloseNotifier).CloseNotify())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestSetBody(t *testing.T) {
+	j := New(0)
+	handler := j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetBody(r, `{"uuid":"1234"}`)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/content.ContentService/GetContent", strings.NewReader("\x00\x00\x00\x00\x06\n\x041234")))
+
+	entries := j.Entries(Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, `{"uuid":"1234"}`, entries[0].Body)
+}
+
+func TestSetStatusAndFrames(t *testing.T) {
+	j := New(0)
+	handler := j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetStatus(r, http.StatusSwitchingProtocols)
+		SetFrames(r, []Frame{{Direction: "sent", Type: "text", Data: "hello"}, {Direction: "received", Type: "close", Code: 1000}})
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/notifications", nil))
+
+	entries := j.Entries(Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, http.StatusSwitchingProtocols, entries[0].Status)
+	require.Len(t, entries[0].Frames, 2)
+	assert.Equal(t, "hello", entries[0].Frames[0].Data)
+	assert.Equal(t, 1000, entries[0].Frames[1].Code)
+}
@@ -0,0 +1,72 @@
+package journal
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Verification asserts how many journalled requests match the filter
+type Verification struct {
+	Filter  Filter `json:"filter"`
+	Count   *int   `json:"count,omitempty"`
+	AtLeast *int   `json:"atLeast,omitempty"`
+	AtMost  *int   `json:"atMost,omitempty"`
+}
+
+// Result is the outcome of a Verification
+type Result struct {
+	OK       bool    `json:"ok"`
+	Message  string  `json:"message"`
+	Actual   int     `json:"actual"`
+	Requests []Entry `json:"requests"`
+}
+
+// VerificationFromQuery creates a verification from query parameters, using the same parameters as FilterFromQuery plus count, atLeast and atMost. If none of count, atLeast or atMost are provided, at least one matching request is expected.
+func VerificationFromQuery(q url.Values) (Verification, error) {
+	f, err := FilterFromQuery(q)
+	if err != nil {
+		return Verification{}, err
+	}
+	f.Limit = 0
+
+	v := Verification{Filter: f}
+	for name, target := range map[string]**int{"count": &v.Count, "atLeast": &v.AtLeast, "atMost": &v.AtMost} {
+		if q.Get(name) == "" {
+			continue
+		}
+
+		i, err := optionalInt(q, name)
+		if err != nil {
+			return v, err
+		}
+		*target = &i
+	}
+
+	if v.Count == nil && v.AtLeast == nil && v.AtMost == nil {
+		one := 1
+		v.AtLeast = &one
+	}
+	return v, nil
+}
+
+// Verify checks the journal against the verification
+func (j *Journal) Verify(v Verification) Result {
+	requests := j.Entries(v.Filter)
+	actual := len(requests)
+
+	res := Result{OK: true, Actual: actual, Requests: requests}
+	switch {
+	case v.Count != nil && actual != *v.Count:
+		res.OK = false
+		res.Message = fmt.Sprintf("expected exactly %v matching requests, but found %v", *v.Count, actual)
+	case v.AtLeast != nil && actual < *v.AtLeast:
+		res.OK = false
+		res.Message = fmt.Sprintf("expected at least %v matching requests, but found %v", *v.AtLeast, actual)
+	case v.AtMost != nil && actual > *v.AtMost:
+		res.OK = false
+		res.Message = fmt.Sprintf("expected at most %v matching requests, but found %v", *v.AtMost, actual)
+	default:
+		res.Message = fmt.Sprintf("found %v matching requests", actual)
+	}
+	return res
+}
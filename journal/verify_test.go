@@ -0,0 +1,79 @@
+package journal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func journalWith(paths ...string) *Journal {
+	j := New(0)
+	h := j.Record(http.NotFoundHandler())
+	for _, p := range paths {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", p, nil))
+	}
+	return j
+}
+
+func verification(t *testing.T, query string) Verification {
+	q, err := url.ParseQuery(query)
+	require.NoError(t, err)
+
+	v, err := VerificationFromQuery(q)
+	require.NoError(t, err)
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	j := journalWith("/first", "/first", "/second")
+
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{"path=/first", true},
+		{"path=/third", false},
+		{"path=/first&count=2", true},
+		{"path=/first&count=1", false},
+		{"path=/first&atLeast=3", false},
+		{"path=/first&atMost=2", true},
+		{"path=/first&atMost=1", false},
+		{"path=/third&atMost=0", true},
+		{"atLeast=2&atMost=3", true},
+	}
+
+	for _, test := range tests {
+		res := j.Verify(verification(t, test.query))
+		assert.Equal(t, test.ok, res.OK, test.query)
+		assert.NotEmpty(t, res.Message)
+	}
+}
+
+func TestVerify__ReturnsMatchingRequests(t *testing.T) {
+	j := journalWith("/first", "/second")
+
+	res := j.Verify(verification(t, "path=/second&count=2"))
+	assert.False(t, res.OK)
+	assert.Equal(t, 1, res.Actual)
+	require.Len(t, res.Requests, 1)
+	assert.Equal(t, "/second", res.Requests[0].Path)
+}
+
+func TestVerificationFromQuery__IgnoresLimit(t *testing.T) {
+	v := verification(t, "limit=1")
+	assert.Equal(t, 0, v.Filter.Limit)
+}
+
+func TestVerificationFromQuery__Errors(t *testing.T) {
+	for _, query := range []string{"count=two", "atLeast=one", "status=ok"} {
+		q, err := url.ParseQuery(query)
+		require.NoError(t, err)
+
+		_, err = VerificationFromQuery(q)
+		assert.Error(t, err, query)
+	}
+}
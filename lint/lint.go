@@ -0,0 +1,364 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v1"
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// Versions are the fixtures versions supported by ersatz
+var Versions = []string{"1.0.0", "2.0.0"}
+
+// yamlErrorLine finds the line number in errors returned by the yaml parser, i.e. yaml: line 3: mapping values are not allowed in this context
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// Problem is a single mistake found in a fixtures file
+type Problem struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("line %v: %v", p.Line, p.Message)
+}
+
+// linter collects the problems found in a single fixtures file
+type linter struct {
+	positions positions
+	problems  []Problem
+}
+
+func (l *linter) report(keys []string, format string, args ...interface{}) {
+	l.problems = append(l.problems, Problem{Line: l.positions.line(keys...), Message: fmt.Sprintf(format, args...)})
+}
+
+// invalid reports a fixture which failed to load. If it failed because of its body, the body is reported instead.
+func (l *linter) invalid(keys []string, format string, err error) {
+	if err == v2.ErrTextBody {
+		for _, body := range [][]string{{"body"}, {"response", "body"}} {
+			bodyKeys := append(append([]string{}, keys...), body...)
+			if _, ok := l.positions[key(bodyKeys)]; ok {
+				keys = bodyKeys
+				break
+			}
+		}
+	}
+	l.report(keys, format, err)
+}
+
+// Lint checks the fixtures file for mistakes which would stop ersatz from starting, or cause it to respond unexpectedly, without starting the server. Problems are ordered by line.
+func Lint(yml []byte) []Problem {
+	p, problems := index(yml)
+	l := &linter{positions: p, problems: problems}
+
+	doc, err := yaml.YAMLToJSON(yml)
+	if err != nil {
+		line := 1
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		return append(l.problems, Problem{Line: line, Message: err.Error()})
+	}
+
+	file := struct {
+		Version  interface{}                           `json:"version"`
+		Proxy    json.RawMessage                       `json:"proxy"`
+		Fixtures map[string]map[string]json.RawMessage `json:"fixtures"`
+		Hosts    map[string]struct {
+			Fixtures map[string]map[string]json.RawMessage `json:"fixtures"`
+		} `json:"hosts"`
+		GRPC       json.RawMessage            `json:"grpc"`
+		WebSockets map[string]json.RawMessage `json:"websockets"`
+	}{}
+
+	if err := json.Unmarshal(doc, &file); err != nil {
+		l.report(nil, "expected a version and a map of fixture paths to methods: %v", err)
+		return l.sorted()
+	}
+
+	version := fmt.Sprintf("%v", file.Version)
+	switch {
+	case file.Version == nil:
+		l.report(nil, "missing version, expected one of %v", strings.Join(Versions, ", "))
+		return l.sorted()
+	case !v2.Contains(version, Versions...):
+		l.report([]string{"version"}, "unsupported version '%v', expected one of %v", version, strings.Join(Versions, ", "))
+		return l.sorted()
+	}
+
+	if len(file.Proxy) > 0 && string(file.Proxy) != "null" && version != "2.0.0" {
+		l.report([]string{"proxy"}, "proxy is only supported by 2.0.0 fixtures")
+	}
+
+	if len(file.Hosts) > 0 && version != "2.0.0" {
+		l.report([]string{"hosts"}, "hosts are only supported by 2.0.0 fixtures")
+	}
+
+	if len(file.GRPC) > 0 && string(file.GRPC) != "null" && version != "2.0.0" {
+		l.report([]string{"grpc"}, "grpc is only supported by 2.0.0 fixtures")
+	}
+
+	if len(file.WebSockets) > 0 && version != "2.0.0" {
+		l.report([]string{"websockets"}, "websockets are only supported by 2.0.0 fixtures")
+	}
+
+	l.fixtures([]string{"fixtures"}, file.Fixtures, version)
+	for host, h := range file.Hosts {
+		l.fixtures([]string{"hosts", host, "fixtures"}, h.Fixtures, version)
+	}
+	return l.sorted()
+}
+
+// fixtures checks every fixture declared under the keys, i.e. the top level fixtures, or those of a virtual host
+func (l *linter) fixtures(keys []string, fixtures map[string]map[string]json.RawMessage, version string) {
+	l.duplicatePaths(keys, fixtures, version)
+
+	for _, path := range l.ordered(keys, fixtures) {
+		methods := fixtures[path]
+		for _, method := range l.orderedMethods(append(append([]string{}, keys...), path), methods) {
+			resourceKeys := append(append([]string{}, keys...), path, method)
+			if version == "1.0.0" {
+				l.v1Resource(resourceKeys, methods[method])
+			} else {
+				l.v2Resource(resourceKeys, methods[method])
+			}
+		}
+	}
+}
+
+func (l *linter) sorted() []Problem {
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		return l.problems[i].Line < l.problems[j].Line
+	})
+	return l.problems
+}
+
+// ordered returns the fixture paths in the order they were declared
+func (l *linter) ordered(keys []string, fixtures map[string]map[string]json.RawMessage) []string {
+	paths := make([]string, 0, len(fixtures))
+	for p := range fixtures {
+		paths = append(paths, p)
+	}
+
+	sort.Slice(paths, func(i, j int) bool {
+		return l.positions.line(append(keys, paths[i])...) < l.positions.line(append(keys, paths[j])...)
+	})
+	return paths
+}
+
+func (l *linter) orderedMethods(keys []string, methods map[string]json.RawMessage) []string {
+	ordered := make([]string, 0, len(methods))
+	for m := range methods {
+		ordered = append(ordered, m)
+	}
+
+	sort.Slice(ordered, func(i, j int) bool {
+		return l.positions.line(append(keys, ordered[i])...) < l.positions.line(append(keys, ordered[j])...)
+	})
+	return ordered
+}
+
+// duplicatePaths reports fixture paths which are registered with the router as the same route, i.e. /content/{uuid} and /content/:id
+func (l *linter) duplicatePaths(keys []string, fixtures map[string]map[string]json.RawMessage, version string) {
+	seen := make(map[string]string)
+	for _, p := range l.ordered(keys, fixtures) {
+		route := p
+		if version == "2.0.0" {
+			route = normaliseRoute(p)
+		}
+
+		if previous, ok := seen[route]; ok {
+			l.report(append(keys, p), "duplicate path '%v', which is the same route as '%v' on line %v", p, previous, l.positions.line(append(keys, previous)...))
+			continue
+		}
+		seen[route] = p
+	}
+}
+
+// normaliseRoute removes the names of path parameters, as the router cannot tell routes apart by their parameter names
+func normaliseRoute(path string) string {
+	parts := strings.Split(v2.ParseRoute(path).String(), "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") {
+			parts[i] = ":"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (l *linter) v1Resource(keys []string, raw json.RawMessage) {
+	if method := keys[len(keys)-1]; !v2.ValidMethod(method) {
+		l.report(keys, "invalid method '%v'", method)
+		return
+	}
+
+	res := v1.Resource{}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		l.report(keys, "invalid fixture: %v", err)
+		return
+	}
+
+	l.status(keys, res.Status, true)
+	l.body(keys, res.Headers, res.Body, false)
+}
+
+func (l *linter) v2Resource(keys []string, raw json.RawMessage) {
+	if method := keys[len(keys)-1]; !v2.ValidMethod(method) {
+		l.report(keys, "invalid method '%v'", method)
+		return
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		res := v2.Resource{}
+		if err := json.Unmarshal(raw, &res); err != nil {
+			l.invalid(keys, "invalid fixture: %v", err)
+			return
+		}
+
+		if res.Sequence.IsEmpty() {
+			l.response(keys, res.Response)
+		}
+		l.sequence(append(keys, "sequence"), res.Sequence)
+		return
+	}
+
+	items := make([]json.RawMessage, 0)
+	if err := json.Unmarshal(raw, &items); err != nil {
+		l.report(keys, "invalid fixture: %v", err)
+		return
+	}
+
+	parsed := make([]v2.Discriminator, 0, len(items))
+	indexes := make([]int, 0, len(items))
+	for i, item := range items {
+		d := v2.Discriminator{}
+		dk := append(append([]string{}, keys...), strconv.Itoa(i))
+		if err := json.Unmarshal(item, &d); err != nil {
+			l.invalid(dk, "invalid discriminator: %v", err)
+			continue
+		}
+
+		for j, earlier := range parsed {
+			if shadows(earlier, d) {
+				l.report(dk, "unreachable discriminator, every request it matches is matched by discriminator %v on line %v", indexes[j], l.positions.line(append(append([]string{}, keys...), strconv.Itoa(indexes[j]))...))
+				break
+			}
+		}
+		parsed = append(parsed, d)
+		indexes = append(indexes, i)
+
+		if d.Sequence.IsEmpty() {
+			l.response(append(dk, "response"), d.Response)
+		}
+		l.sequence(append(dk, "sequence"), d.Sequence)
+	}
+}
+
+func (l *linter) sequence(keys []string, seq v2.Sequence) {
+	for i, res := range seq.Responses {
+		l.response(append(append([]string{}, keys...), strconv.Itoa(i)), res)
+	}
+}
+
+func (l *linter) response(keys []string, res v2.Response) {
+	keys = append([]string{}, keys...)
+	if res.Proxy != nil {
+		return
+	}
+
+	l.status(keys, res.Status, !res.Template)
+	l.body(keys, res.Headers, res.Body, res.Template)
+
+	if err := res.CheckTemplates(); err != nil {
+		l.report(keys, "%v", err)
+	}
+}
+
+// status reports status codes outside of the range http clients accept. An unset status is only reported if it was declared, as it may be rendered from a template.
+func (l *linter) status(keys []string, status int, declared bool) {
+	statusKeys := append(append([]string{}, keys...), "status")
+	_, present := l.positions[key(statusKeys)]
+	if status == 0 && !(declared && present) {
+		return
+	}
+
+	if status < 100 || status > 599 {
+		l.report(statusKeys, "invalid status code %v, expected a number between 100 and 599", status)
+	}
+}
+
+// body reports bodies which cannot be written using the content-type header, and templates in responses which are not templated
+func (l *linter) body(keys []string, headers map[string]string, body interface{}, templated bool) {
+	bodyKeys := append(append([]string{}, keys...), "body")
+
+	contentType, ok := headers["content-type"]
+	if !ok {
+		contentType = "application/json"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		l.report(append(append([]string{}, keys...), "headers", "content-type"), "invalid content-type '%v': %v", contentType, err)
+		return
+	}
+
+	if _, isText := body.(string); body != nil && !isText {
+		switch mediaType {
+		case "application/json", "application/x-yaml":
+		case "text/plain":
+			l.report(bodyKeys, "the body must be a string to be sent as text/plain")
+		default:
+			l.report(bodyKeys, "the body must be a string to be sent as %v, otherwise it is not sent at all", mediaType)
+		}
+	}
+
+	for k := range headers {
+		if k != "content-type" && strings.EqualFold(k, "content-type") {
+			l.report(append(append([]string{}, keys...), "headers", k), "the '%v' header must be lower case (content-type) to be used to serialise the body", k)
+		}
+	}
+
+	if !templated && containsTemplate(headers, body) {
+		l.report(keys, "the response contains a template ({{ ... }}), but will not be rendered unless 'template: true' is set")
+	}
+}
+
+func containsTemplate(headers map[string]string, body interface{}) bool {
+	for _, v := range headers {
+		if isTemplate(v) {
+			return true
+		}
+	}
+
+	switch val := body.(type) {
+	case string:
+		return isTemplate(val)
+	case map[string]interface{}:
+		for _, child := range val {
+			if containsTemplate(nil, child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			if containsTemplate(nil, child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTemplate(v string) bool {
+	i := strings.Index(v, "{{")
+	return i != -1 && strings.Contains(v[i:], "}}")
+}
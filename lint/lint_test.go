@@ -0,0 +1,196 @@
+package lint
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint__Examples(t *testing.T) {
+	for _, f := range []string{"../_examples/v1-example.yml", "../_examples/v2-example.yml"} {
+		yml, err := ioutil.ReadFile(f)
+		require.NoError(t, err)
+		assert.Empty(t, Lint(yml), f)
+	}
+}
+
+const lintTestYAML = `version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+      headers:
+        content-type: text/plain
+      body:
+        ok: true
+    "ge t":
+      status: 200
+  /content/{uuid}:
+    get:
+      - when:
+          headers:
+            X-Policy: ${exists}
+        response:
+          status: 200
+      - when:
+          headers:
+            X-Policy: INTERNAL
+        response:
+          status: 0
+      - when:
+          headers:
+            X-Policy: ${unknown}
+        response:
+          status: 200
+    put:
+      template: true
+      status: 200
+      body:
+        id: '{{ .Request.Uuid }}'
+    post:
+      status: 1000
+      body:
+        id: '{{ .Request.PathParam "uuid" }}'
+  /content/:id:
+    get:
+      status: 200
+      headers:
+        content-type: application/xml
+        Content-Type: application/xml
+      body:
+        - a
+`
+
+func TestLint(t *testing.T) {
+	problems := Lint([]byte(lintTestYAML))
+
+	lines := make([]int, 0)
+	for _, p := range problems {
+		lines = append(lines, p.Line)
+	}
+	assert.Equal(t, []int{8, 10, 19, 23, 24, 29, 34, 35, 38, 43, 44}, lines, "%v", problems)
+
+	assert.Contains(t, problems[0].Message, "text/plain")
+	assert.Contains(t, problems[1].Message, "invalid method 'ge t'")
+	assert.Contains(t, problems[2].Message, "unreachable discriminator")
+	assert.Contains(t, problems[2].Message, "line 14")
+	assert.Contains(t, problems[3].Message, "invalid status code 0")
+	assert.Contains(t, problems[4].Message, "${unknown}")
+	assert.Contains(t, problems[5].Message, "Uuid")
+	assert.Contains(t, problems[6].Message, "template: true")
+	assert.Contains(t, problems[7].Message, "invalid status code 1000")
+	assert.Contains(t, problems[8].Message, "duplicate path '/content/:id'")
+	assert.Contains(t, problems[9].Message, "'Content-Type' header must be lower case")
+	assert.Contains(t, problems[10].Message, "application/xml")
+}
+
+func TestLint__UnsupportedVersion(t *testing.T) {
+	problems := Lint([]byte("# fixtures\nversion: 3.0.0\nfixtures: {}\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, Problem{Line: 2, Message: "unsupported version '3.0.0', expected one of 1.0.0, 2.0.0"}, problems[0])
+
+	problems = Lint([]byte("fixtures: {}\n"))
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "missing version")
+}
+
+func TestLint__InvalidYAML(t *testing.T) {
+	problems := Lint([]byte("version: 2.0.0\nfixtures:\n  /a:\n    get: status: 200\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, 4, problems[0].Line)
+}
+
+func TestLint__V1(t *testing.T) {
+	problems := Lint([]byte(`version: 1.0.0
+proxy: http://localhost:8080
+fixtures:
+  /__health:
+    get:
+      status: 200
+    "ge t":
+      status: 200
+  /__gtg:
+    get:
+      status: 0
+`))
+
+	require.Len(t, problems, 3)
+	assert.Equal(t, 2, problems[0].Line)
+	assert.Equal(t, 7, problems[1].Line)
+	assert.Equal(t, 11, problems[2].Line)
+}
+
+func TestLint__Sequence(t *testing.T) {
+	problems := Lint([]byte(`version: 2.0.0
+fixtures:
+  /jobs:
+    get:
+      sequence:
+        - status: 202
+        - status: 99
+`))
+
+	require.Len(t, problems, 1)
+	assert.Equal(t, 7, problems[0].Line)
+}
+
+func TestLint__Hosts(t *testing.T) {
+	problems := Lint([]byte(`version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+hosts:
+  content-api:
+    fixtures:
+      /__health:
+        get:
+          status: 1000
+      /content/{uuid}:
+        get:
+          status: 200
+      /content/:id:
+        get:
+          status: 200
+`))
+
+	require.Len(t, problems, 2)
+	assert.Equal(t, Problem{Line: 11, Message: "invalid status code 1000, expected a number between 100 and 599"}, problems[0])
+	assert.Equal(t, Problem{Line: 15, Message: "duplicate path '/content/:id', which is the same route as '/content/{uuid}' on line 12"}, problems[1])
+
+	problems = Lint([]byte("version: 1.0.0\nfixtures: {}\nhosts:\n  content-api:\n    fixtures: {}\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, Problem{Line: 3, Message: "hosts are only supported by 2.0.0 fixtures"}, problems[0])
+
+	problems = Lint([]byte("version: 1.0.0\nfixtures: {}\ngrpc:\n  protos: [content.proto]\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, Problem{Line: 3, Message: "grpc is only supported by 2.0.0 fixtures"}, problems[0])
+
+	problems = Lint([]byte("version: 1.0.0\nfixtures: {}\nwebsockets:\n  /notifications: {}\n"))
+	require.Len(t, problems, 1)
+	assert.Equal(t, Problem{Line: 3, Message: "websockets are only supported by 2.0.0 fixtures"}, problems[0])
+}
+
+func TestProblemString(t *testing.T) {
+	assert.Equal(t, "line 3: oops", Problem{Line: 3, Message: "oops"}.String())
+}
+
+func TestLint__TextBodyInDiscriminator(t *testing.T) {
+	problems := Lint([]byte(`version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      - response:
+          status: 200
+          headers:
+            content-type: text/plain
+          body:
+            ok: true
+`))
+
+	require.Len(t, problems, 1)
+	assert.Equal(t, 9, problems[0].Line)
+	assert.Contains(t, problems[0].Message, "text/plain")
+}
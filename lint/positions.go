@@ -0,0 +1,174 @@
+package lint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// positions maps the location of every key and sequence item in a yaml document to the line it was declared on
+type positions map[string]int
+
+// container is a mapping or sequence whose items start at the given column
+type container struct {
+	indent int
+	keys   []string
+	seq    bool
+	next   int
+}
+
+// index finds the line of every key and sequence item in the block style yaml document, and reports keys which are declared more than once in the same mapping. Flow style collections and multi-line scalars are treated as values.
+func index(yml []byte) (positions, []Problem) {
+	p := make(positions)
+	problems := make([]Problem, 0)
+
+	stack := []*container{{indent: 0}}
+	var pending []string // the key or item whose value starts on the next line
+	pendingIndent := -1
+	blockIndent := -1 // skips the lines of a | or > block scalar
+
+	for i, raw := range strings.Split(string(yml), "\n") {
+		line := i + 1
+		text := strings.TrimRight(raw, " \t\r")
+		content := strings.TrimLeft(text, " ")
+		indent := len(text) - len(content)
+
+		if content == "" || strings.HasPrefix(content, "#") || content == "---" || content == "..." {
+			continue
+		}
+
+		if blockIndent != -1 {
+			if indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		if pending != nil && (indent > pendingIndent || (indent == pendingIndent && isItem(content))) {
+			stack = append(stack, &container{indent: indent, keys: pending, seq: isItem(content)})
+		}
+		pending = nil
+
+		for len(stack) > 1 && stack[len(stack)-1].closedBy(indent, content) {
+			stack = stack[:len(stack)-1]
+		}
+		top := stack[len(stack)-1]
+
+		for isItem(content) && top.seq {
+			item := append(append([]string{}, top.keys...), strconv.Itoa(top.next))
+			top.next++
+			p[key(item)] = line
+
+			rest := strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+			if rest == "" || strings.HasPrefix(rest, "#") {
+				pending, pendingIndent = item, indent
+				content = ""
+				break
+			}
+
+			if _, _, ok := splitKey(rest); !ok {
+				content = ""
+				break
+			}
+
+			indent += len(content) - len(rest)
+			top = &container{indent: indent, keys: item, seq: isItem(rest)}
+			stack = append(stack, top)
+			content = rest
+		}
+
+		if content == "" || top.seq {
+			continue
+		}
+
+		name, value, ok := splitKey(content)
+		if !ok {
+			continue
+		}
+
+		keys := append(append([]string{}, top.keys...), name)
+		if previous, ok := p[key(keys)]; ok {
+			problems = append(problems, Problem{Line: line, Message: fmt.Sprintf("duplicate key '%v', which is already declared on line %v", name, previous)})
+			keys[len(keys)-1] = fmt.Sprintf("%v\x00duplicate on line %v", name, line) // so the keys beneath it are not also reported
+		}
+		p[key(keys)] = line
+
+		switch {
+		case value == "":
+			pending, pendingIndent = keys, indent
+		case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+			blockIndent = indent
+		}
+	}
+	return p, problems
+}
+
+// closedBy returns true if the line is outside the container, i.e. it is less indented, or it is a mapping entry at the same indent as a sequence
+func (c *container) closedBy(indent int, content string) bool {
+	return c.indent > indent || (c.indent == indent && c.seq && !isItem(content))
+}
+
+// line returns the line the location was declared on, or the line of its closest parent if it was not found
+func (p positions) line(keys ...string) int {
+	for i := len(keys); i > 0; i-- {
+		if line, ok := p[key(keys[:i])]; ok {
+			return line
+		}
+	}
+	return 1
+}
+
+func key(keys []string) string {
+	return strings.Join(keys, "\x00")
+}
+
+func isItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ")
+}
+
+// splitKey splits a mapping entry into its (possibly quoted) key and its value, without any trailing comment
+func splitKey(content string) (string, string, bool) {
+	var name, rest string
+	switch content[0] {
+	case '"', '\'':
+		end := strings.IndexByte(content[1:], content[0])
+		if end == -1 {
+			return "", "", false
+		}
+		name, rest = content[1:end+1], content[end+2:]
+		if content[0] == '"' {
+			if unquoted, err := strconv.Unquote(content[:end+2]); err == nil {
+				name = unquoted
+			}
+		}
+
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		rest = rest[1:]
+	case '{', '[':
+		return "", "", false
+	default:
+		i := strings.Index(content, ": ")
+		if i == -1 {
+			if !strings.HasSuffix(content, ":") {
+				return "", "", false
+			}
+			i = len(content) - 1
+		}
+		name, rest = strings.TrimSpace(content[:i]), content[i+1:]
+	}
+
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", "", false
+	}
+
+	value := strings.TrimSpace(rest)
+	if strings.HasPrefix(value, "#") {
+		value = ""
+	}
+	if i := strings.Index(value, " #"); i != -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return name, value, true
+}
@@ -0,0 +1,54 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const positionsTestYAML = `# comment
+version: 2.0.0
+fixtures:
+  "/quoted":
+    get:
+    - when:
+        headers:
+          X-Id: a # trailing comment
+      response:
+        body: |
+          not: a key
+    -
+      response:
+        status: 200
+  /content:
+    post:
+      status: 201
+      body:
+        items:
+          - id: 1
+            name: first
+          - id: 2
+`
+
+func TestIndex(t *testing.T) {
+	p, problems := index([]byte(positionsTestYAML))
+	assert.Empty(t, problems)
+
+	assert.Equal(t, 2, p.line("version"))
+	assert.Equal(t, 4, p.line("fixtures", "/quoted"))
+	assert.Equal(t, 6, p.line("fixtures", "/quoted", "get", "0"))
+	assert.Equal(t, 8, p.line("fixtures", "/quoted", "get", "0", "when", "headers", "X-Id"))
+	assert.Equal(t, 10, p.line("fixtures", "/quoted", "get", "0", "response", "body"))
+	assert.Equal(t, 10, p.line("fixtures", "/quoted", "get", "0", "response", "body", "not"))
+	assert.Equal(t, 14, p.line("fixtures", "/quoted", "get", "1", "response", "status"))
+	assert.Equal(t, 15, p.line("fixtures", "/content"))
+	assert.Equal(t, 20, p.line("fixtures", "/content", "post", "body", "items", "0", "id"))
+	assert.Equal(t, 21, p.line("fixtures", "/content", "post", "body", "items", "0", "name"))
+	assert.Equal(t, 22, p.line("fixtures", "/content", "post", "body", "items", "1"))
+	assert.Equal(t, 1, p.line("missing"))
+}
+
+func TestIndex__DuplicateKeys(t *testing.T) {
+	_, problems := index([]byte("fixtures:\n  /a:\n    get:\n      status: 200\n  /a:\n    get:\n      status: 201\n"))
+	assert.Equal(t, []Problem{{Line: 5, Message: "duplicate key '/a', which is already declared on line 2"}}, problems)
+}
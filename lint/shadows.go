@@ -0,0 +1,64 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// shadows returns true if every request which satisfies b also satisfies a, so b can never be reached when a is configured before it. Discriminators with a required state only shadow those which require the same state.
+func shadows(a v2.Discriminator, b v2.Discriminator) bool {
+	if a.RequiredState != "" && (a.RequiredState != b.RequiredState || a.ScenarioName() != b.ScenarioName()) {
+		return false
+	}
+
+	w, other := a.When, b.When
+	return covers(w.Headers.MIMEHeader, w.Headers.TemplatedValues, w.Headers.Expressions, other.Headers.MIMEHeader, other.Headers.Expressions) &&
+		covers(w.QueryParams.Values, w.QueryParams.TemplatedValues, w.QueryParams.Expressions, other.QueryParams.Values, other.QueryParams.Expressions) &&
+		covers(w.PathParams.Values, w.PathParams.TemplatedValues, w.PathParams.Expressions, other.PathParams.Values, other.PathParams.Expressions) &&
+		covers(w.Body.JSON.Values, w.Body.JSON.TemplatedValues, w.Body.JSON.Expressions, other.Body.JSON.Values, other.Body.JSON.Expressions) &&
+		covers(w.Body.Form.Values, w.Body.Form.TemplatedValues, w.Body.Form.Expressions, other.Body.Form.Values, other.Body.Form.Expressions) &&
+		coversText(w.Body.Text, other.Body.Text) &&
+		covers(w.ClientCert.Values, w.ClientCert.TemplatedValues, w.ClientCert.Expressions, other.ClientCert.Values, other.ClientCert.Expressions) &&
+		covers(w.Protocol.Values, w.Protocol.TemplatedValues, w.Protocol.Expressions, other.Protocol.Values, other.Protocol.Expressions)
+}
+
+// covers returns true if every expected value is also expected by the other discriminator, and every templated value is either satisfied by the other's expected value, or uses the same expression
+func covers(exact map[string][]string, templated v2.TemplatedValues, expressions v2.Expressions, otherExact map[string][]string, otherExpressions v2.Expressions) bool {
+	for k, v := range exact {
+		if len(v) == 0 {
+			continue
+		}
+
+		if other := otherExact[k]; len(other) == 0 || other[0] != v[0] {
+			return false
+		}
+	}
+
+	for k, fn := range templated {
+		if other := otherExact[k]; len(other) > 0 && fn(other[0]) {
+			continue
+		}
+
+		if expr, ok := otherExpressions[k]; ok && expr == expressions[k] {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func coversText(t v2.Text, other v2.Text) bool {
+	if t.Equals != "" && t.Equals != other.Equals {
+		return false
+	}
+
+	if t.Contains != "" && !strings.Contains(other.Equals, t.Contains) && !strings.Contains(other.Contains, t.Contains) {
+		return false
+	}
+
+	if t.Regex != nil && !(other.Regex != nil && other.Regex.String() == t.Regex.String()) && !(other.Equals != "" && t.Regex.MatchString(other.Equals)) {
+		return false
+	}
+	return covers(nil, t.TemplatedValues, t.Expressions, nil, other.Expressions)
+}
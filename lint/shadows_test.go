@@ -0,0 +1,73 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func discriminator(t *testing.T, yml string) v2.Discriminator {
+	d := v2.Discriminator{}
+	require.NoError(t, yaml.Unmarshal([]byte(yml), &d))
+	return d
+}
+
+func TestShadows(t *testing.T) {
+	catchAll := discriminator(t, `{response: {status: 200}}`)
+	exists := discriminator(t, `{when: {headers: {X-Policy: '${exists}'}}}`)
+	internal := discriminator(t, `{when: {headers: {x-policy: INTERNAL}, queryParams: {q: a}}}`)
+	external := discriminator(t, `{when: {headers: {X-Policy: EXTERNAL}}}`)
+
+	assert.True(t, shadows(catchAll, exists))
+	assert.True(t, shadows(exists, internal))
+	assert.True(t, shadows(exists, exists))
+	assert.False(t, shadows(internal, exists))
+	assert.False(t, shadows(internal, external))
+	assert.False(t, shadows(external, internal))
+}
+
+func TestShadows__Body(t *testing.T) {
+	contains := discriminator(t, `{when: {body: {text: {contains: world}}}}`)
+	equals := discriminator(t, `{when: {body: {text: {equals: hello world}}}}`)
+	regex := discriminator(t, `{when: {body: {text: {regex: '^hello'}}}}`)
+	json := discriminator(t, `{when: {body: {json: {$.a: '${oneOf:x,y}'}}}}`)
+	jsonExact := discriminator(t, `{when: {body: {json: {$.a: x, $.b: 1}}}}`)
+
+	assert.True(t, shadows(contains, equals))
+	assert.True(t, shadows(regex, equals))
+	assert.False(t, shadows(equals, contains))
+	assert.True(t, shadows(json, jsonExact))
+	assert.False(t, shadows(jsonExact, json))
+}
+
+func TestShadows__ClientCert(t *testing.T) {
+	exists := discriminator(t, `{when: {clientCert: {commonName: '${exists}'}}}`)
+	content := discriminator(t, `{when: {clientCert: {commonName: content-api}}}`)
+	people := discriminator(t, `{when: {clientCert: {commonName: people-api}}}`)
+
+	assert.True(t, shadows(exists, content))
+	assert.False(t, shadows(content, exists))
+	assert.False(t, shadows(content, people))
+}
+
+func TestShadows__Protocol(t *testing.T) {
+	http2 := discriminator(t, `{when: {protocol: '${oneOf:h2,h2c}'}}`)
+	h2c := discriminator(t, `{when: {protocol: h2c}}`)
+
+	assert.True(t, shadows(http2, h2c))
+	assert.False(t, shadows(h2c, http2))
+}
+
+func TestShadows__RequiredState(t *testing.T) {
+	published := discriminator(t, `{requiredState: Published}`)
+	alsoPublished := discriminator(t, `{requiredState: Published, when: {headers: {X-Id: a}}}`)
+	other := discriminator(t, `{scenario: other, requiredState: Published}`)
+
+	assert.True(t, shadows(published, alsoPublished))
+	assert.False(t, shadows(published, other))
+	assert.False(t, shadows(published, discriminator(t, `{}`)))
+	assert.True(t, shadows(discriminator(t, `{}`), published))
+}
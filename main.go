@@ -1,22 +1,24 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
-	"sync"
+	"time"
 
-	"github.com/Financial-Times/http-handlers-go/httphandlers"
-	"github.com/ghodss/yaml"
-	"github.com/husobee/vestigo"
 	"github.com/jawher/mow.cli"
-	"github.com/peteclark-ft/ersatz/v1"
-	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/peteclark-ft/ersatz/diff"
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/peteclark-ft/ersatz/lint"
+	"github.com/peteclark-ft/ersatz/openapi"
+	"github.com/peteclark-ft/ersatz/record"
+	"github.com/peteclark-ft/ersatz/server"
 	log "github.com/sirupsen/logrus"
 )
 
-var configureOnce = &sync.Once{}
-
 func main() {
 	log.SetFormatter(&log.JSONFormatter{})
 	log.SetLevel(log.InfoLevel)
@@ -29,85 +31,264 @@ func main() {
 		EnvVar: "PORT",
 	})
 
-	fixtures := app.String(cli.StringOpt{
+	fixtures := app.Strings(cli.StringsOpt{
 		Name:   "fixtures f",
-		Value:  "./_ft/ersatz-fixtures.yml",
-		Desc:   "Fixtures file to use to simulate requests",
+		Value:  []string{"./_ft/ersatz-fixtures.yml"},
+		Desc:   "Fixtures file, directory or glob to use to simulate requests. May be repeated to combine several fixtures files.",
 		EnvVar: "FIXTURES",
 	})
 
+	journalLimit := app.Int(cli.IntOpt{
+		Name:   "journal-limit",
+		Value:  journal.DefaultLimit,
+		Desc:   "Number of received requests to keep in the request journal",
+		EnvVar: "JOURNAL_LIMIT",
+	})
+
+	openAPI := app.String(cli.StringOpt{
+		Name:   "openapi",
+		Desc:   "OpenAPI (or Swagger 2.0) document to generate stubs from. Fixtures in the fixtures file take precedence.",
+		EnvVar: "OPENAPI",
+	})
+
+	validate := app.Bool(cli.BoolOpt{
+		Name:   "validate",
+		Value:  false,
+		Desc:   "Validate every request against the OpenAPI document before matching it to a fixture",
+		EnvVar: "VALIDATE",
+	})
+
+	validationStatus := app.Int(cli.IntOpt{
+		Name:   "validation-status",
+		Value:  openapi.DefaultValidationStatus,
+		Desc:   "Status code to respond with when a request fails validation, i.e. 422",
+		EnvVar: "VALIDATION_STATUS",
+	})
+
+	delay := app.String(cli.StringOpt{
+		Name:   "delay",
+		Value:  "0s",
+		Desc:   "Default delay before every response, i.e. 250ms",
+		EnvVar: "DELAY",
+	})
+
+	watch := app.Bool(cli.BoolOpt{
+		Name:   "watch",
+		Value:  false,
+		Desc:   "Reload the fixtures files whenever they, or a file they refer to, change",
+		EnvVar: "WATCH",
+	})
+
+	tlsCert := app.String(cli.StringOpt{
+		Name:   "tls-cert",
+		Desc:   "PEM certificate to serve HTTPS with, along with --tls-key",
+		EnvVar: "TLS_CERT",
+	})
+
+	tlsKey := app.String(cli.StringOpt{
+		Name:   "tls-key",
+		Desc:   "PEM private key of the --tls-cert",
+		EnvVar: "TLS_KEY",
+	})
+
+	tlsGenerateCA := app.String(cli.StringOpt{
+		Name:   "tls-generate-ca",
+		Desc:   "Serve HTTPS with a certificate signed by a CA generated on startup, and write the CA certificate to this file for clients to trust, i.e. ./ersatz-ca.pem",
+		EnvVar: "TLS_GENERATE_CA",
+	})
+
+	tlsHosts := app.Strings(cli.StringsOpt{
+		Name:   "tls-host",
+		Desc:   "Host name or IP address of the generated certificate, in addition to localhost and the hostname. May be repeated.",
+		EnvVar: "TLS_HOSTS",
+	})
+
+	tlsClientCA := app.String(cli.StringOpt{
+		Name:   "tls-client-ca",
+		Desc:   "PEM certificates of the CAs which sign client certificates. Every client must present a valid certificate, which can be matched by clientCert discriminators.",
+		EnvVar: "TLS_CLIENT_CA",
+	})
+
+	http2 := app.Bool(cli.BoolOpt{
+		Name:   "http2",
+		Value:  true,
+		Desc:   "Accept HTTP/2 requests, negotiated over HTTPS, or over cleartext (h2c) with prior knowledge or an upgrade. Disable to only serve HTTP/1.1.",
+		EnvVar: "HTTP2",
+	})
+
 	app.Action = func() {
-		yml, err := ioutil.ReadFile(*fixtures)
+		defaultDelay, err := time.ParseDuration(*delay)
 		if err != nil {
-			runServer(*port, nil)
-			return
+			log.WithError(err).Fatal("Failed to parse the default delay")
 		}
 
-		ers := ersatz{}
-		err = yaml.Unmarshal(yml, &ers)
+		tlsConfig, err := server.TLSOptions{Cert: *tlsCert, Key: *tlsKey, GenerateCA: *tlsGenerateCA, Hosts: *tlsHosts, ClientCA: *tlsClientCA}.Config()
 		if err != nil {
-			log.WithError(err).Fatal("Failed to unmarshal yaml in provided fixtures file")
+			log.WithError(err).Fatal("Failed to configure TLS")
 		}
 
-		runServer(*port, &ers)
-	}
+		yml, files, err := server.LoadFixtures(*fixtures)
+		s := server.New(server.Options{
+			Dir:              server.FixturesDir(*fixtures, files),
+			OpenAPI:          *openAPI,
+			Validate:         *validate,
+			ValidationStatus: *validationStatus,
+			DefaultDelay:     defaultDelay,
+			JournalLimit:     *journalLimit,
+		})
 
-	app.Run(os.Args)
-}
+		missing := os.IsNotExist(err) && len(*fixtures) == 1
+		switch {
+		case missing && *openAPI == "":
+			log.Info("No fixtures file found, ready to accept fixtures data on POST /__configure or PUT /__admin/fixtures")
+		case missing:
+			log.Info("No fixtures file found, generating stubs from the OpenAPI document")
+			if err := s.Startup(server.EmptyFixtures); err != nil {
+				log.WithError(err).Fatal("Failed to load the provided OpenAPI document")
+			}
+		case err != nil:
+			log.WithError(err).Fatal("Failed to read the provided fixtures files")
+		default:
+			if err := s.Startup(yml); err != nil {
+				log.WithError(err).Fatal("Failed to load the provided fixtures file")
+			}
+		}
 
-func acceptFixtures(w http.ResponseWriter, req *http.Request) {
-	yml, err := ioutil.ReadAll(req.Body)
-	if err != nil {
-		log.WithError(err).Error("Failed to read request body")
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
+		if *watch {
+			log.WithField("fixtures", *fixtures).Info("Watching the fixtures files for changes")
+			go s.Watch(*fixtures, server.DefaultWatchInterval, nil)
+		}
 
-	ers := ersatz{}
-	err = yaml.Unmarshal(yml, &ers)
-	if err != nil {
-		log.WithError(err).Error("Failed to unmarshal yaml")
-		http.Error(w, "Failed to unmarshal yaml", http.StatusBadRequest)
-		return
+		if *tlsGenerateCA != "" {
+			log.WithField("ca", *tlsGenerateCA).Info("Generated a CA for clients to trust")
+		}
+
+		l, err := net.Listen("tcp", ":"+*port)
+		if err == nil {
+			err = s.Serve(l, tlsConfig, *http2)
+		}
+
+		if err != nil {
+			log.Fatalf("Unable to start: %v", err)
+		}
 	}
 
-	configureOnce.Do(func() {
-		configureErsatz(ers)
+	app.Command("record", "Proxy every request to an upstream, and record the responses as a fixtures file", func(cmd *cli.Cmd) {
+		target := cmd.String(cli.StringOpt{
+			Name:   "target t",
+			Desc:   "Base URL of the upstream to record, i.e. https://api.example.com",
+			EnvVar: "TARGET",
+		})
+
+		output := cmd.String(cli.StringOpt{
+			Name:   "output o",
+			Value:  "./_ft/ersatz-fixtures.yml",
+			Desc:   "Fixtures file to write the recording to",
+			EnvVar: "OUTPUT",
+		})
+
+		allowHeaders := cmd.Strings(cli.StringsOpt{
+			Name: "allow-header",
+			Desc: "Request header which may be used to discriminate between requests. If none are provided, any header not denied may be used.",
+		})
+
+		denyHeaders := cmd.Strings(cli.StringsOpt{
+			Name: "deny-header",
+			Desc: "Header which must never be recorded, in addition to Authorization, Cookie and other credentials",
+		})
+
+		cmd.Action = func() {
+			upstream, err := url.Parse(*target)
+			if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+				log.WithField("target", *target).Fatal("Please provide the absolute URL of the upstream to record with --target")
+			}
+
+			recorder := record.New(upstream, *output, record.Options{AllowHeaders: *allowHeaders, DenyHeaders: *denyHeaders})
+			log.WithField("target", upstream.String()).WithField("output", *output).Info("Recording requests")
+
+			if err := http.ListenAndServe(":"+*port, recorder); err != nil {
+				log.Fatalf("Unable to start: %v", err)
+			}
+		}
 	})
 
-	log.Info("Configured fixtures via /__configure endpoint, further requests to this endpoint will not reconfigure ersatz.")
-	w.WriteHeader(http.StatusOK)
-}
+	app.Command("diff", "Replay a request for every fixture against the real API, and report any differences in the status, headers or body structure of the responses", func(cmd *cli.Cmd) {
+		target := cmd.String(cli.StringOpt{
+			Name:   "target t",
+			Desc:   "Base URL of the API the fixtures simulate, i.e. https://api.example.com",
+			EnvVar: "TARGET",
+		})
 
-func runServer(port string, ers *ersatz) {
-	if ers != nil {
-		configureErsatz(*ers)
-	} else {
-		log.Info("No fixtures file found, ready to accept fixtures data on POST /__configure")
-		http.HandleFunc("/__configure", acceptFixtures)
-	}
+		ignoreHeaders := cmd.Strings(cli.StringsOpt{
+			Name: "ignore-header",
+			Desc: "Response header which is expected to differ, in addition to Date, Content-Length and other volatile headers",
+		})
 
-	if err := http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Unable to start: %v", err)
-	}
-}
+		ignoreFields := cmd.Strings(cli.StringsOpt{
+			Name: "ignore-field",
+			Desc: "JSONPath of a response body field which is expected to differ, i.e. $.publishedDate, $.items[*].id or $..lastModified",
+		})
 
-func configureErsatz(ers ersatz) {
-	unmonitoredRouter := vestigo.NewRouter()
-	var r http.Handler = unmonitoredRouter
-	r = httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), r)
-
-	switch ers.Version {
-	case "1.0.0-rc1":
-	case "1.0.0":
-		v1.MockPaths(unmonitoredRouter, ers.Fixtures.(*v1.Fixtures))
-	case "2.0.0-rc1":
-	case "2.0.0":
-		v2.MockPaths(unmonitoredRouter, ers.Fixtures.(*v2.Fixtures))
-	default:
-		log.Fatal(ErrUnsupportedVersion.Error())
-	}
+		cmd.Action = func() {
+			upstream, err := url.Parse(*target)
+			if err != nil || upstream.Scheme == "" || upstream.Host == "" {
+				log.WithField("target", *target).Fatal("Please provide the absolute URL of the API to compare with --target")
+			}
+
+			yml, files, err := server.LoadFixtures(*fixtures)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to read the fixtures files")
+			}
+
+			s := server.New(server.Options{Dir: server.FixturesDir(*fixtures, files), OpenAPI: *openAPI})
+			f, err := s.Replayable(yml)
+			if err != nil {
+				log.WithError(err).Fatal("Failed to load the provided fixtures file")
+			}
+
+			results := diff.Run(upstream, f, diff.Options{IgnoreHeaders: *ignoreHeaders, IgnoreFields: *ignoreFields})
+			if err := diff.Write(os.Stdout, results); err != nil {
+				log.WithError(err).Fatal("Failed to write the differences")
+			}
+
+			if diff.Drifted(results) {
+				cli.Exit(1)
+			}
+		}
+	})
+
+	app.Command("validate", "Check the fixtures files for mistakes without starting the server, and report each problem with its line number", func(cmd *cli.Cmd) {
+		cmd.Action = func() {
+			_, files, err := server.LoadFixtures(*fixtures)
+			if len(files) == 0 {
+				log.WithError(err).Fatal("Failed to read the fixtures files")
+			}
 
-	log.Info("Ready to simulate requests!")
-	http.Handle("/", r)
+			failed := false
+			for _, f := range files {
+				yml, readErr := ioutil.ReadFile(f)
+				if readErr != nil {
+					fmt.Printf("%v: %v\n", f, readErr)
+					failed = true
+					continue
+				}
+
+				for _, p := range lint.Lint(yml) {
+					fmt.Printf("%v:%v: %v\n", f, p.Line, p.Message)
+					failed = true
+				}
+			}
+
+			if err != nil && !failed {
+				fmt.Println(err)
+				failed = true
+			}
+
+			if failed {
+				cli.Exit(1)
+			}
+		}
+	})
+
+	app.Run(os.Args)
 }
@@ -0,0 +1,555 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// ErrUnsupportedVersion is returned for documents which are neither Swagger 2.0 nor OpenAPI 3
+var ErrUnsupportedVersion = errors.New("unsupported OpenAPI version, please provide a Swagger 2.0 or OpenAPI 3 document")
+
+// Methods are the operations which can be declared for each path, in the order they are listed
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is an OpenAPI 2 or 3 document, normalised so both versions can be used in the same way
+type Document struct {
+	Version string
+	// BasePath prefixes every path in the document, and is taken from the basePath (Swagger 2.0) or the first server url (OpenAPI 3)
+	BasePath   string
+	Operations []*Operation
+}
+
+// Operation is a single path and method declared in the document
+type Operation struct {
+	Path        string
+	Method      string
+	ID          string
+	Parameters  []*Parameter
+	RequestBody *RequestBody
+	Responses   []*Response
+}
+
+// Parameter is a path, query, header or cookie parameter
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   *Schema
+}
+
+// RequestBody lists the schema of the request body for each content type
+type RequestBody struct {
+	Required bool
+	Content  map[string]*MediaType
+}
+
+// Response is a documented response for an operation. The Status is 0 for the default response.
+type Response struct {
+	Status  int
+	Content map[string]*MediaType
+}
+
+// MediaType is the schema and example for a single content type
+type MediaType struct {
+	Schema     *Schema
+	Example    interface{}
+	HasExample bool
+}
+
+// Load parses a Swagger 2.0 or OpenAPI 3 document from yaml or json
+func Load(data []byte) (*Document, error) {
+	j, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	s := spec{}
+	if err := json.Unmarshal(j, &s); err != nil {
+		return nil, err
+	}
+
+	r := &resolver{spec: &s, resolved: make(map[*Schema]bool)}
+	switch {
+	case s.Swagger == "2.0":
+		return r.document("2.0")
+	case strings.HasPrefix(s.OpenAPI, "3."):
+		return r.document(s.OpenAPI)
+	}
+	return nil, ErrUnsupportedVersion
+}
+
+// Operation returns the operation for the path and method, if it is declared
+func (d *Document) Operation(path string, method string) (*Operation, bool) {
+	for _, op := range d.Operations {
+		if op.Path == path && strings.EqualFold(op.Method, method) {
+			return op, true
+		}
+	}
+	return nil, false
+}
+
+// Success returns the first documented 2xx response, or the default response if there isn't one
+func (op *Operation) Success() *Response {
+	var fallback *Response
+	for _, r := range op.Responses {
+		if r.Status >= 200 && r.Status < 300 {
+			return r
+		}
+
+		if r.Status == 0 {
+			fallback = r
+		}
+	}
+	return fallback
+}
+
+// spec holds the parts of both Swagger 2.0 and OpenAPI 3 documents which ersatz uses
+type spec struct {
+	Swagger  string `json:"swagger"`
+	OpenAPI  string `json:"openapi"`
+	BasePath string `json:"basePath"`
+	Servers  []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Consumes []string `json:"consumes"`
+	Produces []string `json:"produces"`
+
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+
+	Definitions map[string]*Schema    `json:"definitions"`
+	Parameters  map[string]*parameter `json:"parameters"`
+	Responses   map[string]*response  `json:"responses"`
+
+	Components struct {
+		Schemas       map[string]*Schema      `json:"schemas"`
+		Parameters    map[string]*parameter   `json:"parameters"`
+		Responses     map[string]*response    `json:"responses"`
+		RequestBodies map[string]*requestBody `json:"requestBodies"`
+		Examples      map[string]*example     `json:"examples"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Consumes    []string             `json:"consumes"`
+	Produces    []string             `json:"produces"`
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+
+	// inline is the schema of a Swagger 2.0 parameter which is not in the body, which is declared alongside the name
+	inline *Schema
+}
+
+type requestBody struct {
+	Ref      string                `json:"$ref"`
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Ref      string                 `json:"$ref"`
+	Schema   *Schema                `json:"schema"`
+	Examples map[string]interface{} `json:"examples"`
+	Content  map[string]*mediaType  `json:"content"`
+}
+
+type mediaType struct {
+	Schema   *Schema             `json:"schema"`
+	Example  interface{}         `json:"example"`
+	Examples map[string]*example `json:"examples"`
+}
+
+type example struct {
+	Ref   string      `json:"$ref"`
+	Value interface{} `json:"value"`
+}
+
+func (p *parameter) UnmarshalJSON(data []byte) error {
+	type plain parameter
+	if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+		return err
+	}
+
+	if p.Schema == nil && p.Ref == "" {
+		p.inline = &Schema{}
+		return json.Unmarshal(data, p.inline)
+	}
+	return nil
+}
+
+type resolver struct {
+	spec     *spec
+	resolved map[*Schema]bool
+}
+
+func (r *resolver) document(version string) (*Document, error) {
+	doc := &Document{Version: version, BasePath: r.basePath(), Operations: make([]*Operation, 0)}
+
+	paths := make([]string, 0, len(r.spec.Paths))
+	for p := range r.spec.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		item := r.spec.Paths[p]
+
+		shared := make([]*parameter, 0)
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf(`invalid parameters for path '%v': %v`, p, err)
+			}
+		}
+
+		for _, method := range Methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+
+			o := operation{}
+			if err := json.Unmarshal(raw, &o); err != nil {
+				return nil, fmt.Errorf(`invalid operation '%v %v': %v`, strings.ToUpper(method), p, err)
+			}
+
+			op, err := r.operation(p, method, shared, o)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid operation '%v %v': %v`, strings.ToUpper(method), p, err)
+			}
+			doc.Operations = append(doc.Operations, op)
+		}
+	}
+	return doc, nil
+}
+
+// basePath returns the path which prefixes every operation, ignoring server urls which use variables
+func (r *resolver) basePath() string {
+	base := r.spec.BasePath
+	if len(r.spec.Servers) > 0 && !strings.Contains(r.spec.Servers[0].URL, "{") {
+		if u, err := url.Parse(r.spec.Servers[0].URL); err == nil {
+			base = u.Path
+		}
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+func (r *resolver) operation(path string, method string, shared []*parameter, o operation) (*Operation, error) {
+	op := &Operation{Path: path, Method: method, ID: o.OperationID, Parameters: make([]*Parameter, 0), Responses: make([]*Response, 0)}
+
+	consumes := o.Consumes
+	if consumes == nil {
+		consumes = r.spec.Consumes
+	}
+	if len(consumes) == 0 {
+		consumes = []string{"application/json"}
+	}
+
+	params := make(map[string]*Parameter)
+	order := make([]string, 0)
+	for _, raw := range append(append([]*parameter{}, shared...), o.Parameters...) {
+		p, err := r.parameter(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		switch p.In {
+		case "body":
+			op.RequestBody = &RequestBody{Required: p.Required, Content: make(map[string]*MediaType)}
+			for _, c := range consumes {
+				op.RequestBody.Content[c] = &MediaType{Schema: p.Schema}
+			}
+			continue
+		case "formData":
+			if op.RequestBody == nil {
+				op.RequestBody = &RequestBody{Content: map[string]*MediaType{"application/x-www-form-urlencoded": {Schema: &Schema{Type: "object", Properties: make(map[string]*Schema)}}}}
+			}
+			form := op.RequestBody.Content["application/x-www-form-urlencoded"].Schema
+			form.Properties[p.Name] = p.Schema
+			if p.Required {
+				op.RequestBody.Required = true
+				form.Required = append(form.Required, p.Name)
+			}
+			continue
+		}
+
+		key := p.In + ":" + p.Name
+		if _, ok := params[key]; !ok {
+			order = append(order, key)
+		}
+		params[key] = p // operation parameters override path parameters
+	}
+
+	for _, key := range order {
+		op.Parameters = append(op.Parameters, params[key])
+	}
+
+	if o.RequestBody != nil {
+		body, err := r.requestBody(o.RequestBody)
+		if err != nil {
+			return nil, err
+		}
+		op.RequestBody = body
+	}
+
+	produces := o.Produces
+	if produces == nil {
+		produces = r.spec.Produces
+	}
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+
+	for code, raw := range o.Responses {
+		status, err := parseStatus(code)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := r.response(status, raw, produces)
+		if err != nil {
+			return nil, err
+		}
+		op.Responses = append(op.Responses, res)
+	}
+
+	sort.Slice(op.Responses, func(i, j int) bool {
+		return op.Responses[i].Status < op.Responses[j].Status
+	})
+	return op, nil
+}
+
+// parseStatus converts a response code (i.e. 200, 2XX or default) to a status code, using 0 for the default response
+func parseStatus(code string) (int, error) {
+	if code == "default" {
+		return 0, nil
+	}
+
+	upper := strings.ToUpper(code)
+	if len(upper) == 3 && strings.HasSuffix(upper, "XX") {
+		upper = upper[:1] + "00"
+	}
+
+	status, err := strconv.Atoi(upper)
+	if err != nil || status < 100 || status > 599 {
+		return 0, fmt.Errorf(`invalid response code '%v'`, code)
+	}
+	return status, nil
+}
+
+func (r *resolver) parameter(p *parameter) (*Parameter, error) {
+	if p.Ref != "" {
+		name, err := refName(p.Ref, "#/parameters/", "#/components/parameters/")
+		if err != nil {
+			return nil, err
+		}
+
+		target, ok := r.spec.Parameters[name]
+		if !ok {
+			target, ok = r.spec.Components.Parameters[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf(`unable to resolve '%v'`, p.Ref)
+		}
+		p = target
+	}
+
+	schema := p.Schema
+	if schema == nil {
+		schema = p.inline
+	}
+
+	resolved, err := r.schema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return &Parameter{Name: p.Name, In: p.In, Required: p.Required || p.In == "path", Schema: resolved}, nil
+}
+
+func (r *resolver) requestBody(b *requestBody) (*RequestBody, error) {
+	if b.Ref != "" {
+		name, err := refName(b.Ref, "#/components/requestBodies/")
+		if err != nil {
+			return nil, err
+		}
+
+		target, ok := r.spec.Components.RequestBodies[name]
+		if !ok {
+			return nil, fmt.Errorf(`unable to resolve '%v'`, b.Ref)
+		}
+		b = target
+	}
+
+	body := &RequestBody{Required: b.Required, Content: make(map[string]*MediaType)}
+	for contentType, m := range b.Content {
+		media, err := r.mediaType(m)
+		if err != nil {
+			return nil, err
+		}
+		body.Content[contentType] = media
+	}
+	return body, nil
+}
+
+func (r *resolver) response(status int, res *response, produces []string) (*Response, error) {
+	if res.Ref != "" {
+		name, err := refName(res.Ref, "#/responses/", "#/components/responses/")
+		if err != nil {
+			return nil, err
+		}
+
+		target, ok := r.spec.Responses[name]
+		if !ok {
+			target, ok = r.spec.Components.Responses[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf(`unable to resolve '%v'`, res.Ref)
+		}
+		res = target
+	}
+
+	out := &Response{Status: status, Content: make(map[string]*MediaType)}
+	for contentType, m := range res.Content {
+		media, err := r.mediaType(m)
+		if err != nil {
+			return nil, err
+		}
+		out.Content[contentType] = media
+	}
+
+	if res.Schema != nil || len(res.Examples) > 0 { // swagger 2.0
+		schema, err := r.schema(res.Schema)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, contentType := range produces {
+			media := &MediaType{Schema: schema}
+			media.Example, media.HasExample = res.Examples[contentType]
+			out.Content[contentType] = media
+		}
+
+		for contentType, ex := range res.Examples {
+			if _, ok := out.Content[contentType]; !ok {
+				out.Content[contentType] = &MediaType{Schema: schema, Example: ex, HasExample: true}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *resolver) mediaType(m *mediaType) (*MediaType, error) {
+	schema, err := r.schema(m.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	media := &MediaType{Schema: schema, Example: m.Example, HasExample: m.Example != nil}
+	if media.HasExample || len(m.Examples) == 0 {
+		return media, nil
+	}
+
+	names := make([]string, 0, len(m.Examples))
+	for name := range m.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ex := m.Examples[names[0]]
+	if ex.Ref != "" {
+		name, err := refName(ex.Ref, "#/components/examples/")
+		if err != nil {
+			return nil, err
+		}
+
+		target, ok := r.spec.Components.Examples[name]
+		if !ok {
+			return nil, fmt.Errorf(`unable to resolve '%v'`, ex.Ref)
+		}
+		ex = target
+	}
+
+	media.Example, media.HasExample = ex.Value, true
+	return media, nil
+}
+
+// schema replaces every $ref in the schema with the schema it refers to. Recursive schemas are supported, as references are replaced with pointers to the same schema.
+func (r *resolver) schema(s *Schema) (*Schema, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	if s.Ref != "" {
+		name, err := refName(s.Ref, "#/definitions/", "#/components/schemas/")
+		if err != nil {
+			return nil, err
+		}
+
+		target, ok := r.spec.Definitions[name]
+		if !ok {
+			target, ok = r.spec.Components.Schemas[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf(`unable to resolve '%v'`, s.Ref)
+		}
+		return r.schema(target)
+	}
+
+	if r.resolved[s] {
+		return s, nil
+	}
+	r.resolved[s] = true
+
+	var err error
+	if s.Items, err = r.schema(s.Items); err != nil {
+		return nil, err
+	}
+
+	if s.AdditionalProperties, err = r.schema(s.AdditionalProperties); err != nil {
+		return nil, err
+	}
+
+	if s.Not, err = r.schema(s.Not); err != nil {
+		return nil, err
+	}
+
+	for k, p := range s.Properties {
+		if s.Properties[k], err = r.schema(p); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, list := range [][]*Schema{s.AllOf, s.OneOf, s.AnyOf} {
+		for i, child := range list {
+			if list[i], err = r.schema(child); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// refName returns the name of a local reference with one of the prefixes, i.e. #/components/schemas/Content
+func refName(ref string, prefixes ...string) (string, error) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(ref, prefix) {
+			return strings.TrimPrefix(ref, prefix), nil
+		}
+	}
+	return "", fmt.Errorf(`unsupported reference '%v', only local references to %v are supported`, ref, strings.Join(prefixes, " or "))
+}
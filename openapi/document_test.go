@@ -0,0 +1,214 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const swaggerTestYAML = `
+swagger: "2.0"
+produces:
+  - application/json
+parameters:
+  tid:
+    name: X-Request-Id
+    in: header
+    type: string
+paths:
+  /content/{uuid}:
+    parameters:
+      - name: uuid
+        in: path
+        type: string
+        format: uuid
+    get:
+      operationId: getContent
+      parameters:
+        - $ref: "#/parameters/tid"
+      responses:
+        200:
+          description: The content
+          schema:
+            $ref: "#/definitions/Content"
+          examples:
+            application/json:
+              id: 7fa7b1d0-2f1e-4c5a-9a3e-1c1f4f6b2f10
+              title: Example Title
+        404:
+          description: Not found
+    put:
+      consumes:
+        - application/json
+      parameters:
+        - name: content
+          in: body
+          required: true
+          schema:
+            $ref: "#/definitions/Content"
+      responses:
+        201:
+          description: Created
+definitions:
+  Content:
+    type: object
+    required: [id, title]
+    properties:
+      id:
+        type: string
+        format: uuid
+      title:
+        type: string
+      related:
+        type: array
+        items:
+          $ref: "#/definitions/Content"
+`
+
+const openAPITestYAML = `
+openapi: 3.0.1
+paths:
+  /lists:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          required: true
+          schema:
+            type: integer
+            minimum: 1
+      responses:
+        default:
+          $ref: "#/components/responses/Error"
+        2XX:
+          description: The lists
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/List"
+              examples:
+                second:
+                  value: [{id: 2}]
+                first:
+                  $ref: "#/components/examples/Lists"
+    post:
+      requestBody:
+        $ref: "#/components/requestBodies/List"
+      responses:
+        "201":
+          description: Created
+components:
+  schemas:
+    List:
+      type: object
+      properties:
+        id:
+          type: integer
+  examples:
+    Lists:
+      value: [{id: 1}]
+  requestBodies:
+    List:
+      required: true
+      content:
+        application/json:
+          schema:
+            $ref: "#/components/schemas/List"
+  responses:
+    Error:
+      description: Error
+      content:
+        text/plain:
+          example: Something went wrong
+`
+
+func TestLoad__Swagger(t *testing.T) {
+	d, err := Load([]byte(swaggerTestYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "2.0", d.Version)
+	require.Len(t, d.Operations, 2)
+
+	get, ok := d.Operation("/content/{uuid}", "GET")
+	require.True(t, ok)
+	assert.Equal(t, "getContent", get.ID)
+	require.Len(t, get.Parameters, 2)
+	assert.Equal(t, &Parameter{Name: "uuid", In: "path", Required: true, Schema: &Schema{Type: "string", Format: "uuid"}}, get.Parameters[0])
+	assert.Equal(t, "X-Request-Id", get.Parameters[1].Name)
+
+	require.Len(t, get.Responses, 2)
+	assert.Equal(t, 200, get.Responses[0].Status)
+	assert.Equal(t, 404, get.Responses[1].Status)
+
+	media := get.Responses[0].Content["application/json"]
+	require.NotNil(t, media)
+	assert.True(t, media.HasExample)
+	assert.Equal(t, "object", media.Schema.Type)
+	assert.Equal(t, media.Schema, media.Schema.Properties["related"].Items)
+
+	put, ok := d.Operation("/content/{uuid}", "put")
+	require.True(t, ok)
+	require.NotNil(t, put.RequestBody)
+	assert.True(t, put.RequestBody.Required)
+	assert.Equal(t, []string{"id", "title"}, put.RequestBody.Content["application/json"].Schema.Required)
+}
+
+func TestLoad__OpenAPI3(t *testing.T) {
+	d, err := Load([]byte(openAPITestYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.1", d.Version)
+
+	get, ok := d.Operation("/lists", "get")
+	require.True(t, ok)
+	require.Len(t, get.Responses, 2)
+	assert.Equal(t, 0, get.Responses[0].Status)
+	assert.Equal(t, 200, get.Responses[1].Status)
+	assert.Equal(t, get.Responses[1], get.Success())
+	assert.Equal(t, []interface{}{map[string]interface{}{"id": float64(1)}}, get.Responses[1].Content["application/json"].Example)
+	assert.Equal(t, "Something went wrong", get.Responses[0].Content["text/plain"].Example)
+
+	post, ok := d.Operation("/lists", "post")
+	require.True(t, ok)
+	assert.True(t, post.RequestBody.Required)
+	assert.Equal(t, "object", post.RequestBody.Content["application/json"].Schema.Type)
+}
+
+func TestLoad__Invalid(t *testing.T) {
+	_, err := Load([]byte(`openapi: 2.5.0`))
+	assert.Equal(t, ErrUnsupportedVersion, err)
+
+	_, err = Load([]byte(`
+openapi: 3.0.0
+paths:
+  /lists:
+    get:
+      responses:
+        "200":
+          $ref: "#/components/responses/Missing"
+`))
+	assert.EqualError(t, err, "invalid operation 'GET /lists': unable to resolve '#/components/responses/Missing'")
+
+	_, err = Load([]byte(`
+openapi: 3.0.0
+paths:
+  /lists:
+    get:
+      responses:
+        "200":
+          $ref: "other.yml#/components/responses/Lists"
+`))
+	assert.Error(t, err)
+
+	_, err = Load([]byte(`
+openapi: 3.0.0
+paths:
+  /lists:
+    get:
+      responses:
+        "OK":
+          description: OK
+`))
+	assert.EqualError(t, err, "invalid operation 'GET /lists': invalid response code 'OK'")
+}
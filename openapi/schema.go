@@ -0,0 +1,170 @@
+package openapi
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// maxExampleDepth stops examples being synthesised forever for recursive schemas
+const maxExampleDepth = 8
+
+// Schema is a JSON schema, as used by both Swagger 2.0 and OpenAPI 3
+type Schema struct {
+	Ref    string `json:"$ref"`
+	Type   string `json:"type"`
+	Format string `json:"format"`
+
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"-"`
+	Items                *Schema            `json:"items"`
+
+	AllOf []*Schema `json:"allOf"`
+	OneOf []*Schema `json:"oneOf"`
+	AnyOf []*Schema `json:"anyOf"`
+	Not   *Schema   `json:"not"`
+
+	Enum     []interface{} `json:"enum"`
+	Example  interface{}   `json:"example"`
+	Default  interface{}   `json:"default"`
+	Nullable bool          `json:"nullable"`
+
+	Minimum          *float64 `json:"minimum"`
+	Maximum          *float64 `json:"maximum"`
+	ExclusiveMinimum bool     `json:"exclusiveMinimum"`
+	ExclusiveMaximum bool     `json:"exclusiveMaximum"`
+	MinLength        *int     `json:"minLength"`
+	MaxLength        *int     `json:"maxLength"`
+	Pattern          string   `json:"pattern"`
+	MinItems         *int     `json:"minItems"`
+	MaxItems         *int     `json:"maxItems"`
+}
+
+// UnmarshalJSON supports additionalProperties as either a boolean or a schema
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	type plain Schema
+	aux := struct {
+		*plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}{plain: (*plain)(s)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.AdditionalProperties) == 0 || aux.AdditionalProperties[0] != '{' {
+		return nil
+	}
+
+	s.AdditionalProperties = &Schema{}
+	return json.Unmarshal(aux.AdditionalProperties, s.AdditionalProperties)
+}
+
+// Synthesise returns the example for the schema if it has one, or otherwise a value which satisfies it
+func (s *Schema) Synthesise() interface{} {
+	return s.example(0)
+}
+
+func (s *Schema) example(depth int) interface{} {
+	if s == nil || depth > maxExampleDepth {
+		return nil
+	}
+
+	switch {
+	case s.Example != nil:
+		return s.Example
+	case s.Default != nil:
+		return s.Default
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, child := range s.AllOf {
+			if obj, ok := child.example(depth + 1).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	case len(s.OneOf) > 0:
+		return s.OneOf[0].example(depth + 1)
+	case len(s.AnyOf) > 0:
+		return s.AnyOf[0].example(depth + 1)
+	}
+
+	switch s.schemaType() {
+	case "object":
+		obj := make(map[string]interface{})
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if v := s.Properties[name].example(depth + 1); v != nil {
+				obj[name] = v
+			}
+		}
+		return obj
+	case "array":
+		item := s.Items.example(depth + 1)
+		if item == nil {
+			return []interface{}{}
+		}
+		return []interface{}{item}
+	case "integer":
+		if s.Minimum != nil {
+			return int64(*s.Minimum)
+		}
+		return 0
+	case "number":
+		if s.Minimum != nil {
+			return *s.Minimum
+		}
+		return 0.0
+	case "boolean":
+		return true
+	case "string":
+		return exampleString(s.Format)
+	}
+	return nil
+}
+
+// schemaType returns the declared type, or infers it from the other keywords used
+func (s *Schema) schemaType() string {
+	switch {
+	case s.Type != "":
+		return s.Type
+	case s.Properties != nil || s.AdditionalProperties != nil:
+		return "object"
+	case s.Items != nil:
+		return "array"
+	}
+	return ""
+}
+
+func exampleString(format string) string {
+	switch format {
+	case "date":
+		return "2018-01-01"
+	case "date-time":
+		return "2018-01-01T00:00:00Z"
+	case "uuid":
+		return "7fa7b1d0-2f1e-4c5a-9a3e-1c1f4f6b2f10"
+	case "email":
+		return "user@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "127.0.0.1"
+	case "ipv6":
+		return "::1"
+	case "byte":
+		return "ZXhhbXBsZQ=="
+	}
+	return "string"
+}
@@ -0,0 +1,53 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSynthesise(t *testing.T) {
+	min := 5.0
+	tests := []struct {
+		schema   *Schema
+		expected interface{}
+	}{
+		{schema: &Schema{Type: "string"}, expected: "string"},
+		{schema: &Schema{Type: "string", Format: "date-time"}, expected: "2018-01-01T00:00:00Z"},
+		{schema: &Schema{Type: "string", Enum: []interface{}{"article", "video"}}, expected: "article"},
+		{schema: &Schema{Type: "string", Example: "Example Title"}, expected: "Example Title"},
+		{schema: &Schema{Type: "integer", Default: 10}, expected: 10},
+		{schema: &Schema{Type: "integer", Minimum: &min}, expected: int64(5)},
+		{schema: &Schema{Type: "number"}, expected: 0.0},
+		{schema: &Schema{Type: "boolean"}, expected: true},
+		{schema: &Schema{Items: &Schema{Type: "integer"}}, expected: []interface{}{0}},
+		{schema: &Schema{Properties: map[string]*Schema{"id": {Type: "string", Format: "uuid"}}}, expected: map[string]interface{}{"id": "7fa7b1d0-2f1e-4c5a-9a3e-1c1f4f6b2f10"}},
+		{schema: &Schema{AllOf: []*Schema{{Properties: map[string]*Schema{"a": {Type: "boolean"}}}, {Properties: map[string]*Schema{"b": {Type: "boolean"}}}}}, expected: map[string]interface{}{"a": true, "b": true}},
+		{schema: &Schema{OneOf: []*Schema{{Type: "boolean"}, {Type: "string"}}}, expected: true},
+		{schema: nil, expected: nil},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, test.schema.Synthesise())
+	}
+}
+
+func TestSynthesise__Recursive(t *testing.T) {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{"title": {Type: "string"}}}
+	s.Properties["parent"] = s
+
+	out, err := json.Marshal(s.Synthesise())
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"parent":{"parent":`)
+}
+
+func TestSchemaUnmarshal__AdditionalProperties(t *testing.T) {
+	s := Schema{}
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"object","additionalProperties":true}`), &s))
+	assert.Nil(t, s.AdditionalProperties)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"object","additionalProperties":{"type":"string"}}`), &s))
+	assert.Equal(t, &Schema{Type: "string"}, s.AdditionalProperties)
+}
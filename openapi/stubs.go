@@ -0,0 +1,106 @@
+package openapi
+
+import (
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+)
+
+// PreferHeader selects which documented response is returned by a generated stub, i.e. Prefer: code=404
+const PreferHeader = "Prefer"
+
+// Fixtures generates 2.0.0 ersatz fixtures for every operation in the document. Each operation responds with its first documented 2xx response (or its first documented response, if none are successful), and any other documented response can be requested with a Prefer: code=<status> header.
+func (d *Document) Fixtures() map[string]map[string]interface{} {
+	fixtures := make(map[string]map[string]interface{})
+	for _, op := range d.Operations {
+		path := d.BasePath + op.Path
+		if _, ok := fixtures[path]; !ok {
+			fixtures[path] = make(map[string]interface{})
+		}
+		fixtures[path][op.Method] = op.stub()
+	}
+	return fixtures
+}
+
+func (op *Operation) stub() interface{} {
+	success := op.Success()
+	if success == nil && len(op.Responses) > 0 {
+		success = op.Responses[0]
+	}
+
+	if success == nil {
+		return map[string]interface{}{"status": 200}
+	}
+
+	others := make([]*Response, 0)
+	for _, r := range op.Responses {
+		if r != success && r.Status != 0 {
+			others = append(others, r)
+		}
+	}
+
+	if len(others) == 0 {
+		return success.stub()
+	}
+
+	discriminators := make([]interface{}, 0, len(others)+1)
+	for _, r := range others {
+		discriminators = append(discriminators, map[string]interface{}{
+			"when":     map[string]interface{}{"headers": map[string]string{PreferHeader: fmt.Sprintf("code=%v", r.Status)}},
+			"response": r.stub(),
+		})
+	}
+	return append(discriminators, map[string]interface{}{"response": success.stub()})
+}
+
+// stub converts the response to a fixture response, using the example for the preferred content type, or a body synthesised from its schema
+func (r *Response) stub() map[string]interface{} {
+	status := r.Status
+	if status == 0 {
+		status = 200
+	}
+
+	res := map[string]interface{}{"status": status}
+	contentType, media := r.preferredContent()
+	if media == nil {
+		return res
+	}
+
+	body := media.Example
+	if !media.HasExample {
+		body = media.Schema.Synthesise()
+	}
+
+	if body == nil {
+		return res
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if _, ok := body.(string); !ok && mediaType != "application/json" && mediaType != "application/x-yaml" {
+		contentType = "application/json"
+	}
+
+	res["headers"] = map[string]string{"content-type": contentType}
+	res["body"] = body
+	return res
+}
+
+// preferredContent picks json if the response supports it, or otherwise the first content type alphabetically
+func (r *Response) preferredContent() (string, *MediaType) {
+	if len(r.Content) == 0 {
+		return "", nil
+	}
+
+	types := make([]string, 0, len(r.Content))
+	for t := range r.Content {
+		mediaType, _, _ := mime.ParseMediaType(t)
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return t, r.Content[t]
+		}
+		types = append(types, t)
+	}
+
+	sort.Strings(types)
+	return types[0], r.Content[types[0]]
+}
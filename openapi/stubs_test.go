@@ -0,0 +1,75 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtures(t *testing.T) {
+	d, err := Load([]byte(swaggerTestYAML))
+	require.NoError(t, err)
+
+	out, err := json.Marshal(d.Fixtures())
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"/content/{uuid}": {
+			"get": [
+				{"when": {"headers": {"Prefer": "code=404"}}, "response": {"status": 404}},
+				{"response": {"status": 200, "headers": {"content-type": "application/json"}, "body": {"id": "7fa7b1d0-2f1e-4c5a-9a3e-1c1f4f6b2f10", "title": "Example Title"}}}
+			],
+			"put": {"status": 201}
+		}
+	}`, string(out))
+}
+
+func TestFixtures__SynthesisedBodies(t *testing.T) {
+	d, err := Load([]byte(`
+openapi: 3.0.0
+paths:
+  /lists/{id}:
+    get:
+      responses:
+        "200":
+          description: The list
+          content:
+            application/vnd.api+json:
+              schema:
+                type: object
+                properties:
+                  id:
+                    type: integer
+        default:
+          description: Error
+          content:
+            text/plain:
+              schema:
+                type: string
+`))
+	require.NoError(t, err)
+
+	out, err := json.Marshal(d.Fixtures())
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"/lists/{id}": {"get": {"status": 200, "headers": {"content-type": "application/json"}, "body": {"id": 0}}}}`, string(out))
+}
+
+func TestFixtures__BasePath(t *testing.T) {
+	d, err := Load([]byte(`
+swagger: "2.0"
+basePath: /v1/
+paths:
+  /lists:
+    get:
+      responses:
+        200:
+          description: The lists
+`))
+	require.NoError(t, err)
+
+	fixtures := d.Fixtures()
+	assert.Contains(t, fixtures, "/v1/lists")
+}
@@ -0,0 +1,534 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultValidationStatus is the status returned for requests which do not satisfy the OpenAPI document
+const DefaultValidationStatus = http.StatusBadRequest
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ValidationError describes a single way in which a request does not satisfy the OpenAPI document
+type ValidationError struct {
+	Location string `json:"location"`
+	Name     string `json:"name,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ValidationReport is returned (and journalled) for requests which do not satisfy the OpenAPI document
+type ValidationReport struct {
+	Message string            `json:"message"`
+	Errors  []ValidationError `json:"errors"`
+}
+
+// Validator rejects requests which do not satisfy the OpenAPI document, before they are matched to a fixture
+type Validator struct {
+	Document *Document
+	Status   int
+}
+
+// Middleware validates every request, responding with the validation errors if there are any, or otherwise calling next
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs := v.Document.Validate(r)
+		if len(errs) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		report := ValidationReport{Message: "The request does not satisfy the OpenAPI document", Errors: errs}
+		journal.SetValidation(r, report)
+		log.WithField("method", r.Method).WithField("path", r.URL.Path).WithField("validation", report).Warn("Request failed validation")
+
+		status := v.Status
+		if status == 0 {
+			status = DefaultValidationStatus
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			log.WithError(err).Error("Failed to write validation report")
+		}
+	})
+}
+
+// Find returns the operation for the request method and path, along with the path parameters. Paths with more literal segments are preferred, so /content/latest takes precedence over /content/{uuid}.
+func (d *Document) Find(method string, path string) (*Operation, map[string]string, bool) {
+	if d.BasePath != "" && d.BasePath != "/" {
+		if !strings.HasPrefix(path, d.BasePath) {
+			return nil, nil, false
+		}
+		path = strings.TrimPrefix(path, d.BasePath)
+	}
+
+	var found *Operation
+	var foundParams map[string]string
+	best := -1
+	for _, op := range d.Operations {
+		if !strings.EqualFold(op.Method, method) {
+			continue
+		}
+
+		params, literals, ok := matchPath(op.Path, path)
+		if ok && literals > best {
+			found, foundParams, best = op, params, literals
+		}
+	}
+	return found, foundParams, found != nil
+}
+
+// declares returns true if any operation is declared for the path
+func (d *Document) declares(path string) bool {
+	for _, op := range d.Operations {
+		if _, _, ok := d.Find(op.Method, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(template string, path string) (map[string]string, int, bool) {
+	expected := strings.Split(strings.Trim(template, "/"), "/")
+	actual := strings.Split(strings.Trim(path, "/"), "/")
+	if len(expected) != len(actual) {
+		return nil, 0, false
+	}
+
+	params := make(map[string]string)
+	literals := 0
+	for i, segment := range expected {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			v, err := url.PathUnescape(actual[i])
+			if err != nil || v == "" {
+				return nil, 0, false
+			}
+			params[segment[1:len(segment)-1]] = v
+			continue
+		}
+
+		if segment != actual[i] {
+			return nil, 0, false
+		}
+		literals++
+	}
+	return params, literals, true
+}
+
+// Validate checks the request against the operation declared for its method and path. HEAD requests are checked against the GET operation if there is no HEAD operation, and OPTIONS requests only need an operation for their path.
+func (d *Document) Validate(r *http.Request) []ValidationError {
+	op, pathParams, ok := d.Find(r.Method, r.URL.Path)
+	if !ok && r.Method == http.MethodHead { // ersatz answers HEAD requests with the get fixture
+		op, pathParams, ok = d.Find(http.MethodGet, r.URL.Path)
+	}
+
+	if !ok && r.Method == http.MethodOptions && d.declares(r.URL.Path) { // ersatz answers OPTIONS requests for every path
+		return nil
+	}
+
+	if !ok {
+		return []ValidationError{{Location: "path", Message: fmt.Sprintf("no operation is declared for %v %v", r.Method, r.URL.Path)}}
+	}
+
+	errs := make([]ValidationError, 0)
+	query := r.URL.Query()
+	cookies := make(map[string]string)
+	for _, c := range r.Cookies() {
+		cookies[c.Name] = c.Value
+	}
+
+	for _, p := range op.Parameters {
+		var value string
+		var present bool
+		switch p.In {
+		case "path":
+			value, present = pathParams[p.Name]
+		case "query":
+			_, present = query[p.Name]
+			value = query.Get(p.Name)
+		case "header":
+			_, present = r.Header[http.CanonicalHeaderKey(p.Name)]
+			value = r.Header.Get(p.Name)
+		case "cookie":
+			value, present = cookies[p.Name]
+		default:
+			continue
+		}
+
+		if !present {
+			if p.Required {
+				errs = append(errs, ValidationError{Location: p.In, Name: p.Name, Message: "is required"})
+			}
+			continue
+		}
+
+		for _, msg := range p.Schema.validateParameter(value) {
+			errs = append(errs, ValidationError{Location: p.In, Name: p.Name, Message: msg})
+		}
+	}
+
+	if op.RequestBody != nil {
+		errs = append(errs, op.RequestBody.validate(r)...)
+	}
+	return errs
+}
+
+func (b *RequestBody) validate(r *http.Request) []ValidationError {
+	body := []byte{}
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return []ValidationError{{Location: "body", Message: "could not be read"}}
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if len(body) == 0 {
+		if b.Required {
+			return []ValidationError{{Location: "body", Message: "is required"}}
+		}
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil && contentType != "" {
+		return []ValidationError{{Location: "header", Name: "Content-Type", Message: fmt.Sprintf("'%v' is not a valid media type", contentType)}}
+	}
+
+	media, ok := b.media(mediaType)
+	if !ok {
+		return []ValidationError{{Location: "header", Name: "Content-Type", Message: fmt.Sprintf("'%v' is not one of %v", contentType, strings.Join(b.contentTypes(), ", "))}}
+	}
+
+	if media.Schema == nil {
+		return nil
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "":
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return []ValidationError{{Location: "body", Message: "is not valid json"}}
+		}
+		return media.Schema.validate("body", "$", doc)
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []ValidationError{{Location: "body", Message: "is not a valid form"}}
+		}
+
+		errs := make([]ValidationError, 0)
+		for _, name := range media.Schema.Required {
+			if _, ok := form[name]; !ok {
+				errs = append(errs, ValidationError{Location: "body", Name: name, Message: "is required"})
+			}
+		}
+
+		for name, values := range form {
+			for _, msg := range media.Schema.Properties[name].validateParameter(values[0]) {
+				errs = append(errs, ValidationError{Location: "body", Name: name, Message: msg})
+			}
+		}
+		return errs
+	}
+	return nil
+}
+
+// media returns the declared media type which matches the content type, including wildcards such as application/*
+func (b *RequestBody) media(mediaType string) (*MediaType, bool) {
+	if len(b.Content) == 0 {
+		return &MediaType{}, true
+	}
+
+	for declared, media := range b.Content {
+		d, _, err := mime.ParseMediaType(declared)
+		if err != nil {
+			continue
+		}
+
+		if d == mediaType || d == "*/*" || (strings.HasSuffix(d, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(d, "*"))) {
+			return media, true
+		}
+	}
+
+	if mediaType == "" { // assume json, as ersatz does for responses
+		return b.media("application/json")
+	}
+	return nil, false
+}
+
+func (b *RequestBody) contentTypes() []string {
+	types := make([]string, 0, len(b.Content))
+	for t := range b.Content {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// validateParameter converts a parameter to the type declared by the schema, then validates it
+func (s *Schema) validateParameter(value string) []string {
+	if s == nil {
+		return nil
+	}
+
+	var v interface{} = value
+	switch s.schemaType() {
+	case "integer", "number":
+		v = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return []string{fmt.Sprintf("expected a boolean, but was '%v'", value)}
+		}
+		v = b
+	case "array":
+		items := make([]interface{}, 0)
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, item)
+			if s.Items != nil && (s.Items.schemaType() == "integer" || s.Items.schemaType() == "number") {
+				items[len(items)-1] = json.Number(item)
+			}
+		}
+		v = items
+	}
+
+	errs := s.validate("", "", v)
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Message
+	}
+	return msgs
+}
+
+// validate checks the value against the schema, naming each error with the JSON path at which it was found
+func (s *Schema) validate(location string, path string, v interface{}) []ValidationError {
+	if s == nil {
+		return nil
+	}
+
+	fail := func(format string, args ...interface{}) []ValidationError {
+		return []ValidationError{{Location: location, Name: path, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if v == nil {
+		if s.Nullable || s.schemaType() == "" {
+			return nil
+		}
+		return fail("must not be null")
+	}
+
+	errs := make([]ValidationError, 0)
+	for _, child := range s.AllOf {
+		errs = append(errs, child.validate(location, path, v)...)
+	}
+
+	if len(s.OneOf) > 0 {
+		matches := 0
+		for _, child := range s.OneOf {
+			if len(child.validate(location, path, v)) == 0 {
+				matches++
+			}
+		}
+
+		if matches != 1 {
+			errs = append(errs, fail("must match exactly one schema in oneOf, but matched %v", matches)...)
+		}
+	}
+
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, child := range s.AnyOf {
+			if len(child.validate(location, path, v)) == 0 {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			errs = append(errs, fail("must match at least one schema in anyOf")...)
+		}
+	}
+
+	if s.Not != nil && len(s.Not.validate(location, path, v)) == 0 {
+		errs = append(errs, fail("must not match the schema in not")...)
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		errs = append(errs, fail("must be one of %v", describeEnum(s.Enum))...)
+	}
+
+	switch s.schemaType() {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return append(errs, fail("expected an object")...)
+		}
+		errs = append(errs, s.validateObject(location, path, obj)...)
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			return append(errs, fail("expected an array")...)
+		}
+
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			errs = append(errs, fail("must have at least %v items", *s.MinItems)...)
+		}
+
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			errs = append(errs, fail("must have at most %v items", *s.MaxItems)...)
+		}
+
+		for i, item := range arr {
+			errs = append(errs, s.Items.validate(location, fmt.Sprintf("%v[%v]", path, i), item)...)
+		}
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return append(errs, fail("expected a number")...)
+		}
+
+		f, err := n.Float64()
+		if err != nil {
+			return append(errs, fail("expected a number, but was '%v'", n)...)
+		}
+
+		if s.schemaType() == "integer" && f != math.Trunc(f) {
+			return append(errs, fail("expected an integer, but was '%v'", n)...)
+		}
+
+		if s.Minimum != nil && (f < *s.Minimum || (s.ExclusiveMinimum && f == *s.Minimum)) {
+			errs = append(errs, fail("must be at least %v", *s.Minimum)...)
+		}
+
+		if s.Maximum != nil && (f > *s.Maximum || (s.ExclusiveMaximum && f == *s.Maximum)) {
+			errs = append(errs, fail("must be at most %v", *s.Maximum)...)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return append(errs, fail("expected a boolean")...)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return append(errs, fail("expected a string")...)
+		}
+		errs = append(errs, s.validateString(location, path, str)...)
+	}
+	return errs
+}
+
+func (s *Schema) validateObject(location string, path string, obj map[string]interface{}) []ValidationError {
+	errs := make([]ValidationError, 0)
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			errs = append(errs, ValidationError{Location: location, Name: path + "." + name, Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if !ok {
+			prop = s.AdditionalProperties
+		}
+		errs = append(errs, prop.validate(location, path+"."+name, obj[name])...)
+	}
+	return errs
+}
+
+func (s *Schema) validateString(location string, path string, str string) []ValidationError {
+	errs := make([]ValidationError, 0)
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Location: location, Name: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := len([]rune(str))
+	if s.MinLength != nil && length < *s.MinLength {
+		fail("must be at least %v characters", *s.MinLength)
+	}
+
+	if s.MaxLength != nil && length > *s.MaxLength {
+		fail("must be at most %v characters", *s.MaxLength)
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err == nil && !re.MatchString(str) {
+			fail("must match the pattern '%v'", s.Pattern)
+		}
+	}
+
+	var valid bool
+	switch s.Format {
+	case "date":
+		_, err := time.Parse("2006-01-02", str)
+		valid = err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, str)
+		valid = err == nil
+	case "uuid":
+		valid = uuidRegex.MatchString(str)
+	case "email":
+		valid = strings.Contains(str, "@")
+	default:
+		return errs
+	}
+
+	if !valid {
+		fail("expected a %v, but was '%v'", s.Format, str)
+	}
+	return errs
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	actual, _ := json.Marshal(v)
+	for _, e := range enum {
+		expected, _ := json.Marshal(e)
+		if bytes.Equal(actual, expected) {
+			return true
+		}
+
+		if n, ok := v.(json.Number); ok { // i.e. 1.0 and 1
+			f, err := n.Float64()
+			if ef, isFloat := e.(float64); err == nil && isFloat && ef == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func describeEnum(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		out, _ := json.Marshal(e)
+		values[i] = string(out)
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,251 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validationTestYAML = `
+openapi: 3.0.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /content/latest:
+    get:
+      responses:
+        "200":
+          description: The latest content
+  /content/{uuid}:
+    parameters:
+      - name: uuid
+        in: path
+        required: true
+        schema:
+          type: string
+          format: uuid
+    get:
+      parameters:
+        - name: X-Request-Id
+          in: header
+          required: true
+          schema:
+            type: string
+            pattern: ^tid_
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            maximum: 10
+      responses:
+        "200":
+          description: The content
+    put:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [title, type]
+              additionalProperties:
+                type: string
+              properties:
+                title:
+                  type: string
+                  minLength: 1
+                type:
+                  type: string
+                  enum: [article, video]
+                tags:
+                  type: array
+                  maxItems: 2
+                  items:
+                    type: string
+                published:
+                  type: string
+                  format: date-time
+                  nullable: true
+      responses:
+        "200":
+          description: Updated
+  /search:
+    post:
+      requestBody:
+        content:
+          application/x-www-form-urlencoded:
+            schema:
+              type: object
+              required: [q]
+              properties:
+                q:
+                  type: string
+                page:
+                  type: integer
+                  minimum: 1
+      responses:
+        "200":
+          description: Results
+`
+
+const validUUID = "7fa7b1d0-2f1e-4c5a-9a3e-1c1f4f6b2f10"
+
+func TestFind(t *testing.T) {
+	d, err := Load([]byte(validationTestYAML))
+	require.NoError(t, err)
+	assert.Equal(t, "/v1", d.BasePath)
+
+	op, params, ok := d.Find("GET", "/v1/content/latest")
+	require.True(t, ok)
+	assert.Equal(t, "/content/latest", op.Path)
+	assert.Empty(t, params)
+
+	op, params, ok = d.Find("GET", "/v1/content/1234")
+	require.True(t, ok)
+	assert.Equal(t, "/content/{uuid}", op.Path)
+	assert.Equal(t, map[string]string{"uuid": "1234"}, params)
+
+	_, _, ok = d.Find("GET", "/content/1234")
+	assert.False(t, ok)
+
+	_, _, ok = d.Find("DELETE", "/v1/content/1234")
+	assert.False(t, ok)
+}
+
+func TestValidate__Parameters(t *testing.T) {
+	d, err := Load([]byte(validationTestYAML))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("GET", "/v1/content/"+validUUID+"?limit=5", nil)
+	r.Header.Set("X-Request-Id", "tid_1234")
+	assert.Empty(t, d.Validate(r))
+
+	r = httptest.NewRequest("GET", "/v1/content/1234?limit=eleven", nil)
+	assert.Equal(t, []ValidationError{
+		{Location: "path", Name: "uuid", Message: "expected a uuid, but was '1234'"},
+		{Location: "header", Name: "X-Request-Id", Message: "is required"},
+		{Location: "query", Name: "limit", Message: "expected a number, but was 'eleven'"},
+	}, d.Validate(r))
+
+	r = httptest.NewRequest("GET", "/v1/content/"+validUUID+"?limit=11", nil)
+	r.Header.Set("X-Request-Id", "1234")
+	assert.Equal(t, []ValidationError{
+		{Location: "header", Name: "X-Request-Id", Message: "must match the pattern '^tid_'"},
+		{Location: "query", Name: "limit", Message: "must be at most 10"},
+	}, d.Validate(r))
+
+	assert.Equal(t, []ValidationError{{Location: "path", Message: "no operation is declared for DELETE /v1/content/1234"}}, d.Validate(httptest.NewRequest("DELETE", "/v1/content/1234", nil)))
+}
+
+func TestValidate__HeadAndOptions(t *testing.T) {
+	d, err := Load([]byte(validationTestYAML))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest("HEAD", "/v1/content/"+validUUID, nil)
+	r.Header.Set("X-Request-Id", "tid_1234")
+	assert.Empty(t, d.Validate(r))
+	assert.Equal(t, []ValidationError{{Location: "header", Name: "X-Request-Id", Message: "is required"}}, d.Validate(httptest.NewRequest("HEAD", "/v1/content/"+validUUID, nil)))
+
+	assert.Empty(t, d.Validate(httptest.NewRequest("OPTIONS", "/v1/content/1234", nil)))
+	assert.Equal(t, []ValidationError{{Location: "path", Message: "no operation is declared for OPTIONS /v1/missing"}}, d.Validate(httptest.NewRequest("OPTIONS", "/v1/missing", nil)))
+	assert.Equal(t, []ValidationError{{Location: "path", Message: "no operation is declared for HEAD /v1/missing"}}, d.Validate(httptest.NewRequest("HEAD", "/v1/missing", nil)))
+}
+
+func TestValidate__JSONBody(t *testing.T) {
+	d, err := Load([]byte(validationTestYAML))
+	require.NoError(t, err)
+
+	put := func(body string, contentType string) []ValidationError {
+		r := httptest.NewRequest("PUT", "/v1/content/"+validUUID, strings.NewReader(body))
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		return d.Validate(r)
+	}
+
+	assert.Empty(t, put(`{"title":"Example","type":"article","tags":["news"],"published":null,"extra":"ok"}`, "application/json"))
+	assert.Empty(t, put(`{"title":"Example","type":"video"}`, ""))
+
+	assert.Equal(t, []ValidationError{{Location: "body", Message: "is required"}}, put(``, "application/json"))
+	assert.Equal(t, []ValidationError{{Location: "body", Message: "is not valid json"}}, put(`{`, "application/json"))
+	assert.Equal(t, []ValidationError{{Location: "header", Name: "Content-Type", Message: "'text/plain' is not one of application/json"}}, put(`title`, "text/plain"))
+
+	assert.Equal(t, []ValidationError{
+		{Location: "body", Name: "$.type", Message: "is required"},
+		{Location: "body", Name: "$.extra", Message: "expected a string"},
+		{Location: "body", Name: "$.published", Message: "expected a date-time, but was 'yesterday'"},
+		{Location: "body", Name: "$.tags", Message: "must have at most 2 items"},
+		{Location: "body", Name: "$.tags[2]", Message: "expected a string"},
+		{Location: "body", Name: "$.title", Message: "must be at least 1 characters"},
+	}, put(`{"title":"","tags":["a","b",3],"published":"yesterday","extra":1}`, "application/json"))
+
+	assert.Equal(t, []ValidationError{{Location: "body", Name: "$.type", Message: `must be one of "article", "video"`}}, put(`{"title":"Example","type":"podcast"}`, "application/json"))
+}
+
+func TestValidate__FormBody(t *testing.T) {
+	d, err := Load([]byte(validationTestYAML))
+	require.NoError(t, err)
+
+	post := func(body string) []ValidationError {
+		r := httptest.NewRequest("POST", "/v1/search", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return d.Validate(r)
+	}
+
+	assert.Empty(t, post(`q=news&page=2`))
+	assert.Empty(t, post(``))
+	assert.Equal(t, []ValidationError{
+		{Location: "body", Name: "q", Message: "is required"},
+		{Location: "body", Name: "page", Message: "must be at least 1"},
+	}, post(`page=0`))
+}
+
+func TestValidate__SchemaCombinations(t *testing.T) {
+	s := &Schema{OneOf: []*Schema{{Type: "string"}, {Type: "string", MaxLength: intPtr(3)}}}
+	assert.Len(t, s.validate("body", "$", "abc"), 1)
+	assert.Empty(t, s.validate("body", "$", "abcd"))
+
+	s = &Schema{AnyOf: []*Schema{{Type: "boolean"}, {Type: "integer"}}}
+	assert.Empty(t, s.validate("body", "$", json.Number("1")))
+	assert.Len(t, s.validate("body", "$", json.Number("1.5")), 1)
+
+	s = &Schema{Not: &Schema{Type: "string"}}
+	assert.Len(t, s.validate("body", "$", "abc"), 1)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidatorMiddleware(t *testing.T) {
+	d, err := Load([]byte(validationTestYAML))
+	require.NoError(t, err)
+
+	j := journal.New(0)
+	v := &Validator{Document: d, Status: http.StatusUnprocessableEntity}
+	handler := j.Record(v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/content/latest", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/v1/content/1234", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	report := ValidationReport{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Len(t, report.Errors, 2)
+
+	entries := j.Entries(journal.Filter{Status: http.StatusUnprocessableEntity})
+	require.Len(t, entries, 1)
+	assert.Equal(t, report, entries[0].Validation)
+}
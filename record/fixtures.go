@@ -0,0 +1,269 @@
+package record
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+// missing is the v2 templated value used when a discriminating value is absent from a request
+const missing = "${missing}"
+
+type fixturesFile struct {
+	Version  string                            `json:"version"`
+	Fixtures map[string]map[string]interface{} `json:"fixtures"`
+}
+
+type response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+
+	Base64Body string `json:"base64Body,omitempty"`
+}
+
+type sequence struct {
+	Sequence []response `json:"sequence"`
+}
+
+type discriminator struct {
+	When     when       `json:"when"`
+	Response *response  `json:"response,omitempty"`
+	Sequence []response `json:"sequence,omitempty"`
+}
+
+type when struct {
+	Headers     map[string]string `json:"headers,omitempty"`
+	QueryParams map[string]string `json:"queryParams,omitempty"`
+	Body        *body             `json:"body,omitempty"`
+}
+
+type body struct {
+	JSON map[string]string `json:"json"`
+}
+
+// key identifies a value in the request which may discriminate between requests
+type key struct {
+	location string
+	name     string
+}
+
+// Fixtures generates a 2.0.0 ersatz-fixtures.yml from the exchanges. Requests to the same path and method which received different responses are told apart using discriminators on the headers, query parameters and top level JSON body fields which differ between them. Requests which cannot be told apart are given a sequence of responses.
+func Fixtures(exchanges []Exchange, opts Options) ([]byte, error) {
+	file := fixturesFile{Version: "2.0.0", Fixtures: make(map[string]map[string]interface{})}
+
+	groups := make(map[key][]Exchange)
+	order := make([]key, 0)
+	for _, e := range exchanges {
+		k := key{location: e.Path, name: strings.ToLower(e.Method)}
+		if _, ok := groups[k]; !ok {
+			order = append(order, k)
+		}
+		groups[k] = append(groups[k], e)
+	}
+
+	for _, k := range order {
+		if _, ok := file.Fixtures[k.location]; !ok {
+			file.Fixtures[k.location] = make(map[string]interface{})
+		}
+		file.Fixtures[k.location][k.name] = resource(groups[k], opts)
+	}
+
+	return yaml.Marshal(file)
+}
+
+// resource generates the fixture for requests to a single path and method
+func resource(exchanges []Exchange, opts Options) interface{} {
+	responses := make([]response, len(exchanges))
+	for i, e := range exchanges {
+		responses[i] = recordResponse(e, opts)
+	}
+
+	if allEqual(responses) {
+		return responses[0]
+	}
+
+	values := make([]map[key]string, len(exchanges))
+	for i, e := range exchanges {
+		values[i] = requestValues(e, opts)
+	}
+	keys := distinguishingKeys(values)
+
+	if len(keys) == 0 {
+		return sequence{Sequence: responses}
+	}
+
+	discriminators := make([]discriminator, 0)
+	signatures := make(map[string]int)
+	for i := range exchanges {
+		sig := signature(keys, values[i])
+		j, ok := signatures[sig]
+		if !ok {
+			j = len(discriminators)
+			signatures[sig] = j
+			discriminators = append(discriminators, discriminator{When: newWhen(keys, values[i])})
+		}
+		discriminators[j].Sequence = append(discriminators[j].Sequence, responses[i])
+	}
+
+	for i, d := range discriminators {
+		if allEqual(d.Sequence) {
+			discriminators[i].Response = &d.Sequence[0]
+			discriminators[i].Sequence = nil
+		}
+	}
+	return discriminators
+}
+
+// requestValues picks out every value in the request which could be used in a discriminator
+func requestValues(e Exchange, opts Options) map[key]string {
+	values := make(map[key]string)
+	for name := range e.Headers {
+		if opts.discriminates(name) {
+			values[key{location: "headers", name: http.CanonicalHeaderKey(name)}] = e.Headers.Get(name)
+		}
+	}
+
+	for name := range e.Query {
+		values[key{location: "queryParams", name: name}] = e.Query.Get(name)
+	}
+
+	doc := make(map[string]interface{})
+	dec := json.NewDecoder(bytes.NewReader(e.Body))
+	dec.UseNumber()
+	if len(e.Body) > 0 && dec.Decode(&doc) == nil {
+		for name, v := range doc {
+			if strings.ContainsAny(name, ".[]'\"") {
+				continue
+			}
+			values[key{location: "body", name: "$." + name}] = v2.Stringify(v)
+		}
+	}
+	return values
+}
+
+// distinguishingKeys returns the keys whose values are not the same in every request, sorted by location and name
+func distinguishingKeys(values []map[key]string) []key {
+	all := make(map[key]bool)
+	for _, v := range values {
+		for k := range v {
+			all[k] = true
+		}
+	}
+
+	keys := make([]key, 0)
+	for k := range all {
+		for _, v := range values {
+			if v[k] != values[0][k] {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].location != keys[j].location {
+			return keys[i].location < keys[j].location
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
+
+func signature(keys []key, values map[key]string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		v, ok := values[k]
+		if !ok {
+			v = missing
+		}
+		parts[i] = v
+	}
+
+	sig, _ := json.Marshal(parts)
+	return string(sig)
+}
+
+func newWhen(keys []key, values map[key]string) when {
+	w := when{}
+	for _, k := range keys {
+		v, ok := values[k]
+		if !ok {
+			v = missing
+		}
+
+		switch k.location {
+		case "headers":
+			if w.Headers == nil {
+				w.Headers = make(map[string]string)
+			}
+			w.Headers[k.name] = v
+		case "queryParams":
+			if w.QueryParams == nil {
+				w.QueryParams = make(map[string]string)
+			}
+			w.QueryParams[k.name] = v
+		case "body":
+			if w.Body == nil {
+				w.Body = &body{JSON: make(map[string]string)}
+			}
+			w.Body.JSON[k.name] = v
+		}
+	}
+	return w
+}
+
+// recordResponse converts the upstream response into a fixture response. JSON bodies are recorded as yaml, textual bodies as strings, and binary bodies (or JSON bodies which cannot be parsed) as base64. The upstream Content-Type is kept, so the body is encoded in the same way when it is served.
+func recordResponse(e Exchange, opts Options) response {
+	res := response{Status: e.Status, Headers: make(map[string]string)}
+	for name := range e.ResponseHeaders {
+		if opts.allowed(name) {
+			res.Headers[strings.ToLower(name)] = e.ResponseHeaders.Get(name)
+		}
+	}
+
+	if len(e.ResponseBody) == 0 {
+		return res
+	}
+
+	contentType := res.Headers["content-type"]
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		dec := json.NewDecoder(bytes.NewReader(e.ResponseBody))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err == nil {
+			res.Body = v
+			return res
+		}
+		res.Base64Body = base64.StdEncoding.EncodeToString(e.ResponseBody)
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml") || mediaType == "application/x-yaml":
+		res.Body = string(e.ResponseBody)
+	default:
+		res.Base64Body = base64.StdEncoding.EncodeToString(e.ResponseBody)
+	}
+	return res
+}
+
+func allEqual(responses []response) bool {
+	first, _ := json.Marshal(responses[0])
+	for _, r := range responses[1:] {
+		next, _ := json.Marshal(r)
+		if !bytes.Equal(first, next) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package record
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func jsonExchange(method string, path string, query string, headers map[string]string, reqBody string, status int, resBody string) Exchange {
+	q, _ := url.ParseQuery(query)
+	h := make(http.Header)
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+
+	return Exchange{
+		Method:          method,
+		Path:            path,
+		Query:           q,
+		Headers:         h,
+		Body:            []byte(reqBody),
+		Status:          status,
+		ResponseHeaders: http.Header{"Content-Type": []string{"application/json; charset=utf-8"}, "Date": []string{"Mon, 01 Jan 2018 00:00:00 GMT"}},
+		ResponseBody:    []byte(resBody),
+	}
+}
+
+func loadFixtures(t *testing.T, yml []byte) v2.Fixtures {
+	f := struct {
+		Version  string      `json:"version"`
+		Fixtures v2.Fixtures `json:"fixtures"`
+	}{}
+	require.NoError(t, yaml.Unmarshal(yml, &f))
+	assert.Equal(t, "2.0.0", f.Version)
+	return f.Fixtures
+}
+
+func TestFixtures__SingleResponse(t *testing.T) {
+	exchanges := []Exchange{
+		jsonExchange("GET", "/__health", "", map[string]string{"X-Request-Id": "tid_1"}, "", 200, `{"ok":true}`),
+		jsonExchange("GET", "/__health", "", map[string]string{"X-Request-Id": "tid_2"}, "", 200, `{"ok":true}`),
+	}
+
+	yml, err := Fixtures(exchanges, Options{DenyHeaders: DefaultDenyHeaders})
+	require.NoError(t, err)
+
+	assert.Equal(t, `fixtures:
+  /__health:
+    get:
+      body:
+        ok: true
+      headers:
+        content-type: application/json; charset=utf-8
+      status: 200
+version: 2.0.0
+`, string(yml))
+}
+
+func TestFixtures__Discriminators(t *testing.T) {
+	exchanges := []Exchange{
+		jsonExchange("GET", "/search", "q=news", map[string]string{"X-Tenant": "ft"}, "", 200, `{"results":[1]}`),
+		jsonExchange("GET", "/search", "", map[string]string{"X-Tenant": "ft"}, "", 400, `{"message":"missing q"}`),
+		jsonExchange("GET", "/search", "q=news", map[string]string{"X-Tenant": "other", "Authorization": "Bearer secret"}, "", 403, `{}`),
+		jsonExchange("POST", "/content", "", nil, `{"title":"Example","type":"article"}`, 201, `{}`),
+		jsonExchange("POST", "/content", "", nil, `{"type":"article"}`, 400, `{}`),
+	}
+
+	yml, err := Fixtures(exchanges, Options{DenyHeaders: DefaultDenyHeaders})
+	require.NoError(t, err)
+	assert.NotContains(t, string(yml), "secret")
+
+	f := loadFixtures(t, yml)
+
+	search := f["/search"]["get"].Discriminators
+	require.Len(t, search, 3)
+	assert.Equal(t, "news", search[0].When.QueryParams.Get("q"))
+	assert.Equal(t, "ft", search[0].When.Headers.Get("X-Tenant"))
+	assert.Equal(t, 400, search[1].Response.Status)
+	assert.Equal(t, 403, search[2].Response.Status)
+
+	content := f["/content"]["post"].Discriminators
+	require.Len(t, content, 2)
+
+	r := httptest.NewRequest("POST", "/content", strings.NewReader(`{"type":"article"}`))
+	assert.False(t, content[0].When.SatisfiesDiscriminator(r))
+	assert.True(t, content[1].When.SatisfiesDiscriminator(r))
+}
+
+func TestFixtures__Sequence(t *testing.T) {
+	exchanges := []Exchange{
+		jsonExchange("GET", "/jobs/1", "", nil, "", 202, `{}`),
+		jsonExchange("GET", "/jobs/1", "", nil, "", 200, `{"done":true}`),
+	}
+
+	yml, err := Fixtures(exchanges, Options{})
+	require.NoError(t, err)
+
+	seq := loadFixtures(t, yml)["/jobs/1"]["get"].Sequence.Responses
+	require.Len(t, seq, 2)
+	assert.Equal(t, 202, seq[0].Status)
+	assert.Equal(t, 200, seq[1].Status)
+}
+
+func TestFixtures__AllowHeaders(t *testing.T) {
+	exchanges := []Exchange{
+		jsonExchange("GET", "/content", "", map[string]string{"X-Tenant": "ft", "X-Trace": "1"}, "", 200, `{}`),
+		jsonExchange("GET", "/content", "", map[string]string{"X-Tenant": "other", "X-Trace": "2"}, "", 404, `{}`),
+	}
+
+	yml, err := Fixtures(exchanges, Options{AllowHeaders: []string{"X-Tenant"}})
+	require.NoError(t, err)
+	assert.Contains(t, string(yml), "X-Tenant")
+	assert.NotContains(t, string(yml), "X-Trace")
+}
+
+func TestRecordResponse__Text(t *testing.T) {
+	e := Exchange{Status: 200, ResponseHeaders: http.Header{"Content-Type": []string{"text/html"}}, ResponseBody: []byte("<html></html>")}
+	assert.Equal(t, response{Status: 200, Headers: map[string]string{"content-type": "text/html"}, Body: "<html></html>"}, recordResponse(e, Options{}))
+
+	e = Exchange{Status: 200, ResponseHeaders: http.Header{"Content-Type": []string{"image/png"}}, ResponseBody: []byte{0x89, 0x50}}
+	res := recordResponse(e, Options{})
+	assert.Nil(t, res.Body)
+	assert.Equal(t, "iVA=", res.Base64Body)
+}
+
+func TestRecordResponse__JSONMediaTypes(t *testing.T) {
+	e := Exchange{Status: 200, ResponseHeaders: http.Header{"Content-Type": []string{"application/problem+json"}}, ResponseBody: []byte(`{"title":"Not Found","status":404}`)}
+	res := recordResponse(e, Options{})
+	assert.Equal(t, "application/problem+json", res.Headers["content-type"])
+	assert.Equal(t, map[string]interface{}{"title": "Not Found", "status": json.Number("404")}, res.Body)
+
+	e = Exchange{Status: 200, ResponseHeaders: http.Header{"Content-Type": []string{"application/hal+json"}}, ResponseBody: []byte(`{"title":`)}
+	res = recordResponse(e, Options{})
+	assert.Equal(t, "application/hal+json", res.Headers["content-type"])
+	assert.Nil(t, res.Body)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(`{"title":`)), res.Base64Body, "invalid JSON should be replayed as it was received")
+}
@@ -0,0 +1,201 @@
+package record
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultDenyHeaders are never recorded, as they either contain credentials, or change on every request
+var DefaultDenyHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Request-Id",
+	"User-Agent",
+	"Accept-Encoding",
+	"Connection",
+	"Content-Length",
+	"Date",
+	"Transfer-Encoding",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+}
+
+// Options configures which headers are recorded
+type Options struct {
+	// AllowHeaders restricts the request headers which can be used to discriminate between requests. If empty, any header not denied can be used.
+	AllowHeaders []string
+	// DenyHeaders are never recorded, in either requests or responses. These are used in addition to the DefaultDenyHeaders.
+	DenyHeaders []string
+}
+
+// Exchange is a single request proxied to the upstream, and the response it returned
+type Exchange struct {
+	Method          string
+	Path            string
+	Query           url.Values
+	Headers         http.Header
+	Body            []byte
+	Status          int
+	ResponseHeaders http.Header
+	ResponseBody    []byte
+}
+
+// Recorder proxies every request to the upstream, and writes the fixtures generated from the exchanges to the output file
+type Recorder struct {
+	sync.Mutex
+	proxy     *httputil.ReverseProxy
+	output    string
+	options   Options
+	exchanges []Exchange
+}
+
+// New creates a Recorder which proxies requests to the target, and writes fixtures to the output file after every exchange
+func New(target *url.URL, output string, opts Options) *Recorder {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+		r.Header.Del("Accept-Encoding") // so response bodies can be recorded without decompressing them
+	}
+
+	opts.DenyHeaders = append(append([]string{}, DefaultDenyHeaders...), opts.DenyHeaders...)
+	return &Recorder{proxy: proxy, output: output, options: opts, exchanges: make([]Exchange, 0)}
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e := Exchange{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Headers: copyHeader(r.Header),
+	}
+
+	if r.Body != nil {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			log.WithError(err).Error("Failed to read request body")
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		e.Body = body
+	}
+
+	capture := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+	rec.proxy.ServeHTTP(capture, r)
+
+	e.Status = capture.status
+	e.ResponseHeaders = copyHeader(capture.Header())
+	e.ResponseBody = capture.body.Bytes()
+	rec.add(e)
+}
+
+func (rec *Recorder) add(e Exchange) {
+	rec.Lock()
+	defer rec.Unlock()
+
+	rec.exchanges = append(rec.exchanges, e)
+	log.WithField("method", e.Method).WithField("path", e.Path).WithField("status", e.Status).Info("Recorded request")
+
+	yml, err := Fixtures(rec.exchanges, rec.options)
+	if err != nil {
+		log.WithError(err).Error("Failed to generate fixtures")
+		return
+	}
+
+	if err := writeFile(rec.output, yml); err != nil {
+		log.WithError(err).Error("Failed to write fixtures file")
+	}
+}
+
+// Exchanges returns every exchange recorded so far
+func (rec *Recorder) Exchanges() []Exchange {
+	rec.Lock()
+	defer rec.Unlock()
+	return append([]Exchange{}, rec.exchanges...)
+}
+
+func copyHeader(h http.Header) http.Header {
+	c := make(http.Header)
+	for k, v := range h {
+		c[k] = append([]string{}, v...)
+	}
+	return c
+}
+
+// writeFile replaces the file, so it is never left partially written
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// allowed returns true if the header can be recorded
+func (o Options) allowed(header string) bool {
+	for _, h := range o.DenyHeaders {
+		if strings.EqualFold(h, header) {
+			return false
+		}
+	}
+	return true
+}
+
+// discriminates returns true if the request header can be used to discriminate between requests
+func (o Options) discriminates(header string) bool {
+	if !o.allowed(header) {
+		return false
+	}
+
+	if len(o.AllowHeaders) == 0 {
+		return true
+	}
+
+	for _, h := range o.AllowHeaders {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+	return false
+}
+
+type responseCapture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	c.wroteHeader = true
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
@@ -0,0 +1,67 @@
+package record
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, `{"title":"Example"}`, string(body))
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"1234"}`))
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "_ft", "ersatz-fixtures.yml")
+	recorder := New(target, output, Options{})
+
+	r := httptest.NewRequest("POST", "/content", strings.NewReader(`{"title":"Example"}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	recorder.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `{"id":"1234"}`, w.Body.String())
+
+	exchanges := recorder.Exchanges()
+	require.Len(t, exchanges, 1)
+	assert.Equal(t, http.StatusCreated, exchanges[0].Status)
+	assert.Equal(t, `{"title":"Example"}`, string(exchanges[0].Body))
+
+	yml, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(yml), "/content:")
+	assert.Contains(t, string(yml), "id: \"1234\"")
+	assert.NotContains(t, string(yml), "secret")
+}
+
+func TestOptions(t *testing.T) {
+	opts := Options{AllowHeaders: []string{"X-Tenant"}, DenyHeaders: []string{"authorization"}}
+
+	assert.False(t, opts.allowed("Authorization"))
+	assert.True(t, opts.allowed("Content-Type"))
+	assert.True(t, opts.discriminates("x-tenant"))
+	assert.False(t, opts.discriminates("X-Trace"))
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/husobee/vestigo"
+	"github.com/peteclark-ft/ersatz/journal"
+	log "github.com/sirupsen/logrus"
+)
+
+// admin serves the /__admin API, which allows fixtures to be changed at runtime, and received requests to be inspected
+type admin struct {
+	config  *configuration
+	journal *journal.Journal
+}
+
+func newAdminRouter(config *configuration, j *journal.Journal) *vestigo.Router {
+	a := &admin{config: config, journal: j}
+
+	r := vestigo.NewRouter()
+	r.Get("/__admin/fixtures", a.getFixtures)
+	r.Put("/__admin/fixtures", a.replaceFixtures)
+	r.Post("/__admin/fixtures/reset", a.resetFixtures)
+	r.Put("/__admin/fixtures/resource", a.upsertResource)
+	r.Delete("/__admin/fixtures/resource", a.removeResource)
+	r.Get("/__admin/scenarios", a.getScenarios)
+	r.Put("/__admin/scenarios", a.setScenario)
+	r.Post("/__admin/scenarios/reset", a.resetScenarios)
+	r.Get("/__admin/requests", a.getRequests)
+	r.Delete("/__admin/requests", a.resetRequests)
+	r.Get("/__admin/requests/verify", a.verifyRequests)
+	r.Get("/__admin/requests/unmatched", a.getUnmatchedRequests)
+	return r
+}
+
+func (a *admin) getFixtures(w http.ResponseWriter, req *http.Request) {
+	yml, err := a.config.Current()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-yaml")
+	w.Write(yml)
+}
+
+func (a *admin) replaceFixtures(w http.ResponseWriter, req *http.Request) {
+	yml, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithError(err).Error("Failed to read request body")
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.config.Replace(yml); err != nil {
+		log.WithError(err).Error("Failed to replace fixtures")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Info("Replaced fixtures via the admin api")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *admin) resetFixtures(w http.ResponseWriter, req *http.Request) {
+	if err := a.config.Reset(); err != nil {
+		log.WithError(err).Error("Failed to reset fixtures")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Reset fixtures via the admin api")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *admin) upsertResource(w http.ResponseWriter, req *http.Request) {
+	path, method := req.URL.Query().Get("path"), strings.ToLower(req.URL.Query().Get("method"))
+	if path == "" || method == "" {
+		http.Error(w, "Please provide both the 'path' and 'method' query parameters", http.StatusBadRequest)
+		return
+	}
+
+	yml, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		log.WithError(err).Error("Failed to read request body")
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if err = a.config.Upsert(req.URL.Query().Get("host"), path, method, yml); err != nil {
+		log.WithError(err).WithField("path", path).WithField("method", method).Error("Failed to add fixture")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.WithField("path", path).WithField("method", method).Info("Added fixture via the admin api")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *admin) removeResource(w http.ResponseWriter, req *http.Request) {
+	path, method := req.URL.Query().Get("path"), strings.ToLower(req.URL.Query().Get("method"))
+	if path == "" {
+		http.Error(w, "Please provide the 'path' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err := a.config.Remove(req.URL.Query().Get("host"), path, method); err != nil {
+		log.WithError(err).WithField("path", path).WithField("method", method).Error("Failed to remove fixture")
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	log.WithField("path", path).WithField("method", method).Info("Removed fixture via the admin api")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *admin) getScenarios(w http.ResponseWriter, req *http.Request) {
+	state, err := a.config.State()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, state.Scenarios())
+}
+
+func (a *admin) setScenario(w http.ResponseWriter, req *http.Request) {
+	scenario, newState := req.URL.Query().Get("scenario"), req.URL.Query().Get("state")
+	if scenario == "" || newState == "" {
+		http.Error(w, "Please provide both the 'scenario' and 'state' query parameters", http.StatusBadRequest)
+		return
+	}
+
+	state, err := a.config.State()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	state.SetScenario(scenario, newState)
+	log.WithField("scenario", scenario).WithField("state", newState).Info("Set scenario state via the admin api")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *admin) resetScenarios(w http.ResponseWriter, req *http.Request) {
+	state, err := a.config.State()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	state.Reset()
+	log.Info("Reset scenarios via the admin api")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *admin) getRequests(w http.ResponseWriter, req *http.Request) {
+	f, err := journal.FilterFromQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, a.journal.Entries(f))
+}
+
+// unmatchedRequest is a journalled request which could not be matched to a fixture, along with the fixture path it most closely resembles
+type unmatchedRequest struct {
+	journal.Entry
+	ClosestFixture string `json:"closestFixture,omitempty"`
+}
+
+func (a *admin) getUnmatchedRequests(w http.ResponseWriter, req *http.Request) {
+	f, err := journal.FilterFromQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.Unmatched = true
+
+	unmatched := make([]unmatchedRequest, 0)
+	for _, e := range a.journal.Entries(f) {
+		u := unmatchedRequest{Entry: e}
+		if e.Fixture != nil {
+			u.ClosestFixture = e.Fixture.Path
+		} else if closest, ok := a.config.ClosestFixture(e.Host, e.Path); ok {
+			u.ClosestFixture = closest
+		}
+		unmatched = append(unmatched, u)
+	}
+
+	writeJSON(w, http.StatusOK, unmatched)
+}
+
+func (a *admin) resetRequests(w http.ResponseWriter, req *http.Request) {
+	a.journal.Reset()
+	log.Info("Cleared the request journal via the admin api")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *admin) verifyRequests(w http.ResponseWriter, req *http.Request) {
+	v, err := journal.VerificationFromQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res := a.journal.Verify(v)
+	if !res.OK {
+		writeJSON(w, http.StatusExpectationFailed, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Error("Failed to write admin api response")
+	}
+}
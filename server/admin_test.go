@@ -0,0 +1,196 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin__ReplaceAndGetFixtures(t *testing.T) {
+	c := newConfiguration()
+	r := newAdminRouter(c, journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/fixtures", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/__admin/fixtures", strings.NewReader(startupFixturesTestYAML)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/fixtures", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-yaml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "/__health")
+}
+
+func TestAdmin__ReplaceInvalidFixtures(t *testing.T) {
+	r := newAdminRouter(newConfiguration(), journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/__admin/fixtures", strings.NewReader("version: 0.0.1")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/__admin/fixtures", strings.NewReader(invalidTemplateFixturesTestYAML)))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "templates should be parsed when the fixtures are loaded")
+	assert.Contains(t, w.Body.String(), "invalid response template")
+}
+
+func TestAdmin__UpsertRemoveAndReset(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+	r := newAdminRouter(c, journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/__admin/fixtures/resource?path=/__gtg&method=GET", strings.NewReader("status: 200")))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__gtg"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/__admin/fixtures/resource?path=/__health", nil))
+	require.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__health"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/__admin/fixtures/reset", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__gtg"))
+}
+
+func TestAdmin__UpsertRequiresPathAndMethod(t *testing.T) {
+	r := newAdminRouter(newConfiguration(), journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/__admin/fixtures/resource?path=/__gtg", strings.NewReader("status: 200")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdmin__RemoveUnknownPath(t *testing.T) {
+	r := newAdminRouter(newConfiguration(), journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/__admin/fixtures/resource?path=/__gtg", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdmin__RequestsAndVerify(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+
+	j := journal.New(0)
+	r := newAdminRouter(c, j)
+	fixtures := j.Record(c)
+
+	serve(fixtures, "GET", "/__health")
+	serve(fixtures, "GET", "/__health")
+	serve(fixtures, "GET", "/__gtg")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/requests?fixture=/__health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	entries := make([]journal.Entry, 0)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 2)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/requests/verify?fixture=/__health&count=2", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/requests/verify?path=/__gtg&status=404&atMost=0", nil))
+	assert.Equal(t, http.StatusExpectationFailed, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("DELETE", "/__admin/requests", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, j.Entries(journal.Filter{}))
+}
+
+func TestAdmin__UnmatchedRequests(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(`
+version: 2.0.0
+fixtures:
+  /content/{uuid}:
+    get:
+      - when:
+          headers:
+            X-User: ${exists}
+        response:
+          status: 200
+`)))
+
+	j := journal.New(0)
+	r := newAdminRouter(c, j)
+	fixtures := j.Record(c)
+
+	serve(fixtures, "GET", "/content/1234")
+	serve(fixtures, "GET", "/content")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/requests/unmatched", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	unmatched := make([]unmatchedRequest, 0)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &unmatched))
+	require.Len(t, unmatched, 2)
+
+	assert.Equal(t, "/content/1234", unmatched[0].Path)
+	assert.Equal(t, http.StatusNotImplemented, unmatched[0].Status)
+	assert.NotNil(t, unmatched[0].Diagnostics)
+	assert.Equal(t, "/content/{uuid}", unmatched[0].ClosestFixture)
+
+	assert.Equal(t, "/content", unmatched[1].Path)
+	assert.Equal(t, http.StatusNotFound, unmatched[1].Status)
+	assert.Equal(t, "/content/{uuid}", unmatched[1].ClosestFixture)
+}
+
+func TestAdmin__Scenarios(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(`
+version: 2.0.0
+fixtures:
+  /content:
+    get:
+      - scenario: content
+        requiredState: Deleted
+        response:
+          status: 404
+      - response:
+          status: 200
+`)))
+	r := newAdminRouter(c, journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("PUT", "/__admin/scenarios?scenario=content&state=Deleted", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/content"))
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/scenarios", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"content":"Deleted"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/__admin/scenarios/reset", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/content"))
+}
+
+func TestAdmin__ScenariosWithoutFixtures(t *testing.T) {
+	r := newAdminRouter(newConfiguration(), journal.New(0))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/__admin/scenarios", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,401 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Financial-Times/http-handlers-go/httphandlers"
+	"github.com/ghodss/yaml"
+	"github.com/husobee/vestigo"
+	"github.com/peteclark-ft/ersatz/grpc"
+	"github.com/peteclark-ft/ersatz/v1"
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/peteclark-ft/ersatz/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrNoFixtures = errors.New("no fixtures have been configured")
+
+var ErrNoScenarios = errors.New("scenarios are only supported by 2.0.0 fixtures")
+
+// EmptyFixtures is used as the starting point when fixtures are added before any others have been configured
+var EmptyFixtures = []byte(`{"version":"2.0.0","fixtures":{}}`)
+
+// configuration holds the fixtures document currently in use, and serves requests using the router built from it. The router is swapped atomically, so in-flight requests complete using the fixtures they started with.
+type configuration struct {
+	sync.Mutex
+	startup  []byte
+	current  []byte
+	fixtures fixtures
+	hosts    map[string]virtualHost
+	state    *v2.State
+	options  v2.Options
+	router   atomic.Value
+	// services serves the gRPC services of the fixtures, if any are declared
+	services *grpc.Server
+
+	// dir is the directory of the fixtures file, which other files (i.e. OpenAPI documents) are relative to
+	dir string
+	// openapi is the OpenAPI document provided on startup, which stubs are generated from
+	openapi string
+	// validate and validationStatus enable request validation against the OpenAPI document on startup
+	validate         bool
+	validationStatus int
+	// sources are the other files read while applying the latest fixtures document, which are watched along with the fixtures file
+	sources []string
+}
+
+type routerHolder struct {
+	http.Handler
+}
+
+func newConfiguration() *configuration {
+	c := &configuration{}
+	c.router.Store(routerHolder{http.NotFoundHandler()})
+	return c
+}
+
+func (c *configuration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.router.Load().(routerHolder).ServeHTTP(w, r)
+}
+
+// ServeReflection serves a call to the gRPC reflection service, which is not recorded in the journal as it streams in both directions
+func (c *configuration) ServeReflection(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	services := c.services
+	c.Unlock()
+
+	if services == nil {
+		http.NotFound(w, r)
+		return
+	}
+	services.ServeHTTP(w, r)
+}
+
+// Startup loads the fixtures from the ersatz-fixtures.yml provided on startup, which are also used on reset
+func (c *configuration) Startup(yml []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	doc, err := yaml.YAMLToJSON(yml)
+	if err != nil {
+		return err
+	}
+
+	if err = c.apply(doc); err != nil {
+		return err
+	}
+
+	c.startup = doc
+	return nil
+}
+
+// Replace replaces every fixture with the provided ersatz-fixtures.yml
+func (c *configuration) Replace(yml []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	doc, err := yaml.YAMLToJSON(yml)
+	if err != nil {
+		return err
+	}
+	return c.apply(doc)
+}
+
+// Reset restores the fixtures provided on startup, or removes all fixtures if there were none
+func (c *configuration) Reset() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.startup == nil {
+		c.current = nil
+		c.fixtures = nil
+		c.hosts = nil
+		c.state = nil
+		c.services = nil
+		c.router.Store(routerHolder{http.NotFoundHandler()})
+		return nil
+	}
+	return c.apply(c.startup)
+}
+
+// Current returns the fixtures currently in use as yaml
+func (c *configuration) Current() ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.current == nil {
+		return nil, ErrNoFixtures
+	}
+	return yaml.JSONToYAML(c.current)
+}
+
+// ClosestFixture returns the configured fixture path which most closely resembles the request path, using the fixtures of the virtual host in the Host header if there is one
+func (c *configuration) ClosestFixture(host string, path string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, name := range hostNames(host) {
+		if h, ok := c.hosts[name]; ok {
+			if h.Fixtures == nil {
+				return "", false
+			}
+			return h.Fixtures.Closest(path)
+		}
+	}
+
+	f, ok := c.fixtures.(*v2.Fixtures)
+	if !ok {
+		return "", false
+	}
+	return f.Closest(path)
+}
+
+// Sources returns the files, other than the fixtures file, which were read while applying the latest fixtures document
+func (c *configuration) Sources() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	return append([]string{}, c.sources...)
+}
+
+// State returns the State of the scenarios for the fixtures currently in use
+func (c *configuration) State() (*v2.State, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.state == nil {
+		return nil, ErrNoScenarios
+	}
+	return c.state, nil
+}
+
+// Upsert adds or replaces the resource for a single path and method, of the virtual host if one is provided. If no fixtures have been configured yet, a 2.0.0 fixtures document is created.
+func (c *configuration) Upsert(host string, path string, method string, yml []byte) error {
+	resource, err := yaml.YAMLToJSON(yml)
+	if err != nil {
+		return err
+	}
+
+	return c.modify(host, true, func(fixtures map[string]interface{}) error {
+		p, ok := fixtures[path].(map[string]interface{})
+		if !ok {
+			p = make(map[string]interface{})
+			fixtures[path] = p
+		}
+
+		p[method] = json.RawMessage(resource)
+		return nil
+	})
+}
+
+// Remove removes the resource for a single path and method, or the whole path if no method is provided, of the virtual host if one is provided
+func (c *configuration) Remove(host string, path string, method string) error {
+	return c.modify(host, false, func(fixtures map[string]interface{}) error {
+		p, ok := fixtures[path].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("no fixtures configured for path '%v'", path)
+		}
+
+		if method == "" {
+			delete(fixtures, path)
+			return nil
+		}
+
+		if _, ok := p[method]; !ok {
+			return fmt.Errorf("no fixtures configured for method '%v' on path '%v'", method, path)
+		}
+
+		delete(p, method)
+		if len(p) == 0 {
+			delete(fixtures, path)
+		}
+		return nil
+	})
+}
+
+// modify changes the top level fixtures, or those of the virtual host if one is provided. Virtual hosts are only created if create is true.
+func (c *configuration) modify(host string, create bool, fn func(fixtures map[string]interface{}) error) error {
+	c.Lock()
+	defer c.Unlock()
+
+	current := c.current
+	if current == nil {
+		current = EmptyFixtures
+	}
+
+	doc := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(current))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+
+	parent := doc
+	if host != "" {
+		hosts, ok := doc["hosts"].(map[string]interface{})
+		if !ok {
+			hosts = make(map[string]interface{})
+			doc["hosts"] = hosts
+		}
+
+		for name := range hosts {
+			if strings.EqualFold(name, host) {
+				host = name
+			}
+		}
+
+		if parent, ok = hosts[host].(map[string]interface{}); !ok {
+			if !create {
+				return fmt.Errorf("no fixtures configured for host '%v'", host)
+			}
+			parent = make(map[string]interface{})
+			hosts[host] = parent
+		}
+	}
+
+	fixtures, ok := parent["fixtures"].(map[string]interface{})
+	if !ok {
+		fixtures = make(map[string]interface{})
+		parent["fixtures"] = fixtures
+	}
+
+	if err := fn(fixtures); err != nil {
+		return err
+	}
+
+	updated, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return c.apply(updated)
+}
+
+// apply builds a new router for the fixtures document and swaps it in. Callers must hold the lock.
+func (c *configuration) apply(doc []byte) error {
+	c.sources = nil
+	generated, err := c.withOpenAPI(doc)
+	if err != nil {
+		return err
+	}
+
+	ers := ersatz{}
+	if err := json.Unmarshal(generated, &ers); err != nil {
+		return err
+	}
+
+	if err := c.loadBodyFiles(ers); err != nil {
+		return err
+	}
+
+	router, state, err := newRouter(ers, c.options)
+	if err != nil {
+		return err
+	}
+
+	validator, err := c.validator(doc)
+	if err != nil {
+		return err
+	}
+
+	if validator != nil {
+		router = validator.Middleware(router)
+	}
+
+	var services *grpc.Server
+	if ers.GRPC != nil {
+		var files []string
+		services, files, err = grpc.NewServer(*ers.GRPC, c.dir)
+		c.sources = append(c.sources, files...)
+		if err != nil {
+			return err
+		}
+		router = services.Middleware(router)
+	}
+
+	if len(ers.WebSockets) > 0 {
+		router = websocket.NewServer(ers.WebSockets).Middleware(router)
+	}
+
+	c.current = doc
+	c.fixtures = ers.Fixtures
+	c.hosts = make(map[string]virtualHost)
+	for name, host := range ers.Hosts {
+		c.hosts[strings.ToLower(name)] = host
+	}
+	c.state = state
+	c.services = services
+	c.router.Store(routerHolder{router})
+	log.Info("Ready to simulate requests!")
+	return nil
+}
+
+// loadBodyFiles reads the body files of every response, relative to the fixtures file
+func (c *configuration) loadBodyFiles(ers ersatz) error {
+	fixtures := make([]*v2.Fixtures, 0)
+	if f, ok := ers.Fixtures.(*v2.Fixtures); ok && f != nil {
+		fixtures = append(fixtures, f)
+	}
+
+	for _, host := range ers.Hosts {
+		if host.Fixtures != nil {
+			fixtures = append(fixtures, host.Fixtures)
+		}
+	}
+
+	for _, f := range fixtures {
+		files, err := f.LoadBodyFiles(c.dir)
+		c.sources = append(c.sources, files...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newRouter creates a router for the fixtures (and those of any virtual hosts), along with the State of their scenarios (which is only supported by v2 fixtures)
+func newRouter(ers ersatz, opts v2.Options) (http.Handler, *v2.State, error) {
+	var state *v2.State
+
+	unmonitoredRouter := vestigo.NewRouter()
+	var r http.Handler = unmonitoredRouter
+	r = httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), r)
+
+	switch ers.Version {
+	case "1.0.0-rc1":
+	case "1.0.0":
+		v1.MockPaths(unmonitoredRouter, ers.Fixtures.(*v1.Fixtures))
+		r = delayed(r, opts.DefaultDelay)
+	case "2.0.0-rc1":
+	case "2.0.0":
+		opts.Proxy = ers.Proxy
+		opts.State = v2.NewState()
+		state = v2.MockPaths(unmonitoredRouter, ers.Fixtures.(*v2.Fixtures), opts)
+		r = v2.Fallback(r, ers.Fixtures.(*v2.Fixtures), ers.Proxy)
+
+		if len(ers.Hosts) > 0 {
+			r = virtualHosts(r, ers.Hosts, opts)
+		}
+	default:
+		return nil, nil, ErrUnsupportedVersion
+	}
+	return r, state, nil
+}
+
+// delayed waits for the delay before every response, for fixtures which cannot configure their own
+func delayed(next http.Handler, delay *v2.Delay) http.Handler {
+	if delay == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay.Wait(r.Context())
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,332 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peteclark-ft/ersatz/journal"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const startupFixturesTestYAML = `
+version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+`
+
+const invalidTemplateFixturesTestYAML = `
+version: 2.0.0
+fixtures:
+  /content/{uuid}:
+    get:
+      template: true
+      status: 200
+      body:
+        id: '{{ .Request.PathParam "uuid" '
+`
+
+const replacementFixturesTestYAML = `
+version: 2.0.0
+fixtures:
+  /__gtg:
+    get:
+      status: 503
+`
+
+func serve(h http.Handler, method string, path string) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+func TestConfiguration__NoFixtures(t *testing.T) {
+	c := newConfiguration()
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__health"))
+
+	_, err := c.Current()
+	assert.Equal(t, ErrNoFixtures, err)
+}
+
+func TestConfiguration__ReplaceAndReset(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+
+	require.NoError(t, c.Replace([]byte(replacementFixturesTestYAML)))
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+
+	require.NoError(t, c.Reset())
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__gtg"))
+}
+
+func TestConfiguration__InvalidReplacementKeepsFixtures(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+
+	err := c.Replace([]byte("version: 3.0.0\nfixtures: {}"))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+}
+
+func TestConfiguration__InvalidTemplateKeepsFixtures(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+
+	err := c.Replace([]byte(invalidTemplateFixturesTestYAML))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid response template")
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"), "the previous fixtures should still be served")
+}
+
+func TestConfiguration__ResetWithoutStartupFixtures(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Replace([]byte(startupFixturesTestYAML)))
+	require.NoError(t, c.Reset())
+
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__health"))
+}
+
+func TestConfiguration__UpsertAndRemove(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+
+	require.NoError(t, c.Upsert("", "/__health", "post", []byte("status: 201")))
+	require.NoError(t, c.Upsert("", "/content/{uuid}", "get", []byte("status: 202")))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusCreated, serve(c, "POST", "/__health"))
+	assert.Equal(t, http.StatusAccepted, serve(c, "GET", "/content/1234"))
+
+	require.NoError(t, c.Remove("", "/__health", "get"))
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusCreated, serve(c, "POST", "/__health"))
+
+	require.NoError(t, c.Remove("", "/content/{uuid}", ""))
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/content/1234"))
+
+	assert.Error(t, c.Remove("", "/content/{uuid}", ""))
+	assert.Error(t, c.Remove("", "/__health", "delete"))
+}
+
+func TestConfiguration__UpsertWithoutFixtures(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Upsert("", "/__health", "get", []byte("status: 200")))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+}
+
+func TestConfiguration__InvalidUpsertKeepsFixtures(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+
+	err := c.Upsert("", "/__health", "get", []byte("- when:\n    headers:\n      x-test: ${regex:(}"))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+}
+
+func TestConfiguration__Proxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(`
+version: 2.0.0
+proxy: `+upstream.URL+`
+fixtures:
+  /__health:
+    get:
+      status: 200
+`)))
+
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusTeapot, serve(c, "GET", "/content"))
+}
+
+func TestConfiguration__ProxyRequires2_0_0(t *testing.T) {
+	c := newConfiguration()
+	err := c.Startup([]byte(`
+version: 1.0.0
+proxy: http://localhost:8080
+fixtures:
+  /__health:
+    get:
+      status: 200
+`))
+	assert.Contains(t, err.Error(), ErrNoProxy.Error())
+}
+
+const grpcFixturesTestYAML = `
+version: 2.0.0
+grpc:
+  protos:
+    - protos/content.proto
+  fixtures:
+    content.ContentService/GetContent:
+      - when:
+          body:
+            json:
+              uuid: "1234"
+        response:
+          body:
+            title: Markets rally
+      - response:
+          status: NOT_FOUND
+fixtures:
+  /__health:
+    get:
+      status: 200
+`
+
+const contentTestProto = `
+syntax = "proto3";
+package content;
+service ContentService {
+  rpc GetContent(GetContentRequest) returns (Content);
+}
+message GetContentRequest { string uuid = 1; }
+message Content { string title = 1; }
+`
+
+func grpcCall(h http.Handler, method string, msg []byte) *http.Response {
+	body := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(msg)))
+
+	req := httptest.NewRequest("POST", "/"+method, bytes.NewReader(append(body, msg...)))
+	req.ProtoMajor = 2
+	req.Header.Set("Content-Type", "application/grpc")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Result()
+}
+
+func TestConfiguration__GRPC(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{"protos/content.proto": contentTestProto})
+	defer os.RemoveAll(dir)
+
+	s := New(Options{Dir: dir})
+	require.NoError(t, s.Startup([]byte(grpcFixturesTestYAML)))
+	assert.Equal(t, []string{filepath.Join(dir, "protos", "content.proto")}, s.config.Sources())
+
+	res := grpcCall(s, "content.ContentService/GetContent", []byte{0x0a, 0x04, '1', '2', '3', '4'})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+
+	res = grpcCall(s, "content.ContentService/GetContent", []byte{0x0a, 0x01, '1'})
+	assert.Equal(t, "5", res.Trailer.Get("Grpc-Status"))
+
+	assert.Equal(t, http.StatusOK, serve(s, "GET", "/__health"), "HTTP fixtures should still be served")
+
+	entries := s.Journal().Entries(journal.Filter{Path: "/content.ContentService/GetContent"})
+	require.Len(t, entries, 2)
+	assert.JSONEq(t, `{"uuid":"1234"}`, entries[0].Body)
+	assert.Equal(t, 0, *entries[0].Fixture.Discriminator)
+}
+
+func TestConfiguration__GRPCReflection(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{"protos/content.proto": contentTestProto})
+	defer os.RemoveAll(dir)
+
+	s := New(Options{Dir: dir})
+	res := grpcCall(s, "grpc.reflection.v1.ServerReflection/ServerReflectionInfo", []byte{0x3a, 0x00})
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "reflection is only served once gRPC fixtures are configured")
+
+	require.NoError(t, s.Startup([]byte(grpcFixturesTestYAML)))
+	res = grpcCall(s, "grpc.reflection.v1.ServerReflection/ServerReflectionInfo", []byte{0x3a, 0x00})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+	assert.Empty(t, s.Journal().Entries(journal.Filter{}), "reflection calls should not be journalled")
+}
+
+func TestConfiguration__GRPCInvalidProtos(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{"protos/content.proto": "syntax = \"proto3\";\nmessage {"})
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.dir = dir
+	err := c.Startup([]byte(grpcFixturesTestYAML))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "content.proto:2")
+	assert.Equal(t, []string{filepath.Join(dir, "protos", "content.proto")}, c.Sources(), "invalid protos should still be watched")
+}
+
+func TestConfiguration__GRPCRequires2_0_0(t *testing.T) {
+	c := newConfiguration()
+	err := c.Startup([]byte(`
+version: 1.0.0
+grpc:
+  protos: [content.proto]
+fixtures: {}
+`))
+	assert.Equal(t, ErrNoGRPC, err)
+}
+
+func TestConfiguration__WebSockets(t *testing.T) {
+	s := New(Options{})
+	require.NoError(t, s.Startup([]byte(`
+version: 2.0.0
+websockets:
+  /notifications:
+    onConnect:
+      - text: welcome
+fixtures:
+  /notifications:
+    get:
+      status: 200
+`)))
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/notifications")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "requests which are not handshakes should be served by the HTTP fixtures")
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	_, err = conn.Write([]byte("GET /notifications HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	require.NoError(t, err)
+
+	reader := bufio.NewReader(conn)
+	res, err = http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+
+	frame := make([]byte, 9)
+	_, err = io.ReadFull(reader, frame)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0x81, 0x07}, "welcome"...), frame)
+}
+
+func TestConfiguration__WebSocketsRequires2_0_0(t *testing.T) {
+	c := newConfiguration()
+	err := c.Startup([]byte(`
+version: 1.0.0
+websockets:
+  /notifications:
+    onConnect:
+      - text: welcome
+fixtures: {}
+`))
+	assert.Equal(t, ErrNoWebSockets, err)
+}
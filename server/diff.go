@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+var ErrNoDiff = errors.New("diff is only supported by 2.0.0 fixtures")
+
+// replayable parses the fixtures (including any stubs generated from OpenAPI documents) so they can be replayed against the real API
+func (c *configuration) replayable(yml []byte) (v2.Fixtures, error) {
+	doc, err := yaml.YAMLToJSON(yml)
+	if err != nil {
+		return nil, err
+	}
+
+	generated, err := c.withOpenAPI(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	ers := ersatz{}
+	if err := json.Unmarshal(generated, &ers); err != nil {
+		return nil, err
+	}
+
+	fixtures, ok := ers.Fixtures.(*v2.Fixtures)
+	if !ok {
+		return nil, ErrNoDiff
+	}
+	return *fixtures, nil
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayable(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.dir = dir
+
+	fixtures, err := c.replayable([]byte(`
+version: 2.0.0
+openapi: openapi.yml
+fixtures:
+  /__health:
+    get:
+      status: 200
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, 200, fixtures["/__health"]["get"].Response.Status)
+	assert.Contains(t, fixtures, "/content/{uuid}")
+}
+
+func TestReplayable__V1(t *testing.T) {
+	c := newConfiguration()
+
+	_, err := c.replayable([]byte(`
+version: 1.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+`))
+	assert.Equal(t, ErrNoDiff, err)
+}
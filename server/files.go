@@ -0,0 +1,415 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// resolve finds the fixtures files for a file, a directory (every .yml or .yaml file in it), or a glob
+func resolve(pattern string) ([]string, error) {
+	info, err := os.Stat(pattern)
+	switch {
+	case err == nil && info.IsDir():
+		files := make([]string, 0)
+		for _, ext := range []string{"*.yml", "*.yaml"} {
+			matches, _ := filepath.Glob(filepath.Join(pattern, ext))
+			files = append(files, regularFiles(matches)...)
+		}
+
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no fixtures files found in directory '%v'", pattern)
+		}
+		sort.Strings(files)
+		return files, nil
+	case err == nil:
+		return []string{pattern}, nil
+	case !strings.ContainsAny(pattern, "*?["):
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixtures pattern '%v': %v", pattern, err)
+	}
+
+	files := regularFiles(matches)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no fixtures files match '%v'", pattern)
+	}
+	return files, nil
+}
+
+func regularFiles(paths []string) []string {
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// LoadFixtures reads the fixtures files for every pattern, along with the files they include, and merges them into a single fixtures document. It also returns every file it read (or tried to read). A single file without includes is returned as it was written.
+func LoadFixtures(patterns []string) ([]byte, []string, error) {
+	files := make([]string, 0)
+	for _, p := range patterns {
+		matches, err := resolve(p)
+		if err != nil {
+			return nil, files, err
+		}
+		files = append(files, matches...)
+	}
+
+	if len(files) == 1 {
+		yml, err := ioutil.ReadFile(files[0])
+		if err != nil {
+			return nil, files, err
+		}
+
+		raw := struct {
+			Include interface{} `json:"include"`
+		}{}
+		if err := yaml.Unmarshal(yml, &raw); err != nil || raw.Include == nil {
+			return yml, files, nil
+		}
+	}
+
+	l := &loader{loaded: make(map[string]bool)}
+	merged := newDocument()
+	for _, f := range files {
+		d, err := l.load(f, nil)
+		if err != nil {
+			return nil, l.files, err
+		}
+
+		if err := merged.merge(d, false); err != nil {
+			return nil, l.files, err
+		}
+	}
+
+	doc, err := merged.marshal()
+	return doc, l.files, err
+}
+
+// FixturesDir is the directory other files are relative to when a single fixtures file is used as it was written
+func FixturesDir(patterns []string, files []string) string {
+	if len(files) > 0 {
+		return filepath.Dir(files[0])
+	}
+	return filepath.Dir(patterns[0])
+}
+
+// loader reads fixtures files and the files they include, reading each file only once
+type loader struct {
+	files  []string
+	loaded map[string]bool
+}
+
+// load reads the fixtures file, and merges it with the files it includes. Fixtures in the file take precedence over the fixtures it includes.
+func (l *loader) load(path string, including []string) (*document, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range including {
+		if f == abs {
+			return nil, fmt.Errorf("include cycle: %v -> %v", strings.Join(including[i:], " -> "), abs)
+		}
+	}
+
+	merged := newDocument()
+	if l.loaded[abs] {
+		return merged, nil
+	}
+	l.loaded[abs] = true
+	l.files = append(l.files, path)
+
+	yml, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := yaml.YAMLToJSON(yml)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse '%v': %v", path, err)
+	}
+
+	raw := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("expected '%v' to be a fixtures file: %v", path, err)
+	}
+
+	includes, err := includes(raw["include"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid include in '%v': %v", path, err)
+	}
+
+	for _, inc := range includes {
+		if !filepath.IsAbs(inc) {
+			inc = filepath.Join(filepath.Dir(path), inc)
+		}
+
+		matches, err := resolve(inc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to include '%v' in '%v': %v", inc, path, err)
+		}
+
+		for _, m := range matches {
+			d, err := l.load(m, append(including, abs))
+			if err != nil {
+				return nil, err
+			}
+
+			if err := merged.merge(d, false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	own, err := parseDocument(path, raw)
+	if err != nil {
+		return nil, err
+	}
+	return merged, merged.merge(own, true)
+}
+
+// includes accepts a single file, or a list of files
+func includes(v interface{}) ([]string, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{val}, nil
+	case []interface{}:
+		files := make([]string, 0, len(val))
+		for _, f := range val {
+			s, ok := f.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a file, but was '%v'", f)
+			}
+			files = append(files, s)
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("expected a file or a list of files, but was '%v'", v)
+}
+
+// document is a fixtures document (or the fixtures of one of its virtual hosts) merged from one or more files, which remembers the file each fixture was declared in
+type document struct {
+	host     string
+	keys     map[string]interface{}
+	fixtures map[string]map[string]interface{}
+	hosts    map[string]*document
+	origins  map[string]string
+}
+
+func newDocument() *document {
+	return &document{
+		keys:     make(map[string]interface{}),
+		fixtures: make(map[string]map[string]interface{}),
+		hosts:    make(map[string]*document),
+		origins:  make(map[string]string),
+	}
+}
+
+// parseDocument reads the fixtures and settings declared in a single file. Relative OpenAPI documents, protos and body files are resolved against the file's directory, as the merged document has no directory of its own.
+func parseDocument(path string, raw map[string]interface{}) (*document, error) {
+	d := newDocument()
+	for k, v := range raw {
+		switch k {
+		case "include":
+		case "fixtures":
+			paths, ok := v.(map[string]interface{})
+			if v != nil && !ok {
+				return nil, fmt.Errorf("expected the fixtures in '%v' to be a map of paths to methods", path)
+			}
+
+			for p, m := range paths {
+				methods, ok := m.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("expected the fixture '%v' in '%v' to be a map of methods to fixtures", p, path)
+				}
+
+				resolveBodyFiles(path, methods)
+				d.fixtures[p] = methods
+				for method := range methods {
+					d.origins[fixtureKey(p, method)] = path
+				}
+			}
+		case "hosts":
+			hosts, ok := v.(map[string]interface{})
+			if v != nil && !ok {
+				return nil, fmt.Errorf("expected the hosts in '%v' to be a map of host names to fixtures", path)
+			}
+
+			for name, h := range hosts {
+				raw, ok := h.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("expected the host '%v' in '%v' to have fixtures", name, path)
+				}
+
+				host, err := parseDocument(path, raw)
+				if err != nil {
+					return nil, err
+				}
+				host.host = name
+				d.hosts[name] = host
+			}
+		default:
+			d.keys[k] = relativeTo(path, k, v)
+			d.origins[k] = path
+		}
+	}
+	return d, nil
+}
+
+func relativeTo(path string, key string, v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		if key == "openapi" && !filepath.IsAbs(val) {
+			abs, err := filepath.Abs(filepath.Join(filepath.Dir(path), val))
+			if err == nil {
+				return abs
+			}
+		}
+	case []interface{}:
+		if key == "protos" || key == "importPaths" {
+			copied := make([]interface{}, 0, len(val))
+			for _, child := range val {
+				if f, ok := child.(string); ok && !filepath.IsAbs(f) {
+					if abs, err := filepath.Abs(filepath.Join(filepath.Dir(path), f)); err == nil {
+						child = abs
+					}
+				}
+				copied = append(copied, child)
+			}
+			return copied
+		}
+	case map[string]interface{}:
+		if key == "validation" || key == "grpc" {
+			copied := make(map[string]interface{})
+			for k, child := range val {
+				copied[k] = relativeTo(path, k, child)
+			}
+			return copied
+		}
+	}
+	return v
+}
+
+// resolveBodyFiles makes every relative bodyFile in the fixture relative to the fixtures file's directory. Bodies and discriminators are not searched.
+func resolveBodyFiles(path string, v interface{}) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			switch k {
+			case "body", "when":
+			case "bodyFile":
+				if f, ok := child.(string); ok && !filepath.IsAbs(f) {
+					if abs, err := filepath.Abs(filepath.Join(filepath.Dir(path), f)); err == nil {
+						val[k] = abs
+					}
+				}
+			default:
+				resolveBodyFiles(path, child)
+			}
+		}
+	case []interface{}:
+		for _, child := range val {
+			resolveBodyFiles(path, child)
+		}
+	}
+}
+
+func fixtureKey(path string, method string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// merge adds the other document's settings and fixtures. If override is false, declaring the same fixture or setting in both documents is an error. The version must always match.
+func (d *document) merge(other *document, override bool) error {
+	for k, v := range other.keys {
+		existing, ok := d.keys[k]
+		switch {
+		case !ok:
+		case k == "version" && fmt.Sprint(existing) != fmt.Sprint(v):
+			return fmt.Errorf("'%v' is a %v fixtures file, but '%v' is %v", other.origins[k], v, d.origins[k], existing)
+		case !override && !reflect.DeepEqual(existing, v):
+			return fmt.Errorf("'%v'%v is declared in both '%v' and '%v'", k, d.on(), d.origins[k], other.origins[k])
+		}
+
+		d.keys[k] = v
+		d.origins[k] = other.origins[k]
+	}
+
+	for p, methods := range other.fixtures {
+		merged, ok := d.fixtures[p]
+		if !ok {
+			merged = make(map[string]interface{})
+			d.fixtures[p] = merged
+		}
+
+		for method, fixture := range methods {
+			key := fixtureKey(p, method)
+			if _, ok := merged[method]; ok && !override {
+				return fmt.Errorf("conflicting fixtures for '%v'%v, which is declared in both '%v' and '%v'", key, d.on(), d.origins[key], other.origins[key])
+			}
+
+			merged[method] = fixture
+			d.origins[key] = other.origins[key]
+		}
+	}
+
+	for name, host := range other.hosts {
+		existing, ok := d.hosts[name]
+		if !ok {
+			d.hosts[name] = host
+			continue
+		}
+
+		if err := existing.merge(host, override); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// on describes the virtual host the document belongs to, if it is one
+func (d *document) on() string {
+	if d.host == "" {
+		return ""
+	}
+	return fmt.Sprintf(" on host '%v'", d.host)
+}
+
+func (d *document) object() map[string]interface{} {
+	obj := make(map[string]interface{})
+	for k, v := range d.keys {
+		obj[k] = v
+	}
+	obj["fixtures"] = d.fixtures
+
+	if len(d.hosts) > 0 {
+		hosts := make(map[string]interface{})
+		for name, host := range d.hosts {
+			hosts[name] = host.object()
+		}
+		obj["hosts"] = hosts
+	}
+	return obj
+}
+
+func (d *document) marshal() ([]byte, error) {
+	return json.Marshal(d.object())
+}
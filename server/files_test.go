@@ -0,0 +1,295 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempFixtures(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+
+	for name, yml := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		writeFixtures(t, path, yml)
+	}
+	return dir
+}
+
+func loadTestFixtures(t *testing.T, patterns ...string) *configuration {
+	yml, _, err := LoadFixtures(patterns)
+	require.NoError(t, err)
+
+	c := newConfiguration()
+	require.NoError(t, c.Startup(yml))
+	return c
+}
+
+func TestLoadFixtures__SingleFile(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{"ersatz-fixtures.yml": startupFixturesTestYAML})
+	defer os.RemoveAll(dir)
+
+	yml, files, err := LoadFixtures([]string{filepath.Join(dir, "ersatz-fixtures.yml")})
+	require.NoError(t, err)
+	assert.Equal(t, startupFixturesTestYAML, string(yml))
+	assert.Equal(t, []string{filepath.Join(dir, "ersatz-fixtures.yml")}, files)
+}
+
+func TestLoadFixtures__MissingFile(t *testing.T) {
+	_, _, err := LoadFixtures([]string{"./missing/ersatz-fixtures.yml"})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadFixtures__RepeatedFiles(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"health.yml": startupFixturesTestYAML,
+		"gtg.yml":    replacementFixturesTestYAML,
+	})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "health.yml"), filepath.Join(dir, "gtg.yml"))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+}
+
+func TestLoadFixtures__DirectoryAndGlob(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"fixtures/health.yml": startupFixturesTestYAML,
+		"fixtures/gtg.yaml":   replacementFixturesTestYAML,
+		"fixtures/README.md":  "not a fixtures file",
+	})
+	defer os.RemoveAll(dir)
+
+	_, files, err := LoadFixtures([]string{filepath.Join(dir, "fixtures")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "fixtures", "gtg.yaml"), filepath.Join(dir, "fixtures", "health.yml")}, files)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "fixtures", "*.y*ml"))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+
+	_, _, err = LoadFixtures([]string{filepath.Join(dir, "*.json")})
+	assert.EqualError(t, err, "no fixtures files match '"+filepath.Join(dir, "*.json")+"'")
+}
+
+func TestLoadFixtures__Include(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"ersatz-fixtures.yml": `
+version: 2.0.0
+include:
+  - services/health.yml
+  - services/content.yml
+fixtures:
+  /__gtg:
+    get:
+      status: 503
+`,
+		"services/health.yml": startupFixturesTestYAML,
+		"services/content.yml": `
+version: 2.0.0
+fixtures:
+  /content/{uuid}:
+    get:
+      status: 200
+      body:
+        title: Example Title
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	_, files, err := LoadFixtures([]string{filepath.Join(dir, "ersatz-fixtures.yml")})
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "ersatz-fixtures.yml"))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/content/1234"))
+}
+
+func TestLoadFixtures__IncludingFileOverrides(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"ersatz-fixtures.yml": `
+version: 2.0.0
+include: health.yml
+fixtures:
+  /__health:
+    get:
+      status: 503
+`,
+		"health.yml": `
+version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+    post:
+      status: 201
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "ersatz-fixtures.yml"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusCreated, serve(c, "POST", "/__health"))
+}
+
+func TestLoadFixtures__Conflicts(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"a.yml": startupFixturesTestYAML,
+		"b.yml": `
+version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 503
+`,
+		"c.yml": "version: 1.0.0\nfixtures: {}\n",
+		"d.yml": "version: 2.0.0\nproxy:\n  url: http://localhost:8080\nfixtures: {}\n",
+		"e.yml": "version: 2.0.0\nproxy:\n  url: http://localhost:9090\nfixtures: {}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	a, b, c, d, e := filepath.Join(dir, "a.yml"), filepath.Join(dir, "b.yml"), filepath.Join(dir, "c.yml"), filepath.Join(dir, "d.yml"), filepath.Join(dir, "e.yml")
+
+	_, _, err := LoadFixtures([]string{a, b})
+	assert.EqualError(t, err, "conflicting fixtures for 'GET /__health', which is declared in both '"+a+"' and '"+b+"'")
+
+	_, _, err = LoadFixtures([]string{a, c})
+	assert.EqualError(t, err, "'"+c+"' is a 1.0.0 fixtures file, but '"+a+"' is 2.0.0")
+
+	_, _, err = LoadFixtures([]string{d, e})
+	assert.EqualError(t, err, "'proxy' is declared in both '"+d+"' and '"+e+"'")
+
+	_, _, err = LoadFixtures([]string{a, d})
+	assert.NoError(t, err)
+}
+
+func TestLoadFixtures__InvalidIncludes(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"a.yml":       "version: 2.0.0\ninclude: b.yml\nfixtures: {}\n",
+		"b.yml":       "version: 2.0.0\ninclude: a.yml\nfixtures: {}\n",
+		"missing.yml": "version: 2.0.0\ninclude: other.yml\nfixtures: {}\n",
+		"invalid.yml": "version: 2.0.0\ninclude:\n  file: other.yml\nfixtures: {}\n",
+	})
+	defer os.RemoveAll(dir)
+
+	_, _, err := LoadFixtures([]string{filepath.Join(dir, "a.yml")})
+	assert.EqualError(t, err, "include cycle: "+filepath.Join(dir, "a.yml")+" -> "+filepath.Join(dir, "b.yml")+" -> "+filepath.Join(dir, "a.yml"))
+
+	_, files, err := LoadFixtures([]string{filepath.Join(dir, "missing.yml")})
+	assert.Contains(t, err.Error(), "failed to include '"+filepath.Join(dir, "other.yml")+"' in '"+filepath.Join(dir, "missing.yml")+"'")
+	assert.Equal(t, []string{filepath.Join(dir, "missing.yml")}, files)
+
+	_, _, err = LoadFixtures([]string{filepath.Join(dir, "invalid.yml")})
+	assert.EqualError(t, err, "invalid include in '"+filepath.Join(dir, "invalid.yml")+"': expected a file or a list of files, but was 'map[file:other.yml]'")
+}
+
+func TestLoadFixtures__RelativeOpenAPI(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "services"), 0755))
+	writeFixtures(t, filepath.Join(dir, "services", "content.yml"), "version: 2.0.0\nopenapi: ../openapi.yml\nfixtures: {}\n")
+	writeFixtures(t, filepath.Join(dir, "ersatz-fixtures.yml"), "version: 2.0.0\ninclude: services/content.yml\nfixtures: {}\n")
+
+	yml, _, err := LoadFixtures([]string{filepath.Join(dir, "ersatz-fixtures.yml")})
+	require.NoError(t, err)
+
+	doc := struct {
+		OpenAPI string `json:"openapi"`
+	}{}
+	require.NoError(t, json.Unmarshal(yml, &doc))
+	assert.True(t, filepath.IsAbs(doc.OpenAPI))
+
+	c := newConfiguration()
+	require.NoError(t, c.Startup(yml))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__health"))
+}
+
+func TestLoadFixtures__Hosts(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"content-api.yml": `
+version: 2.0.0
+hosts:
+  content-api:
+    fixtures:
+      /__health:
+        get:
+          status: 503
+`,
+		"people-api.yml": `
+version: 2.0.0
+hosts:
+  people-api:
+    fixtures:
+      /__health:
+        get:
+          status: 500
+  content-api:
+    fixtures:
+      /content/{uuid}:
+        get:
+          status: 200
+`,
+		"conflict.yml": `
+version: 2.0.0
+hosts:
+  content-api:
+    fixtures:
+      /__health:
+        get:
+          status: 200
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "content-api.yml"), filepath.Join(dir, "people-api.yml"))
+	assert.Equal(t, http.StatusServiceUnavailable, serveHost(c, "content-api", "GET", "/__health"))
+	assert.Equal(t, http.StatusOK, serveHost(c, "content-api", "GET", "/content/1234"))
+	assert.Equal(t, http.StatusInternalServerError, serveHost(c, "people-api", "GET", "/__health"))
+
+	_, _, err := LoadFixtures([]string{filepath.Join(dir, "content-api.yml"), filepath.Join(dir, "conflict.yml")})
+	assert.EqualError(t, err, "conflicting fixtures for 'GET /__health' on host 'content-api', which is declared in both '"+filepath.Join(dir, "content-api.yml")+"' and '"+filepath.Join(dir, "conflict.yml")+"'")
+}
+
+func TestLoadFixtures__RelativeBodyFiles(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"ersatz-fixtures.yml":          "version: 2.0.0\ninclude: services/content.yml\nfixtures: {}\n",
+		"services/content.yml":         "version: 2.0.0\nfixtures:\n  /content/{uuid}:\n    get:\n      bodyFile: bodies/content.json\n      status: 200\n",
+		"services/bodies/content.json": `{"title":"Example Title"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "ersatz-fixtures.yml"))
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest("GET", "/content/1234", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"title":"Example Title"}`, w.Body.String())
+}
+
+func TestLoadFixtures__RelativeProtos(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"ersatz-fixtures.yml":           "version: 2.0.0\ninclude: services/content.yml\nfixtures: {}\n",
+		"services/content.yml":          "version: 2.0.0\ngrpc:\n  protos: [protos/content.proto]\n  importPaths: [protos]\n  fixtures:\n    content.ContentService/GetContent:\n      body:\n        title: Markets rally\n",
+		"services/protos/content.proto": contentTestProto,
+	})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "ersatz-fixtures.yml"))
+	assert.Equal(t, []string{filepath.Join(dir, "services", "protos", "content.proto")}, c.Sources())
+
+	res := grpcCall(c, "content.ContentService/GetContent", []byte{})
+	assert.Equal(t, "0", res.Trailer.Get("Grpc-Status"))
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/net/http2/hpack"
+)
+
+// hopByHopHeaders only apply to the HTTP/1.1 connection, so they are not passed on with a request which upgrades it to h2c
+var hopByHopHeaders = []string{"Connection", "Upgrade", "Http2-Settings", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Te"}
+
+// serveH2C serves HTTP/2 over cleartext to clients which either start with the HTTP/2 preface, or upgrade an HTTP/1.1 request with an Upgrade: h2c header, which is then responded to on stream 1 of the new connection. Other requests (including upgrades with a body) are passed on to next unchanged.
+//
+// The h2c package also supports upgrades, but at the vendored revision it drops the headers of the request, and never ends its stream, so the journal would wait for its body forever. It is only used for prior knowledge.
+func serveH2C(srv *http.Server, h2 *http2.Server, next http.Handler) http.Handler {
+	priorKnowledge := h2c.NewHandler(next, h2)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PRI" {
+			priorKnowledge.ServeHTTP(w, r)
+			return
+		}
+
+		settings, ok := h2cSettings(r)
+		hijacker, canHijack := w.(http.Hijacker)
+		if !ok || !canHijack || r.ContentLength != 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		frames, err := upgradeFrames(r, settings)
+		if err != nil {
+			log.WithError(err).Warn("Unable to upgrade the request to h2c, so it will be served over HTTP/1.1")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		conn, rw, err := hijacker.Hijack()
+		if err != nil {
+			log.WithError(err).Error("Failed to hijack the connection to upgrade it to h2c")
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+		if err := rw.Flush(); err != nil {
+			return
+		}
+
+		preface := make([]byte, len(http2.ClientPreface))
+		if _, err := io.ReadFull(rw, preface); err != nil || string(preface) != http2.ClientPreface {
+			log.WithField("remote", r.RemoteAddr).Warn("The client did not send the HTTP/2 preface after upgrading to h2c")
+			return
+		}
+
+		h2.ServeConn(&upgradedConn{Conn: conn, reader: io.MultiReader(frames, rw), writer: &settingsAckFilter{w: conn}}, &http2.ServeConnOpts{BaseConfig: srv, Handler: next})
+	})
+}
+
+// h2cSettings returns the settings from the HTTP2-Settings header, if the request asks to upgrade to h2c
+func h2cSettings(r *http.Request) ([]http2.Setting, bool) {
+	if r.ProtoMajor != 1 || !httpguts.HeaderValuesContainsToken(r.Header["Upgrade"], "h2c") || !httpguts.HeaderValuesContainsToken(r.Header["Connection"], "HTTP2-Settings") {
+		return nil, false
+	}
+
+	values := r.Header["Http2-Settings"]
+	if len(values) != 1 {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(values[0], "="))
+	if err != nil || len(payload)%6 != 0 {
+		return nil, false
+	}
+
+	settings := make([]http2.Setting, 0, len(payload)/6)
+	for i := 0; i < len(payload); i += 6 {
+		settings = append(settings, http2.Setting{ID: http2.SettingID(binary.BigEndian.Uint16(payload[i:])), Val: binary.BigEndian.Uint32(payload[i+2:])})
+	}
+	return settings, true
+}
+
+// upgradeFrames are read by the HTTP/2 server before anything the client sends: the preface, the settings from the HTTP2-Settings header, and the request as stream 1
+func upgradeFrames(r *http.Request, settings []http2.Setting) (io.Reader, error) {
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	fields := []hpack.HeaderField{{Name: ":method", Value: r.Method}, {Name: ":scheme", Value: "http"}, {Name: ":authority", Value: r.Host}, {Name: ":path", Value: r.URL.RequestURI()}}
+	for name, values := range r.Header {
+		if isHopByHop(name, r.Header) || name == "Host" {
+			continue
+		}
+
+		for _, v := range values {
+			fields = append(fields, hpack.HeaderField{Name: strings.ToLower(name), Value: v})
+		}
+	}
+
+	for _, field := range fields {
+		if err := enc.WriteField(field); err != nil {
+			return nil, err
+		}
+	}
+
+	frames := bytes.NewBufferString(http2.ClientPreface)
+	fr := http2.NewFramer(frames, nil)
+	if err := fr.WriteSettings(settings...); err != nil {
+		return nil, err
+	}
+
+	err := fr.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: block.Bytes(), EndStream: true, EndHeaders: true})
+	return frames, err
+}
+
+func isHopByHop(name string, h http.Header) bool {
+	for _, hop := range hopByHopHeaders {
+		if name == hop {
+			return true
+		}
+	}
+	return httpguts.HeaderValuesContainsToken(h["Connection"], name)
+}
+
+// upgradedConn reads the frames of the upgrade request before the rest of the connection
+type upgradedConn struct {
+	net.Conn
+	reader io.Reader
+	writer io.Writer
+}
+
+func (c *upgradedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *upgradedConn) Write(p []byte) (int, error) {
+	return c.writer.Write(p)
+}
+
+// settingsAckFilter drops the first SETTINGS ACK frame written by the server, which acknowledges the HTTP2-Settings header. The client must not receive it, as the 101 response acknowledges them instead.
+type settingsAckFilter struct {
+	w       io.Writer
+	pending []byte
+	dropped bool
+}
+
+func (f *settingsAckFilter) Write(p []byte) (int, error) {
+	if f.dropped {
+		return f.w.Write(p)
+	}
+
+	f.pending = append(f.pending, p...)
+	for len(f.pending) >= frameHeaderLen {
+		length := int(f.pending[0])<<16 | int(f.pending[1])<<8 | int(f.pending[2])
+		if len(f.pending) < frameHeaderLen+length {
+			break
+		}
+
+		frame := f.pending[:frameHeaderLen+length]
+		f.pending = f.pending[len(frame):]
+		if http2.FrameType(frame[3]) == http2.FrameSettings && http2.Flags(frame[4]).Has(http2.FlagSettingsAck) {
+			f.dropped = true
+			if len(f.pending) > 0 {
+				if _, err := f.w.Write(f.pending); err != nil {
+					return 0, err
+				}
+			}
+			f.pending = nil
+			break
+		}
+
+		if _, err := f.w.Write(frame); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// frameHeaderLen is the length of the header of every HTTP/2 frame
+const frameHeaderLen = 9
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestServe__H2CUpgradeHeaders(t *testing.T) {
+	s, l := serveHTTP2(t, `
+version: 2.0.0
+fixtures:
+  /content:
+    get:
+      - when:
+          headers:
+            X-Request-Id: tid_upgraded
+        response:
+          status: 200
+          headers:
+            content-type: text/plain
+          body: matched
+      - response:
+          status: 404
+`, nil, true)
+	defer l.Close()
+
+	conn := dial(t, l)
+	defer conn.Close()
+
+	_, err := io.WriteString(conn, "GET /content?q=1 HTTP/1.1\r\nHost: localhost\r\nX-Request-Id: tid_upgraded\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAQAAP__\r\n\r\n")
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	status, body := readResponse(t, framer(t, conn, r), 1)
+	assert.Equal(t, "200", status)
+	assert.Equal(t, "matched", body)
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "h2c", entries[0].Protocol)
+	assert.Equal(t, "/content", entries[0].Path)
+	assert.Equal(t, "1", entries[0].Query.Get("q"))
+	assert.Equal(t, []string{"tid_upgraded"}, entries[0].Headers["X-Request-Id"])
+	assert.Empty(t, entries[0].Headers["Http2-Settings"])
+}
+
+func TestServe__H2CUpgradeWithBody(t *testing.T) {
+	s, l := serveHTTP2(t, http2FixturesTestYAML, nil, true)
+	defer l.Close()
+
+	conn := dial(t, l)
+	defer conn.Close()
+
+	_, err := io.WriteString(conn, "POST /content HTTP/1.1\r\nHost: localhost\r\nContent-Length: 4\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAQAAP__\r\n\r\nbody")
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "posted", string(body))
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "http/1.1", entries[0].Protocol, "requests with a body should not be upgraded")
+}
+
+func TestSettingsAckFilter(t *testing.T) {
+	var frames bytes.Buffer
+	fr := http2.NewFramer(&frames, nil)
+	require.NoError(t, fr.WriteSettings(http2.Setting{ID: http2.SettingMaxConcurrentStreams, Val: 100}))
+	require.NoError(t, fr.WriteSettingsAck())
+	require.NoError(t, fr.WriteWindowUpdate(0, 1000))
+	require.NoError(t, fr.WriteSettingsAck())
+	written := frames.Bytes()
+
+	var out bytes.Buffer
+	filter := &settingsAckFilter{w: &out}
+	for i := 0; i < len(written); i += 5 {
+		end := i + 5
+		if end > len(written) {
+			end = len(written)
+		}
+
+		n, err := filter.Write(written[i:end])
+		require.NoError(t, err)
+		assert.Equal(t, end-i, n)
+	}
+
+	read := http2.NewFramer(nil, &out)
+	f, err := read.ReadFrame()
+	require.NoError(t, err)
+	assert.False(t, f.(*http2.SettingsFrame).IsAck())
+
+	f, err = read.ReadFrame()
+	require.NoError(t, err)
+	assert.IsType(t, &http2.WindowUpdateFrame{}, f, "the first SETTINGS ACK should be dropped")
+
+	f, err = read.ReadFrame()
+	require.NoError(t, err)
+	assert.True(t, f.(*http2.SettingsFrame).IsAck(), "later SETTINGS ACKs should be written")
+
+	_, err = read.ReadFrame()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Financial-Times/http-handlers-go/httphandlers"
+	"github.com/husobee/vestigo"
+	"github.com/peteclark-ft/ersatz/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// hostRouter serves each request using the fixtures of the virtual host in its Host header, or the top level fixtures if there is no such virtual host
+type hostRouter struct {
+	hosts    map[string]http.Handler
+	fallback http.Handler
+}
+
+// virtualHosts mocks the fixtures of every virtual host. Their scenarios share the State in the options.
+func virtualHosts(fallback http.Handler, hosts map[string]virtualHost, opts v2.Options) http.Handler {
+	h := hostRouter{hosts: make(map[string]http.Handler), fallback: fallback}
+	for name, host := range hosts {
+		fixtures := host.Fixtures
+		if fixtures == nil {
+			fixtures = &v2.Fixtures{}
+		}
+
+		opts.Proxy = host.Proxy
+		opts.Host = name
+
+		router := vestigo.NewRouter()
+		v2.MockPaths(router, fixtures, opts)
+
+		var r http.Handler = httphandlers.TransactionAwareRequestLoggingHandler(log.StandardLogger(), router)
+		h.hosts[strings.ToLower(name)] = v2.Fallback(r, fixtures, host.Proxy)
+	}
+	return h
+}
+
+func (h hostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, name := range hostNames(r.Host) {
+		if next, ok := h.hosts[name]; ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+	}
+	h.fallback.ServeHTTP(w, r)
+}
+
+// hostNames are the virtual host names which match the Host header, in order of precedence: the host with its port, then without it. Host names are case insensitive.
+func hostNames(host string) []string {
+	host = strings.ToLower(host)
+	names := []string{host}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		names = append(names, hostname)
+	}
+	return names
+}
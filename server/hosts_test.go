@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hostsFixturesTestYAML = `
+version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+hosts:
+  Content-API:
+    fixtures:
+      /__health:
+        get:
+          status: 503
+      /content/{uuid}:
+        get:
+          sequence:
+            - status: 202
+            - status: 200
+  people-api:8080:
+    fixtures:
+      /__health:
+        get:
+          status: 500
+      /content/{uuid}:
+        get:
+          sequence:
+            - status: 202
+            - status: 200
+`
+
+func serveHost(h http.Handler, host string, method string, path string) int {
+	r := httptest.NewRequest(method, path, nil)
+	r.Host = host
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestVirtualHosts(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(hostsFixturesTestYAML)))
+
+	assert.Equal(t, http.StatusOK, serveHost(c, "localhost:9000", "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serveHost(c, "content-api", "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serveHost(c, "content-api:9000", "GET", "/__health"))
+	assert.Equal(t, http.StatusInternalServerError, serveHost(c, "people-api:8080", "GET", "/__health"))
+	assert.Equal(t, http.StatusOK, serveHost(c, "people-api:9000", "GET", "/__health"), "the port must match when the host has one")
+	assert.Equal(t, http.StatusNotFound, serveHost(c, "content-api", "GET", "/people/1234"))
+}
+
+func TestVirtualHosts__SequencesPerHost(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(hostsFixturesTestYAML)))
+
+	assert.Equal(t, http.StatusAccepted, serveHost(c, "content-api", "GET", "/content/1234"))
+	assert.Equal(t, http.StatusAccepted, serveHost(c, "people-api:8080", "GET", "/content/1234"))
+	assert.Equal(t, http.StatusOK, serveHost(c, "content-api", "GET", "/content/1234"))
+}
+
+func TestVirtualHosts__NotSupportedByV1(t *testing.T) {
+	c := newConfiguration()
+	assert.Equal(t, ErrNoHosts, c.Startup([]byte("version: 1.0.0\nfixtures: {}\nhosts:\n  content-api:\n    fixtures: {}\n")))
+}
+
+func TestVirtualHosts__ClosestFixture(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(hostsFixturesTestYAML)))
+
+	closest, ok := c.ClosestFixture("content-api", "/content")
+	assert.True(t, ok)
+	assert.Equal(t, "/content/{uuid}", closest)
+
+	_, ok = c.ClosestFixture("localhost:9000", "/content")
+	assert.False(t, ok)
+}
+
+func TestVirtualHosts__UpsertAndRemove(t *testing.T) {
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(hostsFixturesTestYAML)))
+
+	require.NoError(t, c.Upsert("content-api", "/__gtg", "get", []byte("status: 503")))
+	require.NoError(t, c.Upsert("search-api", "/__gtg", "get", []byte("status: 202")))
+	assert.Equal(t, http.StatusServiceUnavailable, serveHost(c, "content-api", "GET", "/__gtg"))
+	assert.Equal(t, http.StatusAccepted, serveHost(c, "search-api", "GET", "/__gtg"))
+	assert.Equal(t, http.StatusNotFound, serveHost(c, "localhost", "GET", "/__gtg"))
+
+	require.NoError(t, c.Remove("content-api", "/__gtg", "get"))
+	assert.Equal(t, http.StatusNotFound, serveHost(c, "content-api", "GET", "/__gtg"))
+	assert.Equal(t, http.StatusServiceUnavailable, serveHost(c, "content-api", "GET", "/__health"))
+
+	yml, err := c.Current()
+	require.NoError(t, err)
+	assert.NotContains(t, string(yml), "content-api:")
+
+	assert.EqualError(t, c.Remove("unknown-api", "/__gtg", ""), "no fixtures configured for host 'unknown-api'")
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/peteclark-ft/ersatz/grpc"
+	"github.com/peteclark-ft/ersatz/v1"
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/peteclark-ft/ersatz/websocket"
+)
+
+var ErrUnsupportedVersion = errors.New("unsupported ersatz version, please confirm the ersatz-fixtures.yml version number")
+
+var ErrNoProxy = errors.New("proxy is only supported by 2.0.0 fixtures")
+
+var ErrNoHosts = errors.New("hosts are only supported by 2.0.0 fixtures")
+
+var ErrNoGRPC = errors.New("grpc is only supported by 2.0.0 fixtures")
+
+var ErrNoWebSockets = errors.New("websockets are only supported by 2.0.0 fixtures")
+
+type ersatz struct {
+	Version    string                 `json:"version"`
+	Proxy      *v2.Proxy              `json:"proxy"`
+	Fixtures   fixtures               `json:"fixtures"`
+	Hosts      map[string]virtualHost `json:"hosts"`
+	GRPC       *grpc.Fixtures         `json:"grpc"`
+	WebSockets websocket.Fixtures     `json:"websockets"`
+}
+
+// virtualHost has its own fixtures (and proxy), which are used for requests with its Host header instead of the top level fixtures
+type virtualHost struct {
+	Proxy    *v2.Proxy    `json:"proxy"`
+	Fixtures *v2.Fixtures `json:"fixtures"`
+}
+
+type fixtures interface {
+	Version() int
+}
+
+func (e *ersatz) UnmarshalJSON(data []byte) error {
+	v := struct {
+		Version string `json:"version"`
+	}{}
+
+	err := json.Unmarshal(data, &v)
+	if err != nil {
+		return err
+	}
+
+	e.Version = v.Version
+
+	f := struct {
+		Proxy      *v2.Proxy              `json:"proxy"`
+		Fixtures   fixtures               `json:"fixtures"`
+		Hosts      map[string]virtualHost `json:"hosts"`
+		GRPC       *grpc.Fixtures         `json:"grpc"`
+		WebSockets websocket.Fixtures     `json:"websockets"`
+	}{}
+
+	switch e.Version {
+	case "1.0.0-rc1":
+	case "1.0.0":
+		f.Fixtures = &v1.Fixtures{}
+	case "2.0.0-rc1":
+	case "2.0.0":
+		f.Fixtures = &v2.Fixtures{}
+	default:
+		return ErrUnsupportedVersion
+	}
+
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		return err
+	}
+
+	if f.Proxy != nil && e.Version != "2.0.0" {
+		return ErrNoProxy
+	}
+
+	if len(f.Hosts) > 0 && e.Version != "2.0.0" {
+		return ErrNoHosts
+	}
+
+	if f.GRPC != nil && e.Version != "2.0.0" {
+		return ErrNoGRPC
+	}
+
+	if len(f.WebSockets) > 0 && e.Version != "2.0.0" {
+		return ErrNoWebSockets
+	}
+
+	e.Proxy = f.Proxy
+	e.Fixtures = f.Fixtures
+	e.Hosts = f.Hosts
+	e.GRPC = f.GRPC
+	e.WebSockets = f.WebSockets
+	return nil
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/peteclark-ft/ersatz/openapi"
+)
+
+var ErrNoOpenAPI = errors.New("openapi is only supported by 2.0.0 fixtures")
+
+var ErrNoValidationDocument = errors.New("request validation requires an OpenAPI document, please provide one with --openapi, or the openapi key")
+
+// validation configures how requests are validated against an OpenAPI document
+type validation struct {
+	OpenAPI string `json:"openapi"`
+	Status  int    `json:"status"`
+}
+
+// withOpenAPI adds stubs generated from the OpenAPI documents (provided on startup, or with the openapi key) to the fixtures document. Hand-written fixtures take precedence over the openapi key, which takes precedence over the document provided on startup.
+func (c *configuration) withOpenAPI(doc []byte) ([]byte, error) {
+	raw := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	specs := make([]string, 0)
+	if spec, ok := raw["openapi"].(string); ok && spec != "" {
+		specs = append(specs, spec)
+	}
+	if c.openapi != "" {
+		specs = append(specs, c.openapi)
+	}
+
+	if len(specs) == 0 {
+		return doc, nil
+	}
+
+	if raw["version"] != "2.0.0" {
+		return nil, ErrNoOpenAPI
+	}
+
+	fixtures, ok := raw["fixtures"].(map[string]interface{})
+	if !ok {
+		fixtures = make(map[string]interface{})
+		raw["fixtures"] = fixtures
+	}
+
+	for _, spec := range specs {
+		d, err := c.loadOpenAPI(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		for path, methods := range d.Fixtures() {
+			p, ok := fixtures[path].(map[string]interface{})
+			if !ok {
+				p = make(map[string]interface{})
+				fixtures[path] = p
+			}
+
+			for method, stub := range methods {
+				if _, ok := p[method]; !ok {
+					p[method] = stub
+				}
+			}
+		}
+	}
+	return json.Marshal(raw)
+}
+
+// loadOpenAPI reads the OpenAPI document, relative to the fixtures file if the path is not absolute
+func (c *configuration) loadOpenAPI(path string) (*openapi.Document, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.dir, path)
+	}
+	c.sources = append(c.sources, path)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read OpenAPI document '%v': %v`, path, err)
+	}
+
+	d, err := openapi.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to load OpenAPI document '%v': %v`, path, err)
+	}
+	return d, nil
+}
+
+// validator creates a Validator if request validation is enabled, either on startup or with the validation key. The OpenAPI document defaults to the one used to generate stubs.
+func (c *configuration) validator(doc []byte) (*openapi.Validator, error) {
+	raw := struct {
+		OpenAPI    string      `json:"openapi"`
+		Validation *validation `json:"validation"`
+	}{}
+
+	if err := json.Unmarshal(doc, &raw); err != nil {
+		return nil, err
+	}
+
+	if raw.Validation == nil && !c.validate {
+		return nil, nil
+	}
+
+	v := validation{Status: c.validationStatus}
+	if raw.Validation != nil {
+		v = *raw.Validation
+	}
+
+	spec := v.OpenAPI
+	for _, fallback := range []string{raw.OpenAPI, c.openapi} {
+		if spec == "" {
+			spec = fallback
+		}
+	}
+
+	if spec == "" {
+		return nil, ErrNoValidationDocument
+	}
+
+	if v.Status == 0 {
+		v.Status = openapi.DefaultValidationStatus
+	}
+
+	if v.Status < 400 || v.Status > 499 {
+		return nil, fmt.Errorf("expected the validation status to be a 4xx status code, but was '%v'", v.Status)
+	}
+
+	d, err := c.loadOpenAPI(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &openapi.Validator{Document: d, Status: v.Status}, nil
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const openAPIFixturesTestYAML = `
+openapi: 3.0.0
+paths:
+  /__health:
+    get:
+      responses:
+        "503":
+          description: Unhealthy
+  /content/{uuid}:
+    get:
+      responses:
+        "200":
+          description: The content
+          content:
+            application/json:
+              example:
+                title: Example Title
+        "404":
+          description: Not found
+`
+
+func writeOpenAPI(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "openapi.yml"), []byte(openAPIFixturesTestYAML), 0644))
+	return dir
+}
+
+func TestConfiguration__OpenAPIKey(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.dir = dir
+	require.NoError(t, c.Startup([]byte(`
+version: 2.0.0
+openapi: openapi.yml
+fixtures:
+  /__health:
+    get:
+      status: 200
+`)))
+
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/content/1234"))
+
+	r := httptest.NewRequest("GET", "/content/1234", nil)
+	r.Header.Set("Prefer", "code=404")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	yml, err := c.Current()
+	require.NoError(t, err)
+	assert.NotContains(t, string(yml), "/content/{uuid}")
+}
+
+func TestConfiguration__OpenAPIOnStartup(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.openapi = filepath.Join(dir, "openapi.yml")
+	require.NoError(t, c.Startup(EmptyFixtures))
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/content/1234"))
+}
+
+func TestConfiguration__InvalidOpenAPI(t *testing.T) {
+	c := newConfiguration()
+	assert.Error(t, c.Startup([]byte(`
+version: 2.0.0
+openapi: missing.yml
+fixtures: {}
+`)))
+
+	assert.Equal(t, ErrNoOpenAPI, c.Startup([]byte(`
+version: 1.0.0
+openapi: openapi.yml
+fixtures: {}
+`)))
+}
+
+func TestConfiguration__Validation(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.dir = dir
+	require.NoError(t, c.Startup([]byte(`
+version: 2.0.0
+validation:
+  openapi: openapi.yml
+  status: 422
+fixtures:
+  /__health:
+    get:
+      status: 200
+  /__gtg:
+    get:
+      status: 200
+`)))
+
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(c, "GET", "/__gtg"))
+	assert.Equal(t, http.StatusOK, serve(c, "HEAD", "/__health"), "HEAD should be validated as the GET operation")
+	assert.Equal(t, http.StatusNoContent, serve(c, "OPTIONS", "/__health"), "OPTIONS should be allowed for declared paths")
+}
+
+func TestConfiguration__ValidationOnStartup(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.validate = true
+	require.Equal(t, ErrNoValidationDocument, c.Startup(EmptyFixtures))
+
+	c.openapi = filepath.Join(dir, "openapi.yml")
+	require.NoError(t, c.Startup(EmptyFixtures))
+	assert.Equal(t, http.StatusBadRequest, serve(c, "GET", "/__gtg"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__health"))
+}
+
+func TestConfiguration__InvalidValidationStatus(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.dir = dir
+	assert.EqualError(t, c.Startup([]byte(`
+version: 2.0.0
+openapi: openapi.yml
+validation:
+  status: 200
+fixtures: {}
+`)), "expected the validation status to be a 4xx status code, but was '200'")
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/peteclark-ft/ersatz/grpc"
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/peteclark-ft/ersatz/v2"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http2"
+)
+
+// Options configure how the server loads and serves its fixtures
+type Options struct {
+	// Dir is the directory of the fixtures files, which other files (i.e. OpenAPI documents and body files) are relative to
+	Dir string
+	// OpenAPI is an OpenAPI (or Swagger 2.0) document to generate stubs from. Fixtures take precedence.
+	OpenAPI string
+	// Validate enables validation of every request against the OpenAPI document, and ValidationStatus is the status of requests which fail it
+	Validate         bool
+	ValidationStatus int
+	// DefaultDelay is waited before every response
+	DefaultDelay time.Duration
+	// JournalLimit is the number of received requests kept in the journal
+	JournalLimit int
+}
+
+// Server serves the fixtures along with the /__configure endpoint and the /__admin API, and records every request in its journal
+type Server struct {
+	config  *configuration
+	journal *journal.Journal
+	mux     *http.ServeMux
+}
+
+// New creates a server without any fixtures, which responds with a 404 until fixtures are provided
+func New(opts Options) *Server {
+	config := newConfiguration()
+	config.dir = opts.Dir
+	config.openapi = opts.OpenAPI
+	config.validate = opts.Validate
+	config.validationStatus = opts.ValidationStatus
+	if opts.DefaultDelay > 0 {
+		config.options.DefaultDelay = v2.FixedDelay(opts.DefaultDelay)
+	}
+
+	s := &Server{config: config, journal: journal.New(opts.JournalLimit), mux: http.NewServeMux()}
+	s.mux.HandleFunc("/__configure", acceptFixtures(config))
+	s.mux.Handle("/__admin/", newAdminRouter(config, s.journal))
+	s.mux.Handle("/", s.journal.Record(config))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if grpc.IsReflection(r) {
+		s.config.ServeReflection(w, r)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Serve accepts connections on the listener, over TLS if the config is not nil. Clients may use HTTP/2 unless allowHTTP2 is false: over TLS it is negotiated with ALPN, and over cleartext (h2c) clients either start with the HTTP/2 preface, or upgrade an HTTP/1.1 request.
+func (s *Server) Serve(l net.Listener, config *tls.Config, allowHTTP2 bool) error {
+	srv := &http.Server{Handler: s, TLSConfig: config}
+	if !allowHTTP2 {
+		if config != nil {
+			srv.TLSConfig = config.Clone()
+			srv.TLSConfig.NextProtos = []string{"http/1.1"}
+		}
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		return listen(srv, l)
+	}
+
+	// the same HTTP/2 server is used for TLS and h2c, so both behave in the same way
+	h2 := &http2.Server{}
+	if config != nil {
+		srv.TLSConfig = config.Clone()
+		if err := http2.ConfigureServer(srv, h2); err != nil {
+			return err
+		}
+		return listen(srv, l)
+	}
+
+	srv.Handler = serveH2C(srv, h2, s)
+	return listen(srv, l)
+}
+
+func listen(srv *http.Server, l net.Listener) error {
+	l = v2.TrackConnections(l)
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(l, "", "")
+	}
+	return srv.Serve(l)
+}
+
+// Startup loads the fixtures provided on startup, which are also used on reset
+func (s *Server) Startup(yml []byte) error {
+	return s.config.Startup(yml)
+}
+
+// Replace replaces every fixture with the provided fixtures document
+func (s *Server) Replace(yml []byte) error {
+	return s.config.Replace(yml)
+}
+
+// Journal returns the journal of every request the server has received
+func (s *Server) Journal() *journal.Journal {
+	return s.journal
+}
+
+// Watch reloads the fixtures files matching the patterns whenever they, or any file they refer to, change. It checks for changes every interval, until stop is closed.
+func (s *Server) Watch(patterns []string, interval time.Duration, stop <-chan struct{}) {
+	newWatcher(s.config, patterns).Watch(interval, stop)
+}
+
+// Replayable parses the fixtures (including any stubs generated from the OpenAPI document) so they can be replayed against the real API
+func (s *Server) Replayable(yml []byte) (v2.Fixtures, error) {
+	return s.config.replayable(yml)
+}
+
+func acceptFixtures(config *configuration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		yml, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			log.WithError(err).Error("Failed to read request body")
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+
+		err = config.Replace(yml)
+		if err != nil {
+			log.WithError(err).Error("Failed to configure fixtures")
+			http.Error(w, "Failed to configure fixtures: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		log.Info("Configured fixtures via /__configure endpoint")
+		w.WriteHeader(http.StatusOK)
+	}
+}
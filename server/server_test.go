@@ -0,0 +1,314 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+const http2FixturesTestYAML = `
+version: 2.0.0
+fixtures:
+  /content:
+    get:
+      - when:
+          protocol: h2c
+        response:
+          status: 200
+          headers:
+            content-type: text/plain
+          body: cleartext
+      - when:
+          protocol: h2
+        response:
+          status: 200
+          headers:
+            content-type: text/plain
+          body: encrypted
+      - response:
+          status: 404
+    post:
+      status: 200
+      headers:
+        content-type: text/plain
+      body: posted
+`
+
+func serveHTTP2(t *testing.T, yml string, config *tls.Config, allowHTTP2 bool) (*Server, net.Listener) {
+	s := New(Options{})
+	require.NoError(t, s.Startup([]byte(yml)))
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go s.Serve(l, config, allowHTTP2)
+	return s, l
+}
+
+func dial(t *testing.T, l net.Listener) net.Conn {
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	return conn
+}
+
+// generateTLS returns a server config with a certificate for localhost, and a client config which trusts it
+func generateTLS(t *testing.T) (*tls.Config, *tls.Config) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := TLSOptions{GenerateCA: filepath.Join(dir, "ca.pem")}.Config()
+	require.NoError(t, err)
+
+	ca, err := ioutil.ReadFile(filepath.Join(dir, "ca.pem"))
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(ca))
+
+	return config, &tls.Config{RootCAs: pool, ServerName: "localhost"}
+}
+
+// http2Client only sends HTTP/2 requests: over TLS if the config is not nil, and otherwise over cleartext with prior knowledge
+func http2Client(config *tls.Config) *http.Client {
+	transport := &http2.Transport{TLSClientConfig: config}
+	if config == nil {
+		transport.AllowHTTP = true
+		transport.DialTLS = func(network string, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+}
+
+// framer sends the client preface and settings, and returns a framer which decodes the server's headers
+func framer(t *testing.T, w io.Writer, r io.Reader) *http2.Framer {
+	_, err := io.WriteString(w, http2.ClientPreface)
+	require.NoError(t, err)
+
+	fr := http2.NewFramer(w, r)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	require.NoError(t, fr.WriteSettings())
+	return fr
+}
+
+func writeRequest(t *testing.T, fr *http2.Framer, stream uint32, method string, path string) {
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	for _, field := range [][2]string{{":method", method}, {":scheme", "http"}, {":authority", "localhost"}, {":path", path}} {
+		require.NoError(t, enc.WriteField(hpack.HeaderField{Name: field[0], Value: field[1]}))
+	}
+	require.NoError(t, fr.WriteHeaders(http2.HeadersFrameParam{StreamID: stream, BlockFragment: block.Bytes(), EndStream: true, EndHeaders: true}))
+}
+
+// readResponse reads frames until the end of the stream, and returns its status and body
+func readResponse(t *testing.T, fr *http2.Framer, stream uint32) (string, string) {
+	var status string
+	var body bytes.Buffer
+	for {
+		f, err := fr.ReadFrame()
+		require.NoError(t, err)
+
+		if f.Header().StreamID != stream {
+			continue
+		}
+
+		switch f := f.(type) {
+		case *http2.MetaHeadersFrame:
+			status = f.PseudoValue("status")
+			if f.StreamEnded() {
+				return status, body.String()
+			}
+		case *http2.DataFrame:
+			body.Write(f.Data())
+			if f.StreamEnded() {
+				return status, body.String()
+			}
+		case *http2.RSTStreamFrame:
+			require.Fail(t, "the stream was reset")
+		}
+	}
+}
+
+func TestServe__H2CPriorKnowledge(t *testing.T) {
+	s, l := serveHTTP2(t, http2FixturesTestYAML, nil, true)
+	defer l.Close()
+
+	res, err := http2Client(nil).Get("http://" + l.Addr().String() + "/content")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", res.Proto)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "cleartext", string(body))
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "h2c", entries[0].Protocol)
+}
+
+func TestServe__H2CUpgrade(t *testing.T) {
+	s, l := serveHTTP2(t, http2FixturesTestYAML, nil, true)
+	defer l.Close()
+
+	conn := dial(t, l)
+	defer conn.Close()
+
+	_, err := io.WriteString(conn, "GET /content HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAQAAP__\r\n\r\n")
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	fr := framer(t, conn, r)
+	status, body := readResponse(t, fr, 1)
+	assert.Equal(t, "200", status)
+	assert.Equal(t, "cleartext", body)
+
+	writeRequest(t, fr, 3, "POST", "/content")
+	status, body = readResponse(t, fr, 3)
+	assert.Equal(t, "200", status)
+	assert.Equal(t, "posted", body)
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "h2c", e.Protocol)
+		assert.Empty(t, e.Headers["Upgrade"])
+	}
+}
+
+func TestServe__H2CUpgradeFlowControl(t *testing.T) {
+	_, l := serveHTTP2(t, `
+version: 2.0.0
+fixtures:
+  /large:
+    get:
+      status: 200
+      headers:
+        content-type: text/plain
+      body: "`+string(bytes.Repeat([]byte("a"), 40000))+`"
+`, nil, true)
+	defer l.Close()
+
+	conn := dial(t, l)
+	defer conn.Close()
+
+	// the client only accepts 10000 bytes on each stream until it sends a window update
+	_, err := io.WriteString(conn, "GET /large HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAQAACcQ\r\n\r\n")
+	require.NoError(t, err)
+
+	r := bufio.NewReader(conn)
+	_, err = http.ReadResponse(r, nil)
+	require.NoError(t, err)
+
+	fr := framer(t, conn, r)
+	received := 0
+	for received < 10000 {
+		f, err := fr.ReadFrame()
+		require.NoError(t, err)
+		if data, ok := f.(*http2.DataFrame); ok && data.StreamID == 1 {
+			received += len(data.Data())
+			assert.False(t, data.StreamEnded())
+		}
+	}
+	assert.Equal(t, 10000, received)
+
+	require.NoError(t, fr.WriteWindowUpdate(1, 30000))
+	_, body := readResponse(t, fr, 1)
+	assert.Len(t, body, 30000)
+}
+
+func TestServe__HTTP2OverTLS(t *testing.T) {
+	config, clientConfig := generateTLS(t)
+	s, l := serveHTTP2(t, http2FixturesTestYAML, config, true)
+	defer l.Close()
+
+	res, err := http2Client(clientConfig).Get("https://" + l.Addr().String() + "/content")
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "HTTP/2.0", res.Proto)
+	assert.Equal(t, "encrypted", string(body))
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "h2", entries[0].Protocol)
+}
+
+func TestServe__HTTP2Disabled(t *testing.T) {
+	s, l := serveHTTP2(t, http2FixturesTestYAML, nil, false)
+	defer l.Close()
+
+	conn := dial(t, l)
+	defer conn.Close()
+
+	_, err := io.WriteString(conn, "GET /content HTTP/1.1\r\nHost: localhost\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: AAQAAP__\r\n\r\n")
+	require.NoError(t, err)
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "http/1.1", entries[0].Protocol)
+}
+
+func TestServe__HTTP2DisabledOverTLS(t *testing.T) {
+	config, clientConfig := generateTLS(t)
+	_, l := serveHTTP2(t, http2FixturesTestYAML, config, false)
+	defer l.Close()
+
+	clientConfig.NextProtos = []string{"h2", "http/1.1"}
+	conn := tls.Client(dial(t, l), clientConfig)
+	defer conn.Close()
+
+	require.NoError(t, conn.Handshake())
+	assert.Equal(t, "http/1.1", conn.ConnectionState().NegotiatedProtocol)
+}
+
+func TestServe__FaultOverHTTP2(t *testing.T) {
+	config, clientConfig := generateTLS(t)
+	s, l := serveHTTP2(t, `
+version: 2.0.0
+fixtures:
+  /content:
+    get:
+      status: 200
+      fault: connectionReset
+`, config, true)
+	defer l.Close()
+
+	_, err := http2Client(clientConfig).Get("https://" + l.Addr().String() + "/content")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stream error")
+
+	entries := s.Journal().Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "h2", entries[0].Protocol)
+	assert.Equal(t, "connectionReset", entries[0].Fault)
+	assert.True(t, entries[0].Aborted)
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+var ErrNoTLSKey = errors.New("both a TLS certificate and its key must be provided")
+
+var ErrTLSConflict = errors.New("a TLS certificate cannot be provided when generating a CA")
+
+var ErrNoTLS = errors.New("client certificates can only be verified when TLS is configured")
+
+// certificateValidity is how long generated certificates are valid for
+const certificateValidity = 365 * 24 * time.Hour
+
+// TLSOptions configure HTTPS, using either a certificate and key, or a certificate signed by a generated CA
+type TLSOptions struct {
+	// Cert and Key are PEM files of the certificate (and any intermediates) and its private key
+	Cert string
+	Key  string
+	// GenerateCA is the file the PEM certificate of a newly generated CA is written to, so clients can trust the certificate it signs for the Hosts
+	GenerateCA string
+	// Hosts are the names and IP addresses of the generated certificate, in addition to localhost and the machine's hostname
+	Hosts []string
+	// ClientCA is a PEM file of the CAs which client certificates must be signed by. If it is provided, every client must present a valid certificate.
+	ClientCA string
+}
+
+// Config creates the TLS configuration for the options, which is nil if HTTPS has not been configured
+func (o TLSOptions) Config() (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	switch {
+	case o.GenerateCA != "" && (o.Cert != "" || o.Key != ""):
+		return nil, ErrTLSConflict
+	case o.GenerateCA != "":
+		cert, err = generateCertificate(o.GenerateCA, o.hosts())
+	case o.Cert != "" && o.Key != "":
+		cert, err = tls.LoadX509KeyPair(o.Cert, o.Key)
+	case o.Cert != "" || o.Key != "":
+		return nil, ErrNoTLSKey
+	case o.ClientCA != "":
+		return nil, ErrNoTLS
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if o.ClientCA == "" {
+		return config, nil
+	}
+
+	cas, err := ioutil.ReadFile(o.ClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(cas) {
+		return nil, fmt.Errorf("no certificates found in '%v'", o.ClientCA)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+func (o TLSOptions) hosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	return append(hosts, o.Hosts...)
+}
+
+// generateCertificate creates a self-signed CA, and a certificate for the hosts signed by it, and writes the CA certificate to the file
+func generateCertificate(caFile string, hosts []string) (tls.Certificate, error) {
+	ca, caKey, err := newCertificate(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ersatz CA", Organization: []string{"ersatz"}},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}, nil, nil)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0], Organization: []string{"ersatz"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			leaf.IPAddresses = append(leaf.IPAddresses, ip)
+		} else {
+			leaf.DNSNames = append(leaf.DNSNames, h)
+		}
+	}
+
+	cert, key, err := newCertificate(leaf, ca, caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0644); err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to write the CA certificate to '%v': %v", caFile, err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{cert.Raw, ca.Raw}, PrivateKey: key, Leaf: cert}, nil
+}
+
+// newCertificate creates a key and a certificate for it from the template, which is signed by the parent, or self-signed if there is no parent
+func newCertificate(template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = template.NotBefore.Add(certificateValidity)
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
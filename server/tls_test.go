@@ -0,0 +1,166 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tlsFixturesTestYAML = `
+version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+  /content/{uuid}:
+    get:
+      - when:
+          clientCert:
+            commonName: content-api
+        response:
+          status: 200
+      - response:
+          status: 403
+`
+
+func startTLS(t *testing.T, config *tls.Config) *httptest.Server {
+	s := New(Options{})
+	require.NoError(t, s.Startup([]byte(tlsFixturesTestYAML)))
+
+	srv := httptest.NewUnstartedServer(s)
+	srv.TLS = config
+	srv.StartTLS()
+	return srv
+}
+
+func trusting(t *testing.T, caFile string, certs ...tls.Certificate) *http.Client {
+	ca, err := ioutil.ReadFile(caFile)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(ca))
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, Certificates: certs}}}
+}
+
+func writePEM(t *testing.T, path string, blockType string, der []byte) {
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+}
+
+func TestTLSOptions__GenerateCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	config, err := TLSOptions{GenerateCA: filepath.Join(dir, "ca.pem"), Hosts: []string{"content-api", "10.0.0.1"}}.Config()
+	require.NoError(t, err)
+
+	leaf := config.Certificates[0].Leaf
+	assert.Contains(t, leaf.DNSNames, "localhost")
+	assert.Contains(t, leaf.DNSNames, "content-api")
+	assert.Equal(t, "10.0.0.1", leaf.IPAddresses[len(leaf.IPAddresses)-1].String())
+
+	srv := startTLS(t, config)
+	defer srv.Close()
+
+	resp, err := trusting(t, filepath.Join(dir, "ca.pem")).Get(srv.URL + "/__health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = http.Get(srv.URL + "/__health")
+	assert.Error(t, err, "clients which do not trust the CA should be rejected")
+}
+
+func TestTLSOptions__CertAndKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca, caKey, err := newCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "Example CA"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil, nil)
+	require.NoError(t, err)
+
+	cert, key, err := newCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "localhost"}, DNSNames: []string{"localhost"}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}, ca, caKey)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", ca.Raw)
+	writePEM(t, filepath.Join(dir, "cert.pem"), "CERTIFICATE", cert.Raw)
+	writePEM(t, filepath.Join(dir, "key.pem"), "EC PRIVATE KEY", der)
+
+	config, err := TLSOptions{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}.Config()
+	require.NoError(t, err)
+
+	srv := startTLS(t, config)
+	defer srv.Close()
+
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	require.NoError(t, err)
+
+	resp, err := trusting(t, filepath.Join(dir, "ca.pem")).Get("https://localhost:" + port + "/__health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTLSOptions__ClientCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clientCA, clientCAKey, err := newCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "Clients CA"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil, nil)
+	require.NoError(t, err)
+	writePEM(t, filepath.Join(dir, "clients.pem"), "CERTIFICATE", clientCA.Raw)
+
+	clientCert := func(cn string) tls.Certificate {
+		cert, key, err := newCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: cn}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, clientCA, clientCAKey)
+		require.NoError(t, err)
+		return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+	}
+
+	config, err := TLSOptions{GenerateCA: filepath.Join(dir, "ca.pem"), ClientCA: filepath.Join(dir, "clients.pem")}.Config()
+	require.NoError(t, err)
+
+	srv := startTLS(t, config)
+	defer srv.Close()
+
+	for cn, status := range map[string]int{"content-api": http.StatusOK, "people-api": http.StatusForbidden} {
+		resp, err := trusting(t, filepath.Join(dir, "ca.pem"), clientCert(cn)).Get(srv.URL + "/content/1234")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, cn)
+	}
+
+	_, err = trusting(t, filepath.Join(dir, "ca.pem")).Get(srv.URL + "/content/1234")
+	assert.Error(t, err, "clients without a certificate should be rejected")
+}
+
+func TestTLSOptions__Invalid(t *testing.T) {
+	config, err := TLSOptions{}.Config()
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	_, err = TLSOptions{Cert: "cert.pem"}.Config()
+	assert.Equal(t, ErrNoTLSKey, err)
+
+	_, err = TLSOptions{Cert: "cert.pem", Key: "key.pem", GenerateCA: "ca.pem"}.Config()
+	assert.Equal(t, ErrTLSConflict, err)
+
+	_, err = TLSOptions{ClientCA: "clients.pem"}.Config()
+	assert.Equal(t, ErrNoTLS, err)
+
+	_, err = TLSOptions{Cert: "missing.pem", Key: "missing.pem"}.Config()
+	assert.True(t, os.IsNotExist(err))
+}
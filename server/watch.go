@@ -0,0 +1,112 @@
+package server
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultWatchInterval is how often the watched files are checked for changes
+const DefaultWatchInterval = time.Second
+
+// watcher reloads the fixtures files when any of them, or any file they refer to (i.e. an included file, or an OpenAPI document), changes
+type watcher struct {
+	config   *configuration
+	patterns []string
+	// files are every fixtures file read when the fixtures were last loaded
+	files []string
+	// checksums are the contents of every watched file when the fixtures were last loaded. Files which cannot be read have an empty checksum.
+	checksums map[string]string
+}
+
+func newWatcher(config *configuration, patterns []string) *watcher {
+	w := &watcher{config: config, patterns: patterns}
+	_, w.files, _ = LoadFixtures(patterns)
+	w.checksums = w.snapshot()
+	return w
+}
+
+// snapshot checksums the fixtures files, and every file read while applying the latest fixtures
+func (w *watcher) snapshot() map[string]string {
+	checksums := make(map[string]string)
+	for _, f := range append(append(w.resolved(), w.files...), w.config.Sources()...) {
+		checksums[f] = checksum(f)
+	}
+	return checksums
+}
+
+// resolved finds the fixtures files which currently match the patterns, so new files in a watched directory are noticed
+func (w *watcher) resolved() []string {
+	files := make([]string, 0)
+	for _, p := range w.patterns {
+		matches, _ := resolve(p)
+		files = append(files, matches...)
+	}
+	return files
+}
+
+func checksum(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha1.Sum(data)
+	return string(sum[:])
+}
+
+// changed returns true if any watched file has been modified, created or removed since the fixtures were last loaded
+func (w *watcher) changed() bool {
+	for _, f := range w.resolved() {
+		if _, ok := w.checksums[f]; !ok {
+			return true
+		}
+	}
+
+	for f, sum := range w.checksums {
+		if checksum(f) != sum {
+			return true
+		}
+	}
+	return false
+}
+
+// Reload loads the fixtures files if a watched file has changed. If the fixtures cannot be loaded, the current fixtures are kept, and the error is logged.
+func (w *watcher) Reload() {
+	if !w.changed() {
+		return
+	}
+
+	yml, files, err := LoadFixtures(w.patterns)
+	w.files = files
+	defer func() {
+		w.checksums = w.snapshot()
+	}()
+
+	if err != nil {
+		log.WithError(err).WithField("fixtures", w.patterns).Error("Failed to read the fixtures files, keeping the current fixtures")
+		return
+	}
+
+	if err := w.config.Startup(yml); err != nil {
+		log.WithError(err).WithField("fixtures", w.patterns).Error("Failed to reload the fixtures files, keeping the current fixtures")
+		return
+	}
+	log.WithField("fixtures", w.patterns).Info("Reloaded the fixtures files")
+}
+
+// Watch checks the watched files for changes every interval, until stop is closed
+func (w *watcher) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.Reload()
+		case <-stop:
+			return
+		}
+	}
+}
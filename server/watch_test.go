@@ -0,0 +1,179 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFixtures(t *testing.T, path string, yml string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(yml), 0644))
+}
+
+func TestWatcher__Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ersatz-fixtures.yml")
+	writeFixtures(t, path, startupFixturesTestYAML)
+
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+	w := newWatcher(c, []string{path})
+
+	writeFixtures(t, path, replacementFixturesTestYAML)
+	w.Reload()
+	assert.Equal(t, http.StatusNotFound, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+
+	require.NoError(t, c.Reset())
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"), "the reloaded fixtures are used on reset")
+}
+
+func TestWatcher__InvalidFixturesKeepsCurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ersatz-fixtures.yml")
+	writeFixtures(t, path, startupFixturesTestYAML)
+
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+	w := newWatcher(c, []string{path})
+
+	writeFixtures(t, path, "version: 2.0.0\nfixtures: [")
+	w.Reload()
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+
+	require.NoError(t, os.Remove(path))
+	w.Reload()
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+
+	writeFixtures(t, path, replacementFixturesTestYAML)
+	w.Reload()
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+}
+
+func TestWatcher__MissingFixturesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ersatz-fixtures.yml")
+	c := newConfiguration()
+	w := newWatcher(c, []string{path})
+
+	writeFixtures(t, path, startupFixturesTestYAML)
+	w.Reload()
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+}
+
+func TestWatcher__OpenAPIChanges(t *testing.T) {
+	dir := writeOpenAPI(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ersatz-fixtures.yml")
+	yml := "version: 2.0.0\nopenapi: openapi.yml\nfixtures: {}\n"
+	writeFixtures(t, path, yml)
+
+	c := newConfiguration()
+	c.dir = dir
+	require.NoError(t, c.Startup([]byte(yml)))
+	w := newWatcher(c, []string{path})
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__health"))
+
+	writeFixtures(t, filepath.Join(dir, "openapi.yml"), `
+openapi: 3.0.0
+paths:
+  /__health:
+    get:
+      responses:
+        "200":
+          description: Healthy
+`)
+	w.Reload()
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+}
+
+func TestWatcher__Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ersatz-fixtures.yml")
+	writeFixtures(t, path, startupFixturesTestYAML)
+
+	c := newConfiguration()
+	require.NoError(t, c.Startup([]byte(startupFixturesTestYAML)))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	w := newWatcher(c, []string{path})
+	go w.Watch(10*time.Millisecond, stop)
+
+	writeFixtures(t, path, replacementFixturesTestYAML)
+	deadline := time.Now().Add(time.Second)
+	for serve(c, "GET", "/__gtg") != http.StatusServiceUnavailable && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+}
+
+func TestWatcher__IncludedFileChanges(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"ersatz-fixtures.yml": "version: 2.0.0\ninclude: services\nfixtures: {}\n",
+		"services/health.yml": startupFixturesTestYAML,
+	})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, filepath.Join(dir, "ersatz-fixtures.yml"))
+	w := newWatcher(c, []string{filepath.Join(dir, "ersatz-fixtures.yml")})
+
+	writeFixtures(t, filepath.Join(dir, "services", "health.yml"), "version: 2.0.0\nfixtures:\n  /__health:\n    get:\n      status: 503\n")
+	w.Reload()
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__health"))
+}
+
+func TestWatcher__NewFileInDirectory(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{"health.yml": startupFixturesTestYAML})
+	defer os.RemoveAll(dir)
+
+	c := loadTestFixtures(t, dir)
+	w := newWatcher(c, []string{dir})
+
+	writeFixtures(t, filepath.Join(dir, "gtg.yml"), replacementFixturesTestYAML)
+	w.Reload()
+	assert.Equal(t, http.StatusOK, serve(c, "GET", "/__health"))
+	assert.Equal(t, http.StatusServiceUnavailable, serve(c, "GET", "/__gtg"))
+}
+
+func TestWatcher__BodyFileChanges(t *testing.T) {
+	dir := tempFixtures(t, map[string]string{
+		"ersatz-fixtures.yml": "version: 2.0.0\nfixtures:\n  /__gtg:\n    get:\n      status: 200\n      headers:\n        content-type: text/plain\n      bodyFile: gtg.txt\n",
+		"gtg.txt":             "OK",
+	})
+	defer os.RemoveAll(dir)
+
+	c := newConfiguration()
+	c.dir = dir
+	yml, err := ioutil.ReadFile(filepath.Join(dir, "ersatz-fixtures.yml"))
+	require.NoError(t, err)
+	require.NoError(t, c.Startup(yml))
+	w := newWatcher(c, []string{filepath.Join(dir, "ersatz-fixtures.yml")})
+
+	writeFixtures(t, filepath.Join(dir, "gtg.txt"), "Still OK")
+	w.Reload()
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/__gtg", nil))
+	assert.Equal(t, "Still OK", rec.Body.String())
+}
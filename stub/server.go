@@ -0,0 +1,165 @@
+package stub
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/peteclark-ft/ersatz/server"
+)
+
+// T is the part of *testing.T used to report failures, so the package can be used without importing testing
+type T interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// cleaner is implemented by *testing.T in Go 1.14 and later
+type cleaner interface {
+	Cleanup(func())
+}
+
+// Server is an ersatz server listening on a local port, which fails the test when it is closed if an expectation was not met or a request did not match any fixture
+type Server struct {
+	// AllowUnmatched stops requests which did not match any fixture from failing the test
+	AllowUnmatched bool
+
+	t            T
+	ersatz       *server.Server
+	listener     *httptest.Server
+	stubs        []*Stub
+	indexes      []int
+	expectations []journal.Verification
+	once         sync.Once
+}
+
+// NewServer starts a server with the stubs. Stubs for the same method and path are tried in the order provided.
+func NewServer(t T, stubs ...*Stub) *Server {
+	t.Helper()
+
+	fixtures := make(map[string]map[string][]interface{})
+	indexes := make([]int, 0, len(stubs))
+	for _, s := range stubs {
+		methods, ok := fixtures[s.path]
+		if !ok {
+			methods = make(map[string][]interface{})
+			fixtures[s.path] = methods
+		}
+
+		indexes = append(indexes, len(methods[s.method]))
+		methods[s.method] = append(methods[s.method], s.discriminator())
+	}
+
+	doc, err := json.Marshal(map[string]interface{}{"version": "2.0.0", "fixtures": fixtures})
+	if err != nil {
+		t.Fatalf("failed to build the fixtures: %v", err)
+	}
+
+	s := start(t, server.Options{}, doc)
+	s.stubs = stubs
+	s.indexes = indexes
+	return s
+}
+
+// FromYAML starts a server with the fixtures document
+func FromYAML(t T, yml string) *Server {
+	t.Helper()
+	return start(t, server.Options{}, []byte(yml))
+}
+
+// FromFile starts a server with the fixtures files, directories or globs, which are combined in the same way as the --fixtures option
+func FromFile(t T, patterns ...string) *Server {
+	t.Helper()
+
+	yml, files, err := server.LoadFixtures(patterns)
+	if err != nil {
+		t.Fatalf("failed to read the fixtures files: %v", err)
+	}
+	return start(t, server.Options{Dir: server.FixturesDir(patterns, files)}, yml)
+}
+
+func start(t T, opts server.Options, yml []byte) *Server {
+	t.Helper()
+
+	s := &Server{t: t, ersatz: server.New(opts)}
+	if err := s.ersatz.Startup(yml); err != nil {
+		t.Fatalf("failed to load the fixtures: %v", err)
+	}
+
+	s.listener = httptest.NewServer(s.ersatz)
+	if c, ok := t.(cleaner); ok {
+		c.Cleanup(s.Close)
+	}
+	return s
+}
+
+// URL is the base URL of the server, i.e. http://127.0.0.1:54321
+func (s *Server) URL() string {
+	return s.listener.URL
+}
+
+// Journal returns every request the server has received
+func (s *Server) Journal() *journal.Journal {
+	return s.ersatz.Journal()
+}
+
+// Replace replaces every fixture with the fixtures document. Expectations of the stubs the server was started with are still verified.
+func (s *Server) Replace(yml string) error {
+	return s.ersatz.Replace([]byte(yml))
+}
+
+// Expect adds a verification of the journal, which is checked when the server is closed
+func (s *Server) Expect(v journal.Verification) {
+	s.expectations = append(s.expectations, v)
+}
+
+// ExpectRequest expects at least one request with the method and path
+func (s *Server) ExpectRequest(method string, path string) {
+	one := 1
+	s.Expect(journal.Verification{Filter: journal.Filter{Method: method, Path: path}, AtLeast: &one})
+}
+
+// Close shuts the server down, then fails the test for every unmet expectation and unmatched request. It is called automatically at the end of the test if the T supports Cleanup, and may safely be called more than once.
+func (s *Server) Close() {
+	s.once.Do(func() {
+		s.t.Helper()
+		s.listener.Close()
+		s.verify()
+	})
+}
+
+func (s *Server) verify() {
+	s.t.Helper()
+
+	j := s.ersatz.Journal()
+	for i, stub := range s.stubs {
+		if err := stub.verify(j, s.indexes[i]); err != nil {
+			s.t.Errorf("%v", err)
+		}
+	}
+
+	for _, v := range s.expectations {
+		if res := j.Verify(v); !res.OK {
+			filter, _ := json.Marshal(v.Filter)
+			s.t.Errorf("%v for %v", res.Message, string(filter))
+		}
+	}
+
+	if s.AllowUnmatched {
+		return
+	}
+
+	unmatched := j.Entries(journal.Filter{Unmatched: true})
+	if len(unmatched) == 0 {
+		return
+	}
+
+	requests := make([]string, 0, len(unmatched))
+	for _, e := range unmatched {
+		requests = append(requests, e.Method+" "+e.Path)
+	}
+	s.t.Errorf("received %v requests which did not match any fixture: %v", len(unmatched), strings.Join(requests, ", "))
+}
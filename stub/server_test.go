@@ -0,0 +1,157 @@
+package stub
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fatal is panicked by mockT.Fatalf, to stop the code under test in the same way as testing.T
+type fatal struct{}
+
+type mockT struct {
+	errors []string
+}
+
+func (m *mockT) Helper() {}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func (m *mockT) Fatalf(format string, args ...interface{}) {
+	m.Errorf(format, args...)
+	panic(fatal{})
+}
+
+func assertFatal(t *testing.T, m *mockT, fn func()) {
+	defer func() {
+		assert.Equal(t, fatal{}, recover())
+	}()
+	fn()
+	t.Errorf("expected the mock T to fail: %v", m.errors)
+}
+
+func get(t *testing.T, url string) *http.Response {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+const serverTestYAML = `
+version: 2.0.0
+fixtures:
+  /__health:
+    get:
+      status: 200
+  /__gtg:
+    get:
+      status: 503
+`
+
+func TestFromYAML(t *testing.T) {
+	m := &mockT{}
+	s := FromYAML(m, serverTestYAML)
+	s.ExpectRequest("GET", "/__health")
+
+	assert.Equal(t, http.StatusOK, get(t, s.URL()+"/__health").StatusCode)
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, s.URL()+"/__gtg").StatusCode)
+
+	s.Close()
+	s.Close()
+	assert.Empty(t, m.errors)
+}
+
+func TestFromYAML__Invalid(t *testing.T) {
+	m := &mockT{}
+	assertFatal(t, m, func() {
+		FromYAML(m, "version: 3.0.0\nfixtures: {}\n")
+	})
+	assert.Len(t, m.errors, 1)
+	assert.Contains(t, m.errors[0], "failed to load the fixtures")
+}
+
+func TestFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ersatz-fixtures.yml"), []byte("version: 2.0.0\nfixtures:\n  /content/{uuid}:\n    get:\n      status: 200\n      bodyFile: content.json\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "content.json"), []byte(`{"title":"Example Title"}`), 0644))
+
+	m := &mockT{}
+	s := FromFile(m, dir)
+
+	resp, err := http.Get(s.URL() + "/content/1234")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"title":"Example Title"}`, string(body))
+
+	s.Close()
+	assert.Empty(t, m.errors)
+
+	assertFatal(t, m, func() {
+		FromFile(m, filepath.Join(dir, "missing.yml"))
+	})
+	assert.Contains(t, m.errors[0], "failed to read the fixtures files")
+}
+
+func TestServer__UnmetExpectations(t *testing.T) {
+	m := &mockT{}
+	s := FromYAML(m, serverTestYAML)
+
+	once := 1
+	s.ExpectRequest("GET", "/__gtg")
+	s.Expect(journal.Verification{Filter: journal.Filter{Path: "/__health"}, Count: &once})
+
+	get(t, s.URL()+"/__health")
+	get(t, s.URL()+"/__health")
+
+	s.Close()
+	assert.Equal(t, []string{
+		`expected at least 1 matching requests, but found 0 for {"method":"GET","path":"/__gtg"}`,
+		`expected exactly 1 matching requests, but found 2 for {"path":"/__health"}`,
+	}, m.errors)
+}
+
+func TestServer__UnmatchedRequests(t *testing.T) {
+	m := &mockT{}
+	s := FromYAML(m, serverTestYAML)
+
+	assert.Equal(t, http.StatusNotFound, get(t, s.URL()+"/content/1234").StatusCode)
+	get(t, s.URL()+"/__health")
+
+	s.Close()
+	assert.Equal(t, []string{"received 1 requests which did not match any fixture: GET /content/1234"}, m.errors)
+
+	m = &mockT{}
+	s = FromYAML(m, serverTestYAML)
+	s.AllowUnmatched = true
+
+	get(t, s.URL()+"/content/1234")
+	s.Close()
+	assert.Empty(t, m.errors)
+}
+
+func TestServer__Replace(t *testing.T) {
+	m := &mockT{}
+	s := FromYAML(m, serverTestYAML)
+
+	require.NoError(t, s.Replace("version: 2.0.0\nfixtures:\n  /__gtg:\n    get:\n      status: 200\n"))
+	assert.Equal(t, http.StatusOK, get(t, s.URL()+"/__gtg").StatusCode)
+	assert.Len(t, s.Journal().Entries(journal.Filter{}), 1)
+
+	s.Close()
+	assert.Empty(t, m.errors)
+}
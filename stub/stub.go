@@ -0,0 +1,198 @@
+package stub
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/peteclark-ft/ersatz/journal"
+)
+
+// Stub is a fixture built in Go, which responds to requests for its method and path that satisfy every When condition. Each stub is expected to be called at least once, unless Times or AnyTimes is used.
+type Stub struct {
+	method     string
+	path       string
+	when       map[string]interface{}
+	conditions []string
+	response   map[string]interface{}
+	headers    map[string]string
+	body       interface{}
+
+	count    *int
+	anyTimes bool
+}
+
+// On creates a stub for requests with the method and path, which can use the same {param} and * segments as a fixtures file
+func On(method string, path string) *Stub {
+	return &Stub{
+		method:   strings.ToLower(method),
+		path:     path,
+		when:     make(map[string]interface{}),
+		response: map[string]interface{}{"status": http.StatusOK},
+		headers:  make(map[string]string),
+	}
+}
+
+// Get creates a stub for GET requests to the path
+func Get(path string) *Stub {
+	return On(http.MethodGet, path)
+}
+
+// Post creates a stub for POST requests to the path
+func Post(path string) *Stub {
+	return On(http.MethodPost, path)
+}
+
+// Put creates a stub for PUT requests to the path
+func Put(path string) *Stub {
+	return On(http.MethodPut, path)
+}
+
+// Patch creates a stub for PATCH requests to the path
+func Patch(path string) *Stub {
+	return On(http.MethodPatch, path)
+}
+
+// Delete creates a stub for DELETE requests to the path
+func Delete(path string) *Stub {
+	return On(http.MethodDelete, path)
+}
+
+// WhenHeader only matches requests with the header. The value may use the same ${...} expressions as a fixtures file, i.e. ${exists}.
+func (s *Stub) WhenHeader(name string, value string) *Stub {
+	return s.condition("headers", name, value, "header")
+}
+
+// WhenQuery only matches requests with the query param
+func (s *Stub) WhenQuery(name string, value string) *Stub {
+	return s.condition("queryParams", name, value, "query param")
+}
+
+// WhenPathParam only matches requests whose path captures the value for the {name} segment
+func (s *Stub) WhenPathParam(name string, value string) *Stub {
+	return s.condition("pathParams", name, value, "path param")
+}
+
+// WhenJSON only matches requests whose JSON body has the value at the JSONPath, i.e. $.content.title
+func (s *Stub) WhenJSON(path string, value string) *Stub {
+	body := s.section(s.when, "body")
+	s.section(body, "json")[path] = value
+	s.conditions = append(s.conditions, fmt.Sprintf("json %v=%v", path, value))
+	return s
+}
+
+// WhenBodyContains only matches requests whose body contains the text
+func (s *Stub) WhenBodyContains(text string) *Stub {
+	body := s.section(s.when, "body")
+	s.section(body, "text")["contains"] = text
+	s.conditions = append(s.conditions, fmt.Sprintf("body containing '%v'", text))
+	return s
+}
+
+// Respond sets the status and body of the response. Strings are sent as text/plain, byte slices as application/octet-stream, and anything else is serialised as JSON, unless a content-type header is provided.
+func (s *Stub) Respond(status int, body interface{}) *Stub {
+	s.response["status"] = status
+	s.body = body
+	return s
+}
+
+// WithHeader adds a header to the response
+func (s *Stub) WithHeader(name string, value string) *Stub {
+	s.headers[name] = value
+	return s
+}
+
+// Times expects the stub to be called exactly n times
+func (s *Stub) Times(n int) *Stub {
+	s.count = &n
+	s.anyTimes = false
+	return s
+}
+
+// AnyTimes allows the stub to be called any number of times, including none
+func (s *Stub) AnyTimes() *Stub {
+	s.count = nil
+	s.anyTimes = true
+	return s
+}
+
+func (s *Stub) String() string {
+	desc := strings.ToUpper(s.method) + " " + s.path
+	if len(s.conditions) > 0 {
+		desc += " with " + strings.Join(s.conditions, ", ")
+	}
+	return desc
+}
+
+func (s *Stub) condition(section string, name string, value string, desc string) *Stub {
+	s.section(s.when, section)[name] = value
+	s.conditions = append(s.conditions, fmt.Sprintf("%v %v=%v", desc, name, value))
+	return s
+}
+
+func (s *Stub) section(parent map[string]interface{}, name string) map[string]interface{} {
+	m, ok := parent[name].(map[string]interface{})
+	if !ok {
+		m = make(map[string]interface{})
+		parent[name] = m
+	}
+	return m
+}
+
+// discriminator is the stub as a discriminator in a 2.0.0 fixtures document
+func (s *Stub) discriminator() map[string]interface{} {
+	headers := make(map[string]string)
+	for k, v := range s.headers {
+		headers[strings.ToLower(k)] = v
+	}
+
+	response := make(map[string]interface{})
+	for k, v := range s.response {
+		response[k] = v
+	}
+
+	contentType := "application/json"
+	switch body := s.body.(type) {
+	case nil:
+	case []byte:
+		contentType = "application/octet-stream"
+		response["base64Body"] = base64.StdEncoding.EncodeToString(body)
+	case string:
+		contentType = "text/plain"
+		response["body"] = body
+	default:
+		response["body"] = body
+	}
+
+	if _, ok := headers["content-type"]; !ok && s.body != nil {
+		headers["content-type"] = contentType
+	}
+
+	if len(headers) > 0 {
+		response["headers"] = headers
+	}
+	return map[string]interface{}{"when": s.when, "response": response}
+}
+
+// verify checks how many requests the stub responded to, as the discriminator at the index of its fixture
+func (s *Stub) verify(j *journal.Journal, index int) error {
+	if s.anyTimes {
+		return nil
+	}
+
+	calls := 0
+	for _, e := range j.Entries(journal.Filter{Fixture: s.path}) {
+		if e.Fixture.Discriminator != nil && *e.Fixture.Discriminator == index && strings.EqualFold(e.Fixture.Method, s.method) {
+			calls++
+		}
+	}
+
+	switch {
+	case s.count != nil && calls != *s.count:
+		return fmt.Errorf("expected %v to be called %v times, but it was called %v times", s, *s.count, calls)
+	case s.count == nil && calls == 0:
+		return fmt.Errorf("expected %v to be called, but it was not", s)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package stub
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServer(t *testing.T) {
+	m := &mockT{}
+	s := NewServer(m,
+		Get("/content/{uuid}").WhenHeader("Authorization", "${exists}").Respond(200, map[string]string{"title": "Example Title"}),
+		Get("/content/{uuid}").Respond(401, "Unauthorized"),
+		Get("/images/{id}").WhenPathParam("id", "pixel").Respond(200, []byte{0x47, 0x49, 0x46}).WithHeader("Content-Type", "image/gif"),
+		Post("/content").WhenJSON("$.title", "${exists}").Respond(201, nil).WithHeader("Location", "/content/1234"),
+	)
+
+	req, err := http.NewRequest("GET", s.URL()+"/content/1234", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer 1234")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"title":"Example Title"}`, string(body))
+
+	resp, err = http.Get(s.URL() + "/content/1234")
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "text/plain", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "Unauthorized", string(body))
+
+	resp, err = http.Get(s.URL() + "/images/pixel")
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "image/gif", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "GIF", string(body))
+
+	resp, err = http.Post(s.URL()+"/content", "application/json", strings.NewReader(`{"title":"Example Title"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "/content/1234", resp.Header.Get("Location"))
+
+	s.Close()
+	assert.Empty(t, m.errors)
+}
+
+func TestNewServer__Expectations(t *testing.T) {
+	m := &mockT{}
+	s := NewServer(m,
+		Get("/__health").Respond(200, nil),
+		Get("/__gtg").WhenQuery("verbose", "true").Respond(200, nil).Times(2),
+		Get("/__gtg").Respond(503, nil),
+		Delete("/content/{uuid}").Respond(204, nil).AnyTimes(),
+	)
+
+	get(t, s.URL()+"/__gtg?verbose=true")
+	get(t, s.URL()+"/__gtg")
+	get(t, s.URL()+"/__gtg")
+
+	s.Close()
+	assert.Equal(t, []string{
+		"expected GET /__health to be called, but it was not",
+		"expected GET /__gtg with query param verbose=true to be called 2 times, but it was called 1 times",
+	}, m.errors)
+}
+
+func TestNewServer__UnmatchedDiscriminators(t *testing.T) {
+	m := &mockT{}
+	s := NewServer(m, Put("/content/{uuid}").WhenBodyContains("Example Title").Respond(200, nil))
+
+	req, err := http.NewRequest("PUT", s.URL()+"/content/1234", strings.NewReader("Another Title"))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+
+	s.Close()
+	assert.Equal(t, []string{
+		"expected PUT /content/{uuid} with body containing 'Example Title' to be called, but it was not",
+		"received 1 requests which did not match any fixture: PUT /content/1234",
+	}, m.errors)
+}
@@ -27,8 +27,5 @@ type Resource struct {
 
 // Router allows us to test that paths are configured properly
 type Router interface {
-	Get(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
-	Put(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
-	Post(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
-	Delete(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
+	Add(method string, path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
 }
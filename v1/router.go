@@ -2,31 +2,45 @@ package v1
 
 import (
 	"encoding/json"
+	"errors"
 	"mime"
 	"net/http"
+	"strings"
 
 	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
 	log "github.com/sirupsen/logrus"
 )
 
-// MockPaths adds endpoints to the provided router as per the ersatz-fixtures.yml
+// MockPaths adds endpoints to the provided router as per the ersatz-fixtures.yml. Paths with a get fixture also respond to HEAD requests, and every path responds to OPTIONS requests with the methods it allows, unless they have their own fixtures.
 func MockPaths(r Router, paths *Fixtures) {
 	for p, path := range *paths {
+		methods := make([]string, 0, len(path))
 		for method, resource := range path {
-			switch method {
-			case "get":
-				r.Get(p, mockResource(resource))
-			case "post":
-				r.Post(p, mockResource(resource))
-			case "put":
-				r.Put(p, mockResource(resource))
-			case "delete":
-				r.Delete(p, mockResource(resource))
-			}
+			r.Add(strings.ToUpper(method), p, mockResource(resource), v2.JournalFixture(p, method))
+			methods = append(methods, strings.ToUpper(method))
+		}
+
+		if method, get, ok := path.find(http.MethodGet); ok && !Contains(http.MethodHead, methods...) {
+			r.Add(http.MethodHead, p, mockResource(get), v2.JournalFixture(p, method))
+		}
+
+		if !Contains(http.MethodOptions, methods...) {
+			r.Add(http.MethodOptions, p, v2.Allow(v2.AllowedMethods(methods)), v2.JournalFixture(p, "options"))
 		}
 	}
 }
 
+// find returns the fixture for the method, which may be declared in any case, and the method it was declared as
+func (p Path) find(method string) (string, Resource, bool) {
+	for m, resource := range p {
+		if strings.EqualFold(m, method) {
+			return m, resource, true
+		}
+	}
+	return "", Resource{}, false
+}
+
 func mockResource(res Resource) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if res.Expectations != nil && !res.Expectations.AtLeastOneExpectationPasses(r) {
@@ -62,7 +76,11 @@ func mockResource(res Resource) func(w http.ResponseWriter, r *http.Request) {
 		case "application/x-yaml":
 			output, err = yaml.Marshal(res.Body)
 		case "text/plain":
-			output = []byte(res.Body.(string))
+			text, ok := res.Body.(string)
+			if !ok {
+				err = errors.New("expected the body to be a string to be sent as text/plain")
+			}
+			output = []byte(text)
 		}
 
 		if err != nil {
@@ -22,10 +22,12 @@ func TestMockPaths(t *testing.T) {
 	p["delete"] = r
 
 	mockRouter := new(MockRouter)
-	mockRouter.On("Get", "/example", mock.Anything)
-	mockRouter.On("Post", "/example", mock.Anything)
-	mockRouter.On("Put", "/example", mock.Anything)
-	mockRouter.On("Delete", "/example", mock.Anything)
+	mockRouter.On("Add", "GET", "/example", mock.Anything)
+	mockRouter.On("Add", "POST", "/example", mock.Anything)
+	mockRouter.On("Add", "PUT", "/example", mock.Anything)
+	mockRouter.On("Add", "DELETE", "/example", mock.Anything)
+	mockRouter.On("Add", "HEAD", "/example", mock.Anything)
+	mockRouter.On("Add", "OPTIONS", "/example", mock.Anything)
 
 	MockPaths(mockRouter, &f)
 	mockRouter.AssertExpectations(t)
@@ -42,6 +44,16 @@ func TestMockResourcePlaintextResponse(t *testing.T) {
 	assert.Equal(t, http.StatusTeapot, w.Code)
 }
 
+func TestMockResourcePlaintextResponse__WithNonTextBody(t *testing.T) {
+	res := Resource{Status: http.StatusOK, Body: map[string]interface{}{"title": "Example"}, Headers: map[string]string{"content-type": "text/plain"}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	assert.NotPanics(t, func() { mockResource(res)(w, r) })
+	assert.Empty(t, w.Body.String())
+}
+
 func TestMockResourceJSONResponseIsDefault(t *testing.T) {
 	res := Resource{Status: http.StatusTeapot, Body: "OK"}
 
@@ -133,15 +145,45 @@ type MockRouter struct {
 	mock.Mock
 }
 
-func (m *MockRouter) Get(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+func (m *MockRouter) Add(method string, path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
+	m.Called(method, path, handler)
 }
-func (m *MockRouter) Put(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
-}
-func (m *MockRouter) Post(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+
+func TestMockPaths__AllMethods(t *testing.T) {
+	f := Fixtures{"/example": Path{
+		"get":   Resource{Status: http.StatusOK, Body: "OK", Headers: map[string]string{"content-type": "text/plain"}},
+		"patch": Resource{Status: http.StatusNoContent},
+	}}
+
+	handlers := make(map[string]http.HandlerFunc)
+	router := new(MockRouter)
+	router.On("Add", mock.Anything, "/example", mock.Anything).Run(func(args mock.Arguments) {
+		handlers[args.String(0)] = args.Get(2).(http.HandlerFunc)
+	})
+	MockPaths(router, &f)
+
+	assert.Len(t, handlers, 4)
+	assert.Contains(t, handlers, "PATCH")
+	assert.Contains(t, handlers, "HEAD")
+
+	w := httptest.NewRecorder()
+	handlers["OPTIONS"](w, httptest.NewRequest("OPTIONS", "/example", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS, PATCH", w.Header().Get("Allow"))
 }
-func (m *MockRouter) Delete(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+
+func TestMockPaths__UpperCaseMethods(t *testing.T) {
+	f := Fixtures{"/example": Path{
+		"GET":     Resource{Status: http.StatusOK},
+		"OPTIONS": Resource{Status: http.StatusTeapot},
+	}}
+
+	handlers := make(map[string]int)
+	router := new(MockRouter)
+	router.On("Add", mock.Anything, "/example", mock.Anything).Run(func(args mock.Arguments) {
+		handlers[args.String(0)]++
+	})
+	MockPaths(router, &f)
+
+	assert.Equal(t, map[string]int{"GET": 1, "HEAD": 1, "OPTIONS": 1}, handlers, "the OPTIONS fixture should not be overridden")
 }
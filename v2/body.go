@@ -0,0 +1,295 @@
+package v2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Body discriminates requests on the contents of the request body
+type Body struct {
+	JSON JSONFields `json:"json"`
+	Form FormFields `json:"form"`
+	Text Text       `json:"text"`
+}
+
+// JSONFields maps JSONPath (i.e. $.content.title) or dot-path (i.e. content.title) expressions to the values expected at that location in a JSON request body
+type JSONFields QueryParams
+
+// FormFields are the fields expected in an application/x-www-form-urlencoded request body
+type FormFields QueryParams
+
+// Text matches the raw request body
+type Text struct {
+	Equals          string
+	Contains        string
+	Regex           *regexp.Regexp
+	TemplatedValues TemplatedValues
+	Expressions     Expressions
+}
+
+// IsEmpty returns true if no body discriminators have been configured
+func (b Body) IsEmpty() bool {
+	return len(b.JSON.Values) == 0 && len(b.JSON.TemplatedValues) == 0 &&
+		len(b.Form.Values) == 0 && len(b.Form.TemplatedValues) == 0 &&
+		b.Text.IsEmpty()
+}
+
+// IsEmpty returns true if no text discriminators have been configured
+func (t Text) IsEmpty() bool {
+	return t.Equals == "" && t.Contains == "" && t.Regex == nil && len(t.TemplatedValues) == 0
+}
+
+// Validate validates the expected body against the request body, which remains readable afterwards
+func (b Body) Validate(req *http.Request) bool {
+	return len(b.Diagnose(req)) == 0
+}
+
+// Diagnose returns the expected body values which do not match the request body, which remains readable afterwards
+func (b Body) Diagnose(req *http.Request) []Mismatch {
+	if b.IsEmpty() {
+		return nil
+	}
+
+	body, err := readBody(req)
+	if err != nil {
+		return []Mismatch{{Location: "body", Expected: "a readable body", Actual: err.Error()}}
+	}
+
+	mismatches := b.JSON.Diagnose(body)
+	mismatches = append(mismatches, b.Form.Diagnose(req.Header.Get("Content-Type"), body)...)
+	mismatches = append(mismatches, b.Text.Diagnose(body)...)
+	return mismatches
+}
+
+// Validate extracts the value at each expected path from the JSON body, and compares them to the expected values
+func (j JSONFields) Validate(body []byte) bool {
+	return len(j.Diagnose(body)) == 0
+}
+
+// Diagnose returns the expected JSON paths whose values do not match those in the JSON body
+func (j JSONFields) Diagnose(body []byte) []Mismatch {
+	if len(j.Values) == 0 && len(j.TemplatedValues) == 0 {
+		return nil
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		doc = nil
+	}
+
+	return diagnoseValues("body.json", j.Values, j.TemplatedValues, j.Expressions, func(path string) string {
+		return JSONPathValue(doc, path)
+	})
+}
+
+// Validate parses the body as an application/x-www-form-urlencoded form, and compares the fields to the expected values
+func (f FormFields) Validate(contentType string, body []byte) bool {
+	return len(f.Diagnose(contentType, body)) == 0
+}
+
+// Diagnose returns the expected form fields which do not match those in an application/x-www-form-urlencoded body
+func (f FormFields) Diagnose(contentType string, body []byte) []Mismatch {
+	if len(f.Values) == 0 && len(f.TemplatedValues) == 0 {
+		return nil
+	}
+
+	actual := make(url.Values)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "application/x-www-form-urlencoded" {
+		if parsed, err := url.ParseQuery(string(body)); err == nil {
+			actual = parsed
+		}
+	}
+
+	return diagnoseValues("body.form", f.Values, f.TemplatedValues, f.Expressions, actual.Get)
+}
+
+// Validate compares the raw body to the expected text
+func (t Text) Validate(body []byte) bool {
+	return len(t.Diagnose(body)) == 0
+}
+
+// Diagnose returns the text matchers which the raw body does not satisfy
+func (t Text) Diagnose(body []byte) []Mismatch {
+	text := string(body)
+	mismatches := diagnoseValues("body.text", nil, t.TemplatedValues, t.Expressions, func(string) string {
+		return text
+	})
+
+	if t.Equals != "" && t.Equals != text {
+		mismatches = append(mismatches, Mismatch{Location: "body.text", Name: "equals", Expected: t.Equals, Actual: truncate(text)})
+	}
+
+	if t.Contains != "" && !strings.Contains(text, t.Contains) {
+		mismatches = append(mismatches, Mismatch{Location: "body.text", Name: "contains", Expected: t.Contains, Actual: truncate(text)})
+	}
+
+	if t.Regex != nil && !t.Regex.MatchString(text) {
+		mismatches = append(mismatches, Mismatch{Location: "body.text", Name: "regex", Expected: t.Regex.String(), Actual: truncate(text)})
+	}
+	return mismatches
+}
+
+// UnmarshalJSON accepts any scalar value for each JSON path, as the values are compared as strings
+func (j *JSONFields) UnmarshalJSON(d []byte) error {
+	raw := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(d))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return err
+	}
+
+	fields := make(map[string]string)
+	for k, v := range raw {
+		fields[k] = Stringify(v)
+	}
+
+	templated, remainder, err := ParseRequestValues(fields)
+	if err != nil {
+		return err
+	}
+	j.TemplatedValues = templated
+	j.Expressions = NewExpressions(fields, templated)
+
+	j.Values = url.Values{}
+	for k, v := range remainder {
+		j.Add(k, v)
+	}
+	return nil
+}
+
+// UnmarshalJSON creates the expected form fields in the same way as query parameters
+func (f *FormFields) UnmarshalJSON(d []byte) error {
+	return (*QueryParams)(f).UnmarshalJSON(d)
+}
+
+// UnmarshalJSON supports the equals, contains and regex text matchers
+func (t *Text) UnmarshalJSON(d []byte) error {
+	matchers := make(map[string]string)
+	err := json.Unmarshal(d, &matchers)
+	if err != nil {
+		return err
+	}
+
+	templated, remainder, err := ParseRequestValues(matchers)
+	if err != nil {
+		return err
+	}
+	t.TemplatedValues = templated
+	t.Expressions = NewExpressions(matchers, templated)
+
+	for k, v := range remainder {
+		switch k {
+		case "equals":
+			t.Equals = v
+		case "contains":
+			t.Contains = v
+		case "regex":
+			t.Regex, err = regexp.Compile(v)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf(`unsupported text matcher '%v', please use one of equals, contains or regex`, k)
+		}
+	}
+	return nil
+}
+
+// JSONPathValue returns the value found at the path as a string, or an empty string if nothing is found. Objects and arrays are returned as compact JSON.
+func JSONPathValue(doc interface{}, path string) string {
+	current := doc
+	for _, key := range SplitJSONPath(path) {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			current = node[i]
+		default:
+			return ""
+		}
+	}
+	return Stringify(current)
+}
+
+// SplitJSONPath splits both $.a.b[0]['c'] and a.b.0.c into the keys a, b, 0, c
+func SplitJSONPath(path string) []string {
+	path = strings.TrimPrefix(path, "$")
+
+	keys := make([]string, 0)
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			open := strings.Index(part, "[")
+			if open == -1 {
+				keys = append(keys, part)
+				break
+			}
+
+			if open > 0 {
+				keys = append(keys, part[:open])
+			}
+
+			end := strings.Index(part[open:], "]")
+			if end == -1 {
+				keys = append(keys, part[open:])
+				break
+			}
+
+			keys = append(keys, strings.Trim(part[open+1:open+end], `'"`))
+			part = part[open+end+1:]
+		}
+	}
+	return keys
+}
+
+// Stringify converts a value decoded from JSON to the string which discriminators compare against, i.e. numbers without exponents and objects as JSON
+func Stringify(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		out, err := json.Marshal(val)
+		if err != nil {
+			return ""
+		}
+		return string(out)
+	}
+}
+
+// readBody reads the request body, and replaces it so it can be read again by later discriminators
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return []byte{}, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body.Close()
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
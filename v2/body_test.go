@@ -0,0 +1,163 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jsonBodyTestDocument = `{"content":{"title":"Example Title","version":3,"published":true,"tags":[{"name":"first"},{"name":"second"}]}}`
+
+func TestJSONPathValue(t *testing.T) {
+	var doc interface{}
+	decoder := json.NewDecoder(strings.NewReader(jsonBodyTestDocument))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&doc))
+
+	assert.Equal(t, "Example Title", JSONPathValue(doc, "$.content.title"))
+	assert.Equal(t, "Example Title", JSONPathValue(doc, "content.title"))
+	assert.Equal(t, "Example Title", JSONPathValue(doc, "$['content']['title']"))
+	assert.Equal(t, "3", JSONPathValue(doc, "$.content.version"))
+	assert.Equal(t, "true", JSONPathValue(doc, "content.published"))
+	assert.Equal(t, "second", JSONPathValue(doc, "$.content.tags[1].name"))
+	assert.Equal(t, "first", JSONPathValue(doc, "content.tags.0.name"))
+	assert.Equal(t, `{"name":"first"}`, JSONPathValue(doc, "content.tags[0]"))
+	assert.Equal(t, "", JSONPathValue(doc, "content.tags[2].name"))
+	assert.Equal(t, "", JSONPathValue(doc, "$.content.missing"))
+}
+
+func TestDiscriminator__Body__JSON(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.JSON.Add("$.content.title", "Example Title")
+	d.Body.JSON.TemplatedValues["content.tags[0].name"] = Exists
+	d.Body.JSON.TemplatedValues["$.content.uuid"] = Missing
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader(jsonBodyTestDocument))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.True(t, ok)
+}
+
+func TestDiscriminator__Body__JSONFails(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.JSON.Add("$.content.title", "Another Title")
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader(jsonBodyTestDocument))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.False(t, ok)
+}
+
+func TestDiscriminator__Body__JSONInvalidDocumentFails(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.JSON.TemplatedValues["$.content.title"] = Exists
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader(`not json`))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.False(t, ok)
+}
+
+func TestDiscriminator__Body__Form(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.Form.Add("name", "ersatz")
+	d.Body.Form.TemplatedValues["missing"] = Missing
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader("name=ersatz&other=value"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.True(t, ok)
+}
+
+func TestDiscriminator__Body__FormRequiresContentType(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.Form.Add("name", "ersatz")
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader("name=ersatz"))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.False(t, ok)
+}
+
+func TestDiscriminator__Body__Text(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.Text.Contains = "world"
+	d.Body.Text.Regex = regexp.MustCompile("^hello")
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader("hello world"))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.True(t, ok)
+}
+
+func TestDiscriminator__Body__TextFails(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.Text.Equals = "hello"
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader("hello world"))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.False(t, ok)
+}
+
+func TestDiscriminator__Body__TextTemplatedMissing(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.Text.TemplatedValues["equals"] = Missing
+
+	r := httptest.NewRequest("POST", "/url", nil)
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.True(t, ok)
+}
+
+func TestDiscriminator__Body__CanBeReadRepeatedly(t *testing.T) {
+	first := NewRequestDiscriminator()
+	first.Body.Text.Equals = "goodbye"
+
+	second := NewRequestDiscriminator()
+	second.Body.Text.Equals = "hello"
+
+	r := httptest.NewRequest("POST", "/url", strings.NewReader("hello"))
+
+	arr := Discriminators{{When: first}, {When: second}}
+	assert.True(t, arr.AtLeastOneDiscriminatorIsSatisfied(r))
+}
+
+const bodyTestYAML = `
+json:
+  $.content.title: Example Title
+  $.content.version: 3
+  $.content.uuid: ${missing}
+form:
+  name: ${exists}
+text:
+  contains: Example
+  regex: ^\{
+`
+
+func TestBodyUnmarshal(t *testing.T) {
+	b := Body{}
+	err := yaml.Unmarshal([]byte(bodyTestYAML), &b)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Example Title", b.JSON.Get("$.content.title"))
+	assert.Equal(t, "3", b.JSON.Get("$.content.version"))
+	assert.Contains(t, b.JSON.TemplatedValues, "$.content.uuid")
+	assert.Contains(t, b.Form.TemplatedValues, "name")
+	assert.Equal(t, "Example", b.Text.Contains)
+	require.NotNil(t, b.Text.Regex)
+	assert.True(t, b.Text.Regex.MatchString(jsonBodyTestDocument))
+}
+
+func TestBodyUnmarshal__UnknownTextMatcher(t *testing.T) {
+	b := Body{}
+	err := yaml.Unmarshal([]byte("text:\n  startsWith: hello"), &b)
+	assert.Error(t, err)
+}
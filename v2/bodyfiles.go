@@ -0,0 +1,128 @@
+package v2
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+var ErrMultipleBodies = errors.New("only one of body, bodyFile and base64Body can be used in a response")
+
+// contentTypes are used for body files whose extensions are missing from older mime tables
+var contentTypes = map[string]string{
+	".json": "application/json",
+	".yml":  "application/x-yaml",
+	".yaml": "application/x-yaml",
+}
+
+// decodeBase64Body checks the response has at most one body, and decodes the base64Body, which is sent as application/octet-stream unless it has a content-type
+func (res *Response) decodeBase64Body() error {
+	bodies := 0
+	for _, set := range []bool{res.Body != nil, res.BodyFile != "", res.Base64Body != ""} {
+		if set {
+			bodies++
+		}
+	}
+
+	if bodies > 1 {
+		return ErrMultipleBodies
+	}
+
+	if res.Base64Body == "" {
+		return nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(res.Base64Body))
+	if err != nil {
+		return fmt.Errorf("invalid base64Body: %v", err)
+	}
+
+	res.raw = raw
+	res.defaultContentType("application/octet-stream")
+	return nil
+}
+
+// defaultContentType sets the content-type header if the response does not have one, without modifying the original headers
+func (res *Response) defaultContentType(contentType string) {
+	if _, ok := res.Headers["content-type"]; ok {
+		return
+	}
+
+	headers := map[string]string{"content-type": contentType}
+	for k, v := range res.Headers {
+		headers[k] = v
+	}
+	res.Headers = headers
+}
+
+// LoadBodyFiles reads the bodyFile of every response, relative to the directory of the fixtures file, and returns every file it read. Responses without a content-type use the content-type of the file's extension.
+func (v *Fixtures) LoadBodyFiles(dir string) ([]string, error) {
+	files := make([]string, 0)
+	for _, path := range *v {
+		for method, resource := range path {
+			responses := []*Response{&resource.Response}
+			for i := range resource.Sequence.Responses {
+				responses = append(responses, &resource.Sequence.Responses[i])
+			}
+
+			for i := range resource.Discriminators {
+				d := &resource.Discriminators[i]
+				responses = append(responses, &d.Response)
+				for j := range d.Sequence.Responses {
+					responses = append(responses, &d.Sequence.Responses[j])
+				}
+			}
+
+			for _, res := range responses {
+				f, err := res.loadBodyFile(dir)
+				if f != "" {
+					files = append(files, f)
+				}
+
+				if err != nil {
+					return files, err
+				}
+			}
+			path[method] = resource
+		}
+	}
+	return files, nil
+}
+
+func (res *Response) loadBodyFile(dir string) (string, error) {
+	if res.BodyFile == "" {
+		return "", nil
+	}
+
+	path := res.BodyFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return path, fmt.Errorf("failed to read bodyFile '%v': %v", path, err)
+	}
+
+	res.raw = raw
+	res.defaultContentType(contentTypeOf(path, raw))
+	return path, nil
+}
+
+// contentTypeOf uses the file's extension, or its content if the extension is unknown
+func contentTypeOf(path string, raw []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if t, ok := contentTypes[ext]; ok {
+		return t
+	}
+
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return http.DetectContentType(raw)
+}
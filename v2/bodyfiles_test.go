@@ -0,0 +1,131 @@
+package v2
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const bodyFilesTestYAML = `
+/images/{id}:
+  get:
+    - when:
+        pathParams:
+          id: logo
+      response:
+        status: 200
+        bodyFile: images/logo.jpg
+    - when:
+        pathParams:
+          id: pixel
+      response:
+        status: 200
+        base64Body: R0lGODlhAQABAAAAACw=
+    - when:
+        pathParams:
+          id: unknown
+      sequence:
+        - status: 200
+          headers:
+            content-type: text/html
+          bodyFile: unknown.html
+/content/{uuid}:
+  get:
+    status: 200
+    bodyFile: content.json
+`
+
+func writeBodyFiles(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "ersatz")
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "images"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "images", "logo.jpg"), []byte{0xff, 0xd8, 0xff, 0xe0}, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unknown.html"), []byte("<html>Unknown</html>"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "content.json"), []byte(`{"title": "Example Title"}`), 0644))
+	return dir
+}
+
+func TestLoadBodyFiles(t *testing.T) {
+	dir := writeBodyFiles(t)
+	defer os.RemoveAll(dir)
+
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(bodyFilesTestYAML), &f))
+
+	files, err := f.LoadBodyFiles(dir)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "images", "logo.jpg"), filepath.Join(dir, "unknown.html"), filepath.Join(dir, "content.json")}, files)
+
+	router := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	MockPaths(router, &f, Options{})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler := router.handlers["GET /images/:id"]
+		if path == "/content/1234" {
+			handler = router.handlers["GET /content/:uuid"]
+		}
+		handler(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/images/logo")
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+	assert.Equal(t, []byte{0xff, 0xd8, 0xff, 0xe0}, w.Body.Bytes())
+
+	w = get("/images/pixel")
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "GIF89a\x01\x00\x01\x00\x00\x00\x00,", w.Body.String())
+
+	w = get("/images/unknown")
+	assert.Equal(t, "text/html", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<html>Unknown</html>", w.Body.String())
+
+	w = get("/content/1234")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{"title": "Example Title"}`, w.Body.String())
+}
+
+func TestLoadBodyFiles__Missing(t *testing.T) {
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte("/content:\n  get:\n    bodyFile: missing.json\n"), &f))
+
+	files, err := f.LoadBodyFiles("/tmp/ersatz-missing")
+	assert.Contains(t, err.Error(), "failed to read bodyFile '/tmp/ersatz-missing/missing.json'")
+	assert.Equal(t, []string{"/tmp/ersatz-missing/missing.json"}, files)
+}
+
+func TestResponseUnmarshal__Bodies(t *testing.T) {
+	for _, doc := range []string{`{"body":"example","bodyFile":"example.txt"}`, `{"bodyFile":"example.txt","base64Body":"ZXhhbXBsZQ=="}`} {
+		res := Response{}
+		assert.Equal(t, ErrMultipleBodies, json.Unmarshal([]byte(doc), &res))
+	}
+
+	res := Response{}
+	err := json.Unmarshal([]byte(`{"base64Body":"not base64!"}`), &res)
+	assert.Contains(t, err.Error(), "invalid base64Body")
+
+	res = Response{}
+	require.NoError(t, json.Unmarshal([]byte(`{"headers":{"content-type":"text/plain"},"base64Body":"ZXhhbXBsZQ=="}`), &res))
+	assert.Equal(t, "text/plain", res.Headers["content-type"])
+
+	output, err := marshalBody(res)
+	require.NoError(t, err)
+	assert.Equal(t, "example", string(output))
+}
+
+func TestContentTypeOf(t *testing.T) {
+	assert.Equal(t, "application/json", contentTypeOf("content.json", nil))
+	assert.Equal(t, "application/x-yaml", contentTypeOf("content.YML", nil))
+	assert.Equal(t, "application/pdf", contentTypeOf("document.pdf", nil))
+	assert.Equal(t, "image/png", contentTypeOf("image", []byte("\x89PNG\x0D\x0A\x1A\x0A")))
+}
@@ -0,0 +1,89 @@
+package v2
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ClientCert discriminates requests on the verified client certificate they were sent with, when ersatz is serving mutual TLS
+type ClientCert QueryParams
+
+// clientCertFields are the fields of the client certificate which can be discriminated on
+var clientCertFields = []string{"commonName", "issuer", "organization", "organizationalUnit", "subject"}
+
+// UnmarshalJSON creates the expected client certificate fields in the same way as query parameters, but only accepts known fields
+func (c *ClientCert) UnmarshalJSON(d []byte) error {
+	if err := (*QueryParams)(c).UnmarshalJSON(d); err != nil {
+		return err
+	}
+
+	names := make([]string, 0)
+	for k := range c.Values {
+		names = append(names, k)
+	}
+	for k := range c.TemplatedValues {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		if !Contains(k, clientCertFields...) {
+			return fmt.Errorf("unknown clientCert field '%v', expected one of %v", k, strings.Join(clientCertFields, ", "))
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true if no client certificate fields have been configured
+func (c ClientCert) IsEmpty() bool {
+	return len(c.Values) == 0 && len(c.TemplatedValues) == 0
+}
+
+// Diagnose returns the expected client certificate fields which do not match the certificate the request was sent with
+func (c ClientCert) Diagnose(req *http.Request) []Mismatch {
+	return diagnoseValues("clientCert", c.Values, c.TemplatedValues, c.Expressions, ClientCertFromRequest(req).Get)
+}
+
+// ClientCertFromRequest returns the fields of the verified client certificate the request was sent with, which are all empty if there was none
+func ClientCertFromRequest(req *http.Request) url.Values {
+	v := url.Values{}
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return v
+	}
+
+	cert := req.TLS.PeerCertificates[0]
+	v.Set("commonName", cert.Subject.CommonName)
+	v.Set("organization", strings.Join(cert.Subject.Organization, ","))
+	v.Set("organizationalUnit", strings.Join(cert.Subject.OrganizationalUnit, ","))
+	v.Set("subject", DistinguishedName(cert.Subject))
+	v.Set("issuer", DistinguishedName(cert.Issuer))
+	return v
+}
+
+// DistinguishedName formats the name in the same order as RFC 2253, i.e. CN=ersatz,OU=Content,O=Example,C=GB
+func DistinguishedName(n pkix.Name) string {
+	parts := make([]string, 0)
+	add := func(attr string, values ...string) {
+		for _, v := range values {
+			parts = append(parts, attr+"="+escapeDN(v))
+		}
+	}
+
+	if n.CommonName != "" {
+		add("CN", n.CommonName)
+	}
+	add("OU", n.OrganizationalUnit...)
+	add("O", n.Organization...)
+	add("L", n.Locality...)
+	add("ST", n.Province...)
+	add("C", n.Country...)
+	return strings.Join(parts, ",")
+}
+
+func escapeDN(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`, `+`, `\+`, `"`, `\"`, `<`, `\<`, `>`, `\>`, `;`, `\;`).Replace(v)
+}
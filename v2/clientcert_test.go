@@ -0,0 +1,70 @@
+package v2
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withClientCert(subject pkix.Name) *tls.ConnectionState {
+	return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{
+		Subject: subject,
+		Issuer:  pkix.Name{CommonName: "ersatz CA", Organization: []string{"ersatz"}},
+	}}}
+}
+
+func TestClientCertFromRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "https://localhost/content", nil)
+	req.TLS = withClientCert(pkix.Name{CommonName: "content-api", Organization: []string{"Example, Ltd"}, OrganizationalUnit: []string{"Content"}, Country: []string{"GB"}})
+
+	v := ClientCertFromRequest(req)
+	assert.Equal(t, "content-api", v.Get("commonName"))
+	assert.Equal(t, "Example, Ltd", v.Get("organization"))
+	assert.Equal(t, "Content", v.Get("organizationalUnit"))
+	assert.Equal(t, `CN=content-api,OU=Content,O=Example\, Ltd,C=GB`, v.Get("subject"))
+	assert.Equal(t, "CN=ersatz CA,O=ersatz", v.Get("issuer"))
+
+	assert.Empty(t, ClientCertFromRequest(httptest.NewRequest("GET", "/content", nil)))
+}
+
+func TestClientCert__Diagnose(t *testing.T) {
+	c := ClientCert{}
+	require.NoError(t, json.Unmarshal([]byte(`{"commonName":"content-api","subject":"${contains:O=Example}"}`), &c))
+
+	req := httptest.NewRequest("GET", "https://localhost/content", nil)
+	req.TLS = withClientCert(pkix.Name{CommonName: "content-api", Organization: []string{"Example"}})
+	assert.Empty(t, c.Diagnose(req))
+
+	req.TLS = withClientCert(pkix.Name{CommonName: "people-api"})
+	assert.Equal(t, []Mismatch{
+		{Location: "clientCert", Name: "commonName", Expected: "content-api", Actual: "people-api"},
+		{Location: "clientCert", Name: "subject", Expected: "${contains:O=Example}", Actual: "CN=people-api"},
+	}, c.Diagnose(req))
+
+	req = httptest.NewRequest("GET", "/content", nil)
+	assert.Len(t, c.Diagnose(req), 2, "requests without a client certificate never match")
+}
+
+func TestClientCert__UnknownField(t *testing.T) {
+	c := ClientCert{}
+	err := json.Unmarshal([]byte(`{"commonName":"content-api","serial":"1234"}`), &c)
+	assert.EqualError(t, err, "unknown clientCert field 'serial', expected one of commonName, issuer, organization, organizationalUnit, subject")
+}
+
+func TestDiscriminator__ClientCert(t *testing.T) {
+	d := Discriminator{}
+	require.NoError(t, json.Unmarshal([]byte(`{"when":{"clientCert":{"commonName":"content-api"}},"response":{"status":200}}`), &d))
+
+	req := httptest.NewRequest("GET", "https://localhost/content", nil)
+	req.TLS = withClientCert(pkix.Name{CommonName: "content-api"})
+	assert.True(t, d.When.SatisfiesDiscriminator(req))
+
+	req.TLS = withClientCert(pkix.Name{CommonName: "people-api"})
+	assert.False(t, d.When.SatisfiesDiscriminator(req))
+}
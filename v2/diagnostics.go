@@ -0,0 +1,136 @@
+package v2
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxActualLength limits how much of a request value is included in a Mismatch
+const maxActualLength = 256
+
+// Mismatch describes a single value in the request which did not satisfy a discriminator
+type Mismatch struct {
+	Location string `json:"location"`
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// Candidate lists the mismatches between the request and a single discriminator
+type Candidate struct {
+	Discriminator int        `json:"discriminator"`
+	Mismatches    []Mismatch `json:"mismatches"`
+}
+
+// Report explains why a request did not satisfy any of the discriminators configured for a path and method
+type Report struct {
+	Message    string      `json:"message"`
+	Closest    int         `json:"closest"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Diagnose explains why the request did not satisfy each of the discriminators, including any whose scenario was not in the required state. The closest candidate is the discriminator with the fewest mismatches.
+func (arr Discriminators) Diagnose(req *http.Request, state *State) Report {
+	report := Report{
+		Message:    "The request did not match any of the discriminators configured for this path and method",
+		Candidates: make([]Candidate, 0, len(arr)),
+	}
+
+	for i, d := range arr {
+		c := Candidate{Discriminator: i, Mismatches: d.When.Diagnose(req)}
+		if state != nil && d.RequiredState != "" {
+			if current := state.Scenario(d.ScenarioName()); current != d.RequiredState {
+				c.Mismatches = append(c.Mismatches, Mismatch{Location: "scenario", Name: d.ScenarioName(), Expected: d.RequiredState, Actual: current})
+			}
+		}
+
+		if i == 0 || len(c.Mismatches) < len(report.Candidates[report.Closest].Mismatches) {
+			report.Closest = i
+		}
+		report.Candidates = append(report.Candidates, c)
+	}
+	return report
+}
+
+// diagnoseValues compares each expected value (and templated value) to the value returned by actual
+func diagnoseValues(location string, expected map[string][]string, templated TemplatedValues, expressions Expressions, actual func(string) string) []Mismatch {
+	mismatches := make([]Mismatch, 0)
+	for k, template := range templated {
+		v := actual(k)
+		if !template(v) {
+			mismatches = append(mismatches, Mismatch{Location: location, Name: k, Expected: expressions.Describe(k), Actual: truncate(v)})
+		}
+	}
+
+	for name, values := range expected {
+		if len(values) == 0 {
+			continue
+		}
+
+		v := actual(name)
+		if !Contains(values[0], v) {
+			mismatches = append(mismatches, Mismatch{Location: location, Name: name, Expected: values[0], Actual: truncate(v)})
+		}
+	}
+
+	sortMismatches(mismatches)
+	return mismatches
+}
+
+func sortMismatches(mismatches []Mismatch) {
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Location != mismatches[j].Location {
+			return mismatches[i].Location < mismatches[j].Location
+		}
+		return mismatches[i].Name < mismatches[j].Name
+	})
+}
+
+func truncate(v string) string {
+	if len(v) <= maxActualLength {
+		return v
+	}
+	return v[:maxActualLength] + "..."
+}
+
+// Closest returns the fixture path which most closely resembles the request path, or false if no fixture path shares any segments with it
+func (v Fixtures) Closest(path string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	closest, best := "", 0
+	for p := range v {
+		score := ParseRoute(p).similarity(parts)
+		if score > best || (score == best && score > 0 && p < closest) {
+			closest, best = p, score
+		}
+	}
+	return closest, best > 0
+}
+
+// similarity weights the path segments which match the route against the difference in the number of segments
+func (r Route) similarity(parts []string) int {
+	score := 0
+	for i, s := range r.segments {
+		if s.wildcard {
+			if score == i {
+				score++
+			}
+			return 2 * score
+		}
+
+		if i >= len(parts) {
+			break
+		}
+
+		if s.param != "" || s.literal == parts[i] {
+			score++
+		}
+	}
+
+	diff := len(parts) - len(r.segments)
+	if diff < 0 {
+		diff = -diff
+	}
+	return 2*score - diff
+}
@@ -0,0 +1,128 @@
+package v2
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diagnosticsTestYAML = `
+- when:
+    headers:
+      X-User: the-first-user
+      X-Request-Id: ${prefix:tid_}
+    queryParams:
+      id: the-first-id
+  response:
+    status: 200
+- when:
+    headers:
+      X-User: the-second-user
+    body:
+      json:
+        $.title: ${exists}
+  response:
+    status: 200
+`
+
+func TestDiscriminatorsDiagnose(t *testing.T) {
+	res := Resource{}
+	require.NoError(t, yaml.Unmarshal([]byte(diagnosticsTestYAML), &res))
+
+	r := httptest.NewRequest("POST", "/id?id=the-wrong-id", strings.NewReader(`{"title":"Example"}`))
+	r.Header.Set("X-User", "the-third-user")
+	r.Header.Set("X-Request-Id", "1234")
+
+	report := res.Discriminators.Diagnose(r, nil)
+	require.Len(t, report.Candidates, 2)
+	assert.Equal(t, 1, report.Closest)
+
+	assert.Equal(t, []Mismatch{
+		{Location: "headers", Name: "X-Request-Id", Expected: "${prefix:tid_}", Actual: "1234"},
+		{Location: "headers", Name: "X-User", Expected: "the-first-user", Actual: "the-third-user"},
+		{Location: "queryParams", Name: "id", Expected: "the-first-id", Actual: "the-wrong-id"},
+	}, report.Candidates[0].Mismatches)
+
+	assert.Equal(t, []Mismatch{
+		{Location: "headers", Name: "X-User", Expected: "the-second-user", Actual: "the-third-user"},
+	}, report.Candidates[1].Mismatches)
+}
+
+func TestDiscriminatorDiagnose__Body(t *testing.T) {
+	d := RequestDiscriminator{}
+	require.NoError(t, yaml.Unmarshal([]byte(`
+body:
+  json:
+    $.title: ${missing}
+  form:
+    name: ersatz
+  text:
+    contains: goodbye
+`), &d))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"title":"Example"}`))
+
+	assert.Equal(t, []Mismatch{
+		{Location: "body.json", Name: "$.title", Expected: "${missing}", Actual: "Example"},
+		{Location: "body.form", Name: "name", Expected: "ersatz", Actual: ""},
+		{Location: "body.text", Name: "contains", Expected: "goodbye", Actual: `{"title":"Example"}`},
+	}, d.Diagnose(r))
+}
+
+func TestDiscriminatorDiagnose__Satisfied(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Headers.Add("x-testing", "value")
+	d.Headers.TemplatedValues["X-Other"] = Missing
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("x-testing", "value")
+
+	assert.Empty(t, d.Diagnose(r))
+}
+
+func TestDiscriminatorDiagnose__UnknownExpression(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Headers.TemplatedValues["X-Testing"] = Exists
+
+	mismatches := d.Diagnose(httptest.NewRequest("GET", "/", nil))
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, "templated value", mismatches[0].Expected)
+}
+
+func TestDiagnose__TruncatesActual(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Body.Text.Equals = "short"
+
+	mismatches := d.Diagnose(httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 1000))))
+	require.Len(t, mismatches, 1)
+	assert.Len(t, mismatches[0].Actual, maxActualLength+3)
+}
+
+func TestFixturesClosest(t *testing.T) {
+	f := Fixtures{
+		"/__health":                   Path{},
+		"/content/{uuid}":             Path{},
+		"/content/{uuid}/annotations": Path{},
+		"/static/*":                   Path{},
+	}
+
+	tests := map[string]string{
+		"/content/1234/annotation":  "/content/{uuid}/annotations",
+		"/content/1234/annotations": "/content/{uuid}/annotations",
+		"/content":                  "/content/{uuid}",
+		"/static/css/main.css":      "/static/*",
+	}
+
+	for path, expected := range tests {
+		closest, ok := f.Closest(path)
+		assert.True(t, ok, path)
+		assert.Equal(t, expected, closest, path)
+	}
+
+	_, ok := f.Closest("/__gtg")
+	assert.False(t, ok)
+}
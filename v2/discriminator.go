@@ -16,6 +16,29 @@ func NewRequestDiscriminator() RequestDiscriminator {
 			Values:          make(url.Values),
 			TemplatedValues: make(TemplatedValues),
 		},
+		PathParams: PathParams{
+			Values:          make(url.Values),
+			TemplatedValues: make(TemplatedValues),
+		},
+		Body: Body{
+			JSON: JSONFields{
+				Values:          make(url.Values),
+				TemplatedValues: make(TemplatedValues),
+			},
+			Form: FormFields{
+				Values:          make(url.Values),
+				TemplatedValues: make(TemplatedValues),
+			},
+			Text: Text{TemplatedValues: make(TemplatedValues)},
+		},
+		ClientCert: ClientCert{
+			Values:          make(url.Values),
+			TemplatedValues: make(TemplatedValues),
+		},
+		Protocol: Protocol{
+			Values:          make(url.Values),
+			TemplatedValues: make(TemplatedValues),
+		},
 	}
 }
 
@@ -29,48 +52,47 @@ func (arr Discriminators) AtLeastOneDiscriminatorIsSatisfied(req *http.Request)
 }
 
 func (r RequestDiscriminator) SatisfiesDiscriminator(req *http.Request) bool {
-	return r.Headers.Validate(req.Header) && r.QueryParams.Validate(req.URL.Query())
+	return len(r.Diagnose(req)) == 0
+}
+
+// Diagnose returns every value in the request which does not satisfy the discriminator
+func (r RequestDiscriminator) Diagnose(req *http.Request) []Mismatch {
+	mismatches := r.Headers.Diagnose(req.Header)
+	mismatches = append(mismatches, r.QueryParams.Diagnose(req.URL.Query())...)
+	mismatches = append(mismatches, r.PathParams.Diagnose(PathParamsFromRequest(req))...)
+	mismatches = append(mismatches, r.Body.Diagnose(req)...)
+	mismatches = append(mismatches, r.ClientCert.Diagnose(req)...)
+	mismatches = append(mismatches, r.Protocol.Diagnose(req)...)
+	return mismatches
 }
 
 func (q QueryParams) Validate(actual url.Values) bool {
-	for k, template := range q.TemplatedValues {
-		v := actual.Get(k)
-		if !template(v) {
-			return false
-		}
-	}
+	return len(q.Diagnose(actual)) == 0
+}
 
-	for name := range q.Values {
-		expected := q.Get(name)
-		value := actual.Get(name)
+// Diagnose returns the expected query params which do not match the actual params
+func (q QueryParams) Diagnose(actual url.Values) []Mismatch {
+	return diagnoseValues("queryParams", q.Values, q.TemplatedValues, q.Expressions, actual.Get)
+}
 
-		ok := Contains(expected, value)
-		if !ok {
-			return false
-		}
-	}
-	return true
+// Validate validates the expected path parameters against those captured from the request path
+func (p PathParams) Validate(actual url.Values) bool {
+	return len(p.Diagnose(actual)) == 0
+}
+
+// Diagnose returns the expected path parameters which do not match those captured from the request path
+func (p PathParams) Diagnose(actual url.Values) []Mismatch {
+	return diagnoseValues("pathParams", p.Values, p.TemplatedValues, p.Expressions, actual.Get)
 }
 
 // Validate validates the expected headers against the received headers
 func (h Headers) Validate(actual http.Header) bool {
-	for k, template := range h.TemplatedValues {
-		v := actual.Get(k)
-		if !template(v) {
-			return false
-		}
-	}
-
-	for name := range h.MIMEHeader {
-		expected := h.Get(name)
-		value := actual.Get(name)
+	return len(h.Diagnose(actual)) == 0
+}
 
-		ok := Contains(expected, value)
-		if !ok {
-			return false
-		}
-	}
-	return true
+// Diagnose returns the expected headers which do not match the received headers
+func (h Headers) Diagnose(actual http.Header) []Mismatch {
+	return diagnoseValues("headers", h.MIMEHeader, h.TemplatedValues, h.Expressions, actual.Get)
 }
 
 // Contains compares the expected values to the actual
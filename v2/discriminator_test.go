@@ -1,7 +1,9 @@
 package v2
 
 import (
+	"context"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -130,3 +132,36 @@ func TestDiscriminator__QueryParams__EqualValueFails(t *testing.T) {
 	ok := d.SatisfiesDiscriminator(r)
 	assert.False(t, ok)
 }
+
+func TestDiscriminator__PathParams__EqualValue(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.PathParams.Add("uuid", "1234")
+
+	r := httptest.NewRequest("GET", "/content/1234", nil)
+	r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, url.Values{"uuid": {"1234"}}))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.True(t, ok)
+}
+
+func TestDiscriminator__PathParams__EqualValueFails(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.PathParams.Add("uuid", "1234")
+
+	r := httptest.NewRequest("GET", "/content/5678", nil)
+	r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, url.Values{"uuid": {"5678"}}))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.False(t, ok)
+}
+
+func TestDiscriminator__PathParams__TemplatedMissingFails(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.PathParams.TemplatedValues["uuid"] = Missing
+
+	r := httptest.NewRequest("GET", "/content/1234", nil)
+	r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, url.Values{"uuid": {"1234"}}))
+
+	ok := d.SatisfiesDiscriminator(r)
+	assert.False(t, ok)
+}
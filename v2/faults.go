@@ -0,0 +1,353 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// EmptyResponse closes the connection without sending a response
+	EmptyResponse = "emptyResponse"
+	// ConnectionReset resets the TCP connection without sending a response
+	ConnectionReset = "connectionReset"
+	// TruncatedBody sends the headers and the first half of the body, then closes the connection
+	TruncatedBody = "truncatedBody"
+	// SlowBody sends the body in small chunks, waiting between each one
+	SlowBody = "slowBody"
+)
+
+// defaultSlowBodyInterval is how long a SlowBody fault waits between chunks if no interval is configured
+const defaultSlowBodyInterval = 100 * time.Millisecond
+
+// Duration is a time.Duration which is configured as a string (i.e. 500ms or 2s), or a number of milliseconds
+type Duration time.Duration
+
+// Delay is how long to wait before responding. Exactly one of Fixed, Uniform or LogNormal should be used.
+type Delay struct {
+	Fixed     Duration        `json:"fixed"`
+	Uniform   *UniformDelay   `json:"uniform"`
+	LogNormal *LogNormalDelay `json:"lognormal"`
+}
+
+// UniformDelay is a delay chosen at random between Min and Max
+type UniformDelay struct {
+	Min Duration `json:"min"`
+	Max Duration `json:"max"`
+}
+
+// LogNormalDelay is a delay with a log-normal distribution, which resembles real-world latency. Most delays are close to the Median, with a long tail controlled by Sigma. If Max is set, no delay will exceed it.
+type LogNormalDelay struct {
+	Median Duration `json:"median"`
+	Sigma  float64  `json:"sigma"`
+	Max    Duration `json:"max"`
+}
+
+// Fault simulates a broken dependency, by responding with one of EmptyResponse, ConnectionReset, TruncatedBody or SlowBody
+type Fault struct {
+	Type string `json:"type"`
+
+	// ChunkSize and Interval configure a SlowBody fault, which sends ChunkSize bytes every Interval
+	ChunkSize int      `json:"chunkSize"`
+	Interval  Duration `json:"interval"`
+}
+
+// delays are random for every run, as the global math/rand source is not seeded before Go 1.20
+var delays = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// FixedDelay creates a Delay which always waits for d
+func FixedDelay(d time.Duration) *Delay {
+	return &Delay{Fixed: Duration(d)}
+}
+
+// Duration picks how long to wait, using the configured distribution
+func (d *Delay) Duration() time.Duration {
+	switch {
+	case d == nil:
+		return 0
+	case d.Uniform != nil:
+		min, max := int64(d.Uniform.Min), int64(d.Uniform.Max)
+		delays.Lock()
+		defer delays.Unlock()
+		return time.Duration(min + delays.Int63n(max-min+1))
+	case d.LogNormal != nil:
+		delays.Lock()
+		norm := delays.NormFloat64()
+		delays.Unlock()
+
+		wait := time.Duration(float64(d.LogNormal.Median) * math.Exp(d.LogNormal.Sigma*norm))
+		if d.LogNormal.Max > 0 && wait > time.Duration(d.LogNormal.Max) {
+			return time.Duration(d.LogNormal.Max)
+		}
+		return wait
+	default:
+		return time.Duration(d.Fixed)
+	}
+}
+
+// Wait blocks for the delay, or until the request is cancelled
+func (d *Delay) Wait(ctx context.Context) {
+	wait := d.Duration()
+	if wait <= 0 {
+		return
+	}
+
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// UnmarshalJSON accepts either a Go duration string (i.e. 500ms) or a number of milliseconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err == nil {
+		*d = Duration(ms * float64(time.Millisecond))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf(`expected a duration, but was '%v'`, string(data))
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf(`expected a duration (i.e. 500ms), but was '%v'`, s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// UnmarshalJSON accepts either a fixed delay (i.e. delay: 500ms) or an object with one of fixed, uniform or lognormal
+func (d *Delay) UnmarshalJSON(data []byte) error {
+	var fixed Duration
+	if err := json.Unmarshal(data, &fixed); err == nil {
+		*d = Delay{Fixed: fixed}
+		return nil
+	}
+
+	type plain Delay
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+
+	configured := 0
+	if d.Fixed != 0 {
+		configured++
+	}
+
+	if d.Uniform != nil {
+		configured++
+		if d.Uniform.Min < 0 || d.Uniform.Max < d.Uniform.Min {
+			return fmt.Errorf(`expected uniform delay min '%v' to be less than max '%v'`, time.Duration(d.Uniform.Min), time.Duration(d.Uniform.Max))
+		}
+	}
+
+	if d.LogNormal != nil {
+		configured++
+		if d.LogNormal.Median <= 0 || d.LogNormal.Sigma < 0 {
+			return fmt.Errorf(`expected lognormal delay to have a positive median and sigma`)
+		}
+	}
+
+	if configured > 1 {
+		return fmt.Errorf(`expected only one of fixed, uniform or lognormal delays, but found %v`, configured)
+	}
+	return nil
+}
+
+// UnmarshalJSON accepts either the fault type (i.e. fault: emptyResponse) or an object with the type and its options
+func (f *Fault) UnmarshalJSON(data []byte) error {
+	var faultType string
+	if err := json.Unmarshal(data, &faultType); err == nil {
+		*f = Fault{Type: faultType}
+	} else {
+		type plain Fault
+		if err := json.Unmarshal(data, (*plain)(f)); err != nil {
+			return err
+		}
+	}
+
+	switch f.Type {
+	case EmptyResponse, ConnectionReset, TruncatedBody, SlowBody:
+		return nil
+	}
+	return fmt.Errorf(`unsupported fault '%v', please use one of %v, %v, %v or %v`, f.Type, EmptyResponse, ConnectionReset, TruncatedBody, SlowBody)
+}
+
+// writeFault writes the headers and body of the response (if the fault requires them), then breaks the response as configured
+func writeFault(f *Fault, status int, body []byte, w http.ResponseWriter, r *http.Request) {
+	journal.SetFault(r, f.Type)
+
+	switch f.Type {
+	case EmptyResponse:
+		closeConnection(w, r, false)
+	case ConnectionReset:
+		closeConnection(w, r, true)
+	case TruncatedBody:
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(status)
+		w.Write(body[:len(body)/2])
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		closeConnection(w, r, false)
+	case SlowBody:
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(status)
+		writeSlowly(f, body, w, r)
+	}
+}
+
+// closeConnection hijacks the connection and closes it, discarding anything which has not been flushed. If reset is true, the TCP connection is reset rather than closed cleanly. Connections which cannot be hijacked (i.e. HTTP/2) abort the handler instead, which resets the stream.
+func closeConnection(w http.ResponseWriter, r *http.Request, reset bool) {
+	var conn net.Conn
+	err := errors.New("the response writer does not support hijacking")
+	if hijacker, ok := w.(http.Hijacker); ok && r.ProtoMajor == 1 {
+		conn, _, err = hijacker.Hijack()
+	}
+
+	if err != nil {
+		log.WithError(err).WithField("protocol", r.Proto).Warn("Unable to close the connection, so the handler will be aborted instead")
+		journal.SetAborted(r)
+		panic(http.ErrAbortHandler)
+	}
+
+	if !reset {
+		conn.Close()
+		return
+	}
+
+	// closing a TLS connection would send a close_notify alert, so reset the TCP connection beneath it
+	if tracked := trackedConnection(r); tracked != nil {
+		tracked.reset()
+		return
+	}
+
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// connections are the connections accepted by every listener from TrackConnections, by their local and remote addresses
+var connections = struct {
+	sync.Mutex
+	open map[string]*trackedConn
+}{open: make(map[string]*trackedConn)}
+
+// TrackConnections wraps a listener, so ConnectionReset faults can reset the TCP connections it accepts even when they are wrapped in TLS. TLS connections from other listeners are closed instead.
+func TrackConnections(l net.Listener) net.Listener {
+	return &trackingListener{l}
+}
+
+type trackingListener struct {
+	net.Listener
+}
+
+func (l *trackingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	tracked := &trackedConn{Conn: conn, key: connectionKey(conn.LocalAddr().String(), conn.RemoteAddr().String())}
+	connections.Lock()
+	connections.open[tracked.key] = tracked
+	connections.Unlock()
+	return tracked, nil
+}
+
+type trackedConn struct {
+	net.Conn
+	key  string
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.once.Do(func() {
+		connections.Lock()
+		delete(connections.open, c.key)
+		connections.Unlock()
+	})
+	return c.Conn.Close()
+}
+
+func (c *trackedConn) reset() {
+	if tcp, ok := c.Conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	c.Close()
+}
+
+// trackedConnection finds the connection the request was received on, or nil if its listener was not wrapped with TrackConnections
+func trackedConnection(r *http.Request) *trackedConn {
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return nil
+	}
+
+	connections.Lock()
+	defer connections.Unlock()
+	return connections.open[connectionKey(local.String(), r.RemoteAddr)]
+}
+
+func connectionKey(local string, remote string) string {
+	return local + " " + remote
+}
+
+func writeSlowly(f *Fault, body []byte, w http.ResponseWriter, r *http.Request) {
+	chunkSize := f.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1
+	}
+
+	interval := time.Duration(f.Interval)
+	if interval <= 0 {
+		interval = defaultSlowBodyInterval
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for len(body) > 0 {
+		n := chunkSize
+		if n > len(body) {
+			n = len(body)
+		}
+
+		if _, err := w.Write(body[:n]); err != nil {
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		body = body[n:]
+		if len(body) == 0 {
+			return
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package v2
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestDelayUnmarshal(t *testing.T) {
+	tests := []struct {
+		yml      string
+		expected Delay
+	}{
+		{yml: `500ms`, expected: Delay{Fixed: Duration(500 * time.Millisecond)}},
+		{yml: `250`, expected: Delay{Fixed: Duration(250 * time.Millisecond)}},
+		{yml: `{fixed: 2s}`, expected: Delay{Fixed: Duration(2 * time.Second)}},
+		{yml: `{uniform: {min: 100ms, max: 300ms}}`, expected: Delay{Uniform: &UniformDelay{Min: Duration(100 * time.Millisecond), Max: Duration(300 * time.Millisecond)}}},
+		{yml: `{lognormal: {median: 80ms, sigma: 0.4, max: 1s}}`, expected: Delay{LogNormal: &LogNormalDelay{Median: Duration(80 * time.Millisecond), Sigma: 0.4, Max: Duration(time.Second)}}},
+	}
+
+	for _, test := range tests {
+		d := Delay{}
+		require.NoError(t, yaml.Unmarshal([]byte(test.yml), &d), test.yml)
+		assert.Equal(t, test.expected, d, test.yml)
+	}
+}
+
+func TestDelayUnmarshal__Invalid(t *testing.T) {
+	invalid := []string{
+		`soon`,
+		`{uniform: {min: 300ms, max: 100ms}}`,
+		`{lognormal: {sigma: 0.5}}`,
+		`{fixed: 1s, uniform: {min: 100ms, max: 300ms}}`,
+	}
+
+	for _, yml := range invalid {
+		d := Delay{}
+		assert.Error(t, yaml.Unmarshal([]byte(yml), &d), yml)
+	}
+}
+
+func TestDelayDuration(t *testing.T) {
+	var nilDelay *Delay
+	assert.Equal(t, time.Duration(0), nilDelay.Duration())
+	assert.Equal(t, time.Second, FixedDelay(time.Second).Duration())
+
+	uniform := &Delay{Uniform: &UniformDelay{Min: Duration(100 * time.Millisecond), Max: Duration(300 * time.Millisecond)}}
+	lognormal := &Delay{LogNormal: &LogNormalDelay{Median: Duration(100 * time.Millisecond), Sigma: 2, Max: Duration(time.Second)}}
+	for i := 0; i < 100; i++ {
+		d := uniform.Duration()
+		assert.True(t, d >= 100*time.Millisecond && d <= 300*time.Millisecond, d.String())
+
+		d = lognormal.Duration()
+		assert.True(t, d > 0 && d <= time.Second, d.String())
+	}
+}
+
+func TestDelayDuration__Seeded(t *testing.T) {
+	max := time.Hour
+	uniform := &Delay{Uniform: &UniformDelay{Max: Duration(max)}}
+	unseeded := rand.New(rand.NewSource(1))
+	assert.NotEqual(t, time.Duration(unseeded.Int63n(int64(max)+1)), uniform.Duration(), "delays should differ between runs")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			uniform.Duration()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestFaultUnmarshal(t *testing.T) {
+	f := Fault{}
+	require.NoError(t, yaml.Unmarshal([]byte(`connectionReset`), &f))
+	assert.Equal(t, Fault{Type: ConnectionReset}, f)
+
+	f = Fault{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{type: slowBody, chunkSize: 4, interval: 10ms}`), &f))
+	assert.Equal(t, Fault{Type: SlowBody, ChunkSize: 4, Interval: Duration(10 * time.Millisecond)}, f)
+
+	f = Fault{}
+	assert.EqualError(t, yaml.Unmarshal([]byte(`explode`), &f), "error unmarshaling JSON: while decoding JSON: unsupported fault 'explode', please use one of emptyResponse, connectionReset, truncatedBody or slowBody")
+}
+
+func faultServer(res Response) (*httptest.Server, *journal.Journal) {
+	j := journal.New(0)
+	server := httptest.NewServer(j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMockResponse(res, w, r)
+	})))
+	return server, j
+}
+
+func TestWriteMockResponse__WithDelay(t *testing.T) {
+	server, _ := faultServer(Response{Status: 200, Delay: FixedDelay(50 * time.Millisecond)})
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+}
+
+func TestWriteMockResponse__WithEmptyResponse(t *testing.T) {
+	for _, fault := range []string{EmptyResponse, ConnectionReset} {
+		server, j := faultServer(Response{Status: 200, Body: map[string]interface{}{"ok": true}, Fault: &Fault{Type: fault}})
+		defer server.Close()
+
+		_, err := http.Get(server.URL)
+		assert.Error(t, err, fault)
+
+		entries := j.Entries(journal.Filter{})
+		require.Len(t, entries, 1)
+		assert.Equal(t, fault, entries[0].Fault)
+	}
+}
+
+func TestWriteMockResponse__WithTruncatedBody(t *testing.T) {
+	server, _ := faultServer(Response{Status: 200, Body: "the full response body", Headers: map[string]string{"content-type": "text/plain"}, Fault: &Fault{Type: TruncatedBody}})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Error(t, err)
+	assert.Equal(t, "the full re", string(body))
+}
+
+func TestWriteMockResponse__WithConnectionResetOverTLS(t *testing.T) {
+	for fault, reset := range map[string]bool{EmptyResponse: false, ConnectionReset: true} {
+		j := journal.New(0)
+		server := httptest.NewUnstartedServer(j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeMockResponse(Response{Status: 200, Fault: &Fault{Type: fault}}, w, r)
+		})))
+		server.Listener = TrackConnections(server.Listener)
+		server.StartTLS()
+		defer server.Close()
+
+		_, err := server.Client().Get(server.URL)
+		require.Error(t, err, fault)
+		assert.Equal(t, reset, strings.Contains(err.Error(), "connection reset by peer"), err.Error())
+
+		entries := j.Entries(journal.Filter{})
+		require.Len(t, entries, 1)
+		assert.Equal(t, fault, entries[0].Fault)
+		assert.False(t, entries[0].Aborted)
+	}
+
+	connections.Lock()
+	defer connections.Unlock()
+	assert.Empty(t, connections.open, "closed connections should no longer be tracked")
+}
+
+func TestWriteMockResponse__WithConnectionResetOverUntrackedTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMockResponse(Response{Status: 200, Fault: &Fault{Type: ConnectionReset}}, w, r)
+	}))
+	defer server.Close()
+
+	_, err := server.Client().Get(server.URL)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "connection reset by peer", "the connection should be closed instead")
+}
+
+func TestWriteMockResponse__WithFaultsOverHTTP2(t *testing.T) {
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network string, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+
+	for _, fault := range []string{EmptyResponse, ConnectionReset, TruncatedBody} {
+		j := journal.New(0)
+		server := httptest.NewServer(h2c.NewHandler(j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeMockResponse(Response{Status: 200, Body: "the full response body", Headers: map[string]string{"content-type": "text/plain"}, Fault: &Fault{Type: fault}}, w, r)
+		})), &http2.Server{}))
+		defer server.Close()
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			// the headers of a truncated body are sent before the stream is reset
+			assert.Equal(t, TruncatedBody, fault)
+			_, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		require.Error(t, err, fault)
+		assert.Contains(t, err.Error(), "stream error", fault)
+
+		entries := j.Entries(journal.Filter{})
+		require.Len(t, entries, 1)
+		assert.Equal(t, fault, entries[0].Fault)
+		assert.True(t, entries[0].Aborted, fault)
+	}
+}
+
+func TestWriteMockResponse__WithSlowBody(t *testing.T) {
+	server, _ := faultServer(Response{Status: 200, Body: "slowly", Headers: map[string]string{"content-type": "text/plain"}, Fault: &Fault{Type: SlowBody, ChunkSize: 2, Interval: Duration(20 * time.Millisecond)}})
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "slowly", string(body))
+	assert.True(t, time.Since(start) >= 40*time.Millisecond)
+}
+
+func TestMockResource__WithDefaultDelay(t *testing.T) {
+	res := Resource{Response: Response{Status: 200}, options: Options{DefaultDelay: FixedDelay(time.Second)}}
+	assert.Equal(t, FixedDelay(time.Second), res.withDefaults(res.Response).Delay)
+
+	own := FixedDelay(time.Millisecond)
+	assert.Equal(t, own, res.withDefaults(Response{Delay: own}).Delay)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"text/template"
 
 	"github.com/husobee/vestigo"
 )
@@ -22,6 +23,26 @@ type Path map[string]Resource
 type Resource struct {
 	Discriminators Discriminators
 	Response       Response
+	Sequence       Sequence
+
+	id      string
+	state   *State
+	options Options
+}
+
+// Options configures behaviour shared by every fixture
+type Options struct {
+	// DefaultDelay is used for every response which does not configure its own delay
+	DefaultDelay *Delay
+
+	// Proxy forwards requests which do not match any discriminator to an upstream, rather than responding with a 501
+	Proxy *Proxy
+
+	// State is shared by the scenarios and sequences of fixtures mocked by separate calls to MockPaths, i.e. for each virtual host. A new State is created if none is provided.
+	State *State
+
+	// Host is the virtual host the fixtures are served on, so their sequences are counted separately from the same fixtures on other hosts
+	Host string
 }
 
 type Discriminators []Discriminator
@@ -29,41 +50,74 @@ type Discriminators []Discriminator
 type Discriminator struct {
 	When     RequestDiscriminator `json:"when"`
 	Response Response             `json:"response"`
+	Sequence
+
+	// Scenario, RequiredState and NewState allow a discriminator to only match while its scenario is in the RequiredState, and to move the scenario into the NewState when it does
+	Scenario      string `json:"scenario"`
+	RequiredState string `json:"requiredState"`
+	NewState      string `json:"newState"`
 }
 
 type RequestDiscriminator struct {
 	Headers     Headers     `json:"headers"`
 	QueryParams QueryParams `json:"queryParams"`
+	PathParams  PathParams  `json:"pathParams"`
+	Body        Body        `json:"body"`
+	ClientCert  ClientCert  `json:"clientCert"`
+	Protocol    Protocol    `json:"protocol"`
 }
 
 // Headers does what it says on the tin
 type Headers struct {
 	textproto.MIMEHeader
 	TemplatedValues TemplatedValues
+	Expressions     Expressions
 }
 
 // QueryParams does what it says on the tin
 type QueryParams struct {
 	url.Values
 	TemplatedValues TemplatedValues
+	Expressions     Expressions
 }
 
+// PathParams are the values captured by the :name or {name} segments (and trailing * wildcard) of the fixture path
+type PathParams QueryParams
+
 // TemplatedValues holds "special" values which can be used for fuzzy discriminators - i.e. ${exists} checks for the existence of the header
 type TemplatedValues map[string]TemplatedFunction
 
 type TemplatedFunction func(string) bool
 
+// Expressions holds the original ${...} syntax for each templated value, which is used when explaining why a request did not match
+type Expressions map[string]string
+
 // Response mocks a particular http method for a given path
 type Response struct {
 	Status  int               `json:"status"`
 	Headers map[string]string `json:"headers"`
 	Body    interface{}       `json:"body"`
+
+	// BodyFile (relative to the fixtures file) and Base64Body are sent as they are, rather than serialised using the content-type, so they can contain binary content
+	BodyFile   string `json:"bodyFile"`
+	Base64Body string `json:"base64Body"`
+
+	// Delay and Fault simulate a slow or broken dependency
+	Delay *Delay `json:"delay"`
+	Fault *Fault `json:"fault"`
+
+	// Proxy forwards the request to an upstream, instead of responding with this response
+	Proxy *Proxy `json:"proxy"`
+
+	// Template renders the status, headers and every string in the body as Go templates, using data from the request
+	Template bool `json:"template"`
+
+	statusTemplate string
+	templates      map[string]*template.Template
+	raw            []byte
 }
 
 // Router allows us to test that paths are configured properly
 type Router interface {
-	Get(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
-	Put(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
-	Post(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
-	Delete(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
+	Add(method string, path string, handler http.HandlerFunc, middleware ...vestigo.Middleware)
 }
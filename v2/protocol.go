@@ -0,0 +1,39 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/peteclark-ft/ersatz/journal"
+)
+
+// Protocol discriminates requests on the protocol they were sent with: http/1.0, http/1.1, h2 (HTTP/2 over TLS) or h2c (HTTP/2 over cleartext)
+type Protocol QueryParams
+
+// UnmarshalJSON creates the expected protocol from a single value, which may be templated, i.e. ${oneOf:h2,h2c}
+func (p *Protocol) UnmarshalJSON(d []byte) error {
+	var protocol string
+	if err := json.Unmarshal(d, &protocol); err != nil {
+		return fmt.Errorf("expected protocol to be a string, but was '%v'", string(d))
+	}
+
+	values, err := json.Marshal(map[string]string{"protocol": protocol})
+	if err != nil {
+		return err
+	}
+	return (*QueryParams)(p).UnmarshalJSON(values)
+}
+
+// IsEmpty returns true if no protocol has been configured
+func (p Protocol) IsEmpty() bool {
+	return len(p.Values) == 0 && len(p.TemplatedValues) == 0
+}
+
+// Diagnose returns the expected protocol if the request was sent with a different one
+func (p Protocol) Diagnose(req *http.Request) []Mismatch {
+	protocol := journal.Protocol(req)
+	return diagnoseValues("protocol", p.Values, p.TemplatedValues, p.Expressions, func(string) string {
+		return protocol
+	})
+}
@@ -0,0 +1,37 @@
+package v2
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProtocol__Diagnose(t *testing.T) {
+	d := Discriminator{}
+	require.NoError(t, json.Unmarshal([]byte(`{"when":{"protocol":"h2c"},"response":{"status":200}}`), &d))
+
+	req := httptest.NewRequest("GET", "/content", nil)
+	assert.Equal(t, []Mismatch{{Location: "protocol", Name: "protocol", Expected: "h2c", Actual: "http/1.1"}}, d.When.Diagnose(req))
+
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	assert.True(t, d.When.SatisfiesDiscriminator(req))
+}
+
+func TestProtocol__Templated(t *testing.T) {
+	p := Protocol{}
+	require.NoError(t, json.Unmarshal([]byte(`"${oneOf:h2,h2c}"`), &p))
+
+	req := httptest.NewRequest("GET", "https://localhost/content", nil)
+	assert.Len(t, p.Diagnose(req), 1)
+
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	assert.Empty(t, p.Diagnose(req))
+}
+
+func TestProtocol__Invalid(t *testing.T) {
+	p := Protocol{}
+	assert.EqualError(t, json.Unmarshal([]byte(`["h2"]`), &p), `expected protocol to be a string, but was '["h2"]'`)
+}
@@ -0,0 +1,152 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProxiedHeader is added to every proxied response, and contains the upstream the request was forwarded to
+const ProxiedHeader = "X-Ersatz-Proxied"
+
+// Proxy forwards requests to an upstream, i.e. a local stand-in for the real service
+type Proxy struct {
+	Target string `json:"target"`
+
+	// Headers are set on every proxied request, replacing any existing values
+	Headers map[string]string `json:"headers"`
+	// RemoveHeaders are removed from every proxied request
+	RemoveHeaders []string `json:"removeHeaders"`
+
+	handler *httputil.ReverseProxy
+}
+
+// UnmarshalJSON accepts either the upstream base URL (i.e. proxy: http://localhost:8080), or an object with the target and its header rewriting rules
+func (p *Proxy) UnmarshalJSON(data []byte) error {
+	var target string
+	if err := json.Unmarshal(data, &target); err == nil {
+		*p = Proxy{Target: target}
+	} else {
+		type plain Proxy
+		if err := json.Unmarshal(data, (*plain)(p)); err != nil {
+			return err
+		}
+	}
+
+	return p.init()
+}
+
+// NewProxy creates a Proxy which forwards requests to the target
+func NewProxy(target string) (*Proxy, error) {
+	p := &Proxy{Target: target}
+	return p, p.init()
+}
+
+func (p *Proxy) init() error {
+	u, err := url.Parse(p.Target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf(`expected proxy target to be an absolute URL, but was '%v'`, p.Target)
+	}
+
+	p.handler = p.newReverseProxy(u)
+	return nil
+}
+
+func (p *Proxy) newReverseProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = target.Host
+
+		for _, h := range p.RemoveHeaders {
+			r.Header.Del(h)
+		}
+
+		for k, v := range p.Headers {
+			r.Header.Set(k, v)
+		}
+	}
+
+	proxy.Transport = &upstreamTransport{target: p.Target, next: http.DefaultTransport}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		res.Header.Set(ProxiedHeader, p.Target)
+		return nil
+	}
+
+	return proxy
+}
+
+// upstreamTransport responds with a 502 Bad Gateway if the upstream cannot be reached, rather than failing the round trip, so the response is still marked as proxied (ReverseProxy.ErrorHandler needs Go 1.11)
+type upstreamTransport struct {
+	target string
+	next   http.RoundTripper
+}
+
+func (t *upstreamTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(r)
+	if err == nil {
+		return res, nil
+	}
+
+	log.WithError(err).WithField("target", t.target).Error("Failed to proxy request")
+	body := fmt.Sprintf("Failed to proxy request to '%v': %v\n", t.target, err)
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", http.StatusBadGateway, http.StatusText(http.StatusBadGateway)),
+		StatusCode: http.StatusBadGateway,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":           []string{"text/plain; charset=utf-8"},
+			"X-Content-Type-Options": []string{"nosniff"},
+		},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
+
+// ServeHTTP forwards the request to the upstream
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	journal.SetProxied(r, p.Target)
+	log.WithField("method", r.Method).WithField("path", r.URL.Path).WithField("target", p.Target).Info("Proxying request")
+	p.handler.ServeHTTP(w, r)
+}
+
+// Fallback forwards requests to the proxy if there is no fixture for their path and method, and otherwise uses the router
+func Fallback(router http.Handler, paths *Fixtures, proxy *Proxy) http.Handler {
+	if proxy == nil {
+		return router
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !paths.Matches(r.Method, r.URL.Path) {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+// Matches returns true if there is a fixture for the request method and path, or the router responds to the method automatically (i.e. HEAD and OPTIONS)
+func (v Fixtures) Matches(method string, path string) bool {
+	for p, resources := range v {
+		if _, ok := ParseRoute(p).Match(path); !ok {
+			continue
+		}
+
+		if Contains(strings.ToUpper(method), resources.Allowed()...) {
+			return true
+		}
+	}
+	return false
+}
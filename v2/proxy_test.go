@@ -0,0 +1,130 @@
+package v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func upstream() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream-Path", r.URL.Path)
+		w.Header().Set("X-Upstream-Auth", r.Header.Get("Authorization"))
+		w.Header().Set("X-Upstream-Debug", r.Header.Get("X-Debug"))
+		w.WriteHeader(http.StatusTeapot)
+	}))
+}
+
+func TestProxyUnmarshal(t *testing.T) {
+	p := Proxy{}
+	require.NoError(t, yaml.Unmarshal([]byte(`http://localhost:8080`), &p))
+	assert.Equal(t, "http://localhost:8080", p.Target)
+
+	p = Proxy{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{target: http://localhost:8080, headers: {Authorization: Bearer local}, removeHeaders: [X-Debug]}`), &p))
+	assert.Equal(t, "http://localhost:8080", p.Target)
+	assert.Equal(t, map[string]string{"Authorization": "Bearer local"}, p.Headers)
+	assert.Equal(t, []string{"X-Debug"}, p.RemoveHeaders)
+
+	p = Proxy{}
+	assert.Error(t, yaml.Unmarshal([]byte(`localhost:8080/content`), &p))
+}
+
+func TestWriteMockResponse__WithProxy(t *testing.T) {
+	server := upstream()
+	defer server.Close()
+
+	res := Response{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{proxy: {target: `+server.URL+`, headers: {Authorization: Bearer local}, removeHeaders: [X-Debug]}}`), &res))
+
+	j := journal.New(0)
+	r := httptest.NewRequest("GET", "/content/1234", nil)
+	r.Header.Set("Authorization", "Bearer real")
+	r.Header.Set("X-Debug", "true")
+	w := httptest.NewRecorder()
+	j.Record(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeMockResponse(res, w, r)
+	})).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, server.URL, w.Header().Get(ProxiedHeader))
+	assert.Equal(t, "/content/1234", w.Header().Get("X-Upstream-Path"))
+	assert.Equal(t, "Bearer local", w.Header().Get("X-Upstream-Auth"))
+	assert.Empty(t, w.Header().Get("X-Upstream-Debug"))
+
+	entries := j.Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, server.URL, entries[0].Proxied)
+}
+
+func TestProxy__UpstreamUnavailable(t *testing.T) {
+	p, err := NewProxy("http://127.0.0.1:1")
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, "http://127.0.0.1:1", w.Header().Get(ProxiedHeader))
+	assert.Contains(t, w.Body.String(), "Failed to proxy request to 'http://127.0.0.1:1'")
+}
+
+func TestMockResource__ProxiesUnmatchedDiscriminators(t *testing.T) {
+	server := upstream()
+	defer server.Close()
+	p, err := NewProxy(server.URL)
+	require.NoError(t, err)
+
+	res := Resource{Discriminators: Discriminators{{When: RequestDiscriminator{Headers: Headers{MIMEHeader: map[string][]string{"X-Broken": {"true"}}}}, Response: Response{Status: 500}}}, options: Options{Proxy: p}}
+
+	r := httptest.NewRequest("GET", "/content", nil)
+	r.Header.Set("X-Broken", "true")
+	w := httptest.NewRecorder()
+	mockResource(res)(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	mockResource(res)(w, httptest.NewRequest("GET", "/content", nil))
+	assert.Equal(t, http.StatusTeapot, w.Code)
+	assert.Equal(t, server.URL, w.Header().Get(ProxiedHeader))
+}
+
+func TestFallback(t *testing.T) {
+	server := upstream()
+	defer server.Close()
+	p, err := NewProxy(server.URL)
+	require.NoError(t, err)
+
+	f := Fixtures{"/content/{uuid}": Path{"get": Resource{}}}
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Fallback(router, &f, p)
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{method: "GET", path: "/content/1234", expected: http.StatusOK},
+		{method: "PUT", path: "/content/1234", expected: http.StatusTeapot},
+		{method: "HEAD", path: "/content/1234", expected: http.StatusOK},
+		{method: "OPTIONS", path: "/content/1234", expected: http.StatusOK},
+		{method: "GET", path: "/lists/1234", expected: http.StatusTeapot},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		assert.Equal(t, test.expected, w.Code, test.method+" "+test.path)
+	}
+
+	w := httptest.NewRecorder()
+	Fallback(router, &f, nil).ServeHTTP(w, httptest.NewRequest("GET", "/lists/1234", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
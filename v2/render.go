@@ -0,0 +1,323 @@
+package v2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the helpers available to response templates, in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"now":          now,
+	"uuid":         newUUID,
+	"randomInt":    randomInt,
+	"randomString": randomString,
+}
+
+// TemplateData is the data available to response templates, i.e. {{ .Request.PathParam "uuid" }}
+type TemplateData struct {
+	Request TemplateRequest
+}
+
+// TemplateRequest exposes the request being responded to
+type TemplateRequest struct {
+	Method string
+	Path   string
+	Body   string
+
+	req        *http.Request
+	pathParams map[string][]string
+	json       interface{}
+	jsonParsed bool
+}
+
+// PathParam returns the value captured by the :name, {name} or * segment of the fixture path
+func (t *TemplateRequest) PathParam(name string) string {
+	if values := t.pathParams[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Query returns the first value of the query parameter
+func (t *TemplateRequest) Query(name string) string {
+	return t.req.URL.Query().Get(name)
+}
+
+// Header returns the first value of the request header
+func (t *TemplateRequest) Header(name string) string {
+	return t.req.Header.Get(name)
+}
+
+// JSON returns the value found at the JSONPath (or dot-path) in the request body, or an empty string if the body is not JSON
+func (t *TemplateRequest) JSON(path string) string {
+	if !t.jsonParsed {
+		t.jsonParsed = true
+		dec := json.NewDecoder(strings.NewReader(t.Body))
+		dec.UseNumber()
+		if err := dec.Decode(&t.json); err != nil {
+			t.json = nil
+		}
+	}
+	return JSONPathValue(t.json, path)
+}
+
+func newTemplateData(r *http.Request) (*TemplateData, error) {
+	body, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateData{Request: TemplateRequest{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Body:       string(body),
+		req:        r,
+		pathParams: PathParamsFromRequest(r),
+	}}, nil
+}
+
+// parseTemplate parses a single response template, with the helpers available
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("response").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf(`invalid response template '%v': %v`, text, err)
+	}
+	return tmpl, nil
+}
+
+func executeTemplate(tmpl *template.Template, text string, data *TemplateData) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", fmt.Errorf(`failed to render response template '%v': %v`, text, err)
+	}
+	return buf.String(), nil
+}
+
+// parseTemplates parses every template in the response once, when the fixtures are loaded, so mistakes are found before any request is served
+func (res *Response) parseTemplates() error {
+	res.templates = make(map[string]*template.Template)
+	parse := func(text string) (string, error) {
+		if _, ok := res.templates[text]; ok {
+			return text, nil
+		}
+
+		tmpl, err := parseTemplate(text)
+		if err != nil {
+			return text, err
+		}
+		res.templates[text] = tmpl
+		return text, nil
+	}
+
+	if res.statusTemplate != "" {
+		if _, err := parse(res.statusTemplate); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range res.Headers {
+		if _, err := parse(v); err != nil {
+			return err
+		}
+	}
+	return walkStrings(res.Body, parse)
+}
+
+// renderer returns a function which renders a template of the response using the data. Templates are parsed when the fixtures are loaded, so they are only parsed here for responses which were built in Go.
+func (res Response) renderer(data *TemplateData) func(string) (string, error) {
+	return func(text string) (string, error) {
+		tmpl, ok := res.templates[text]
+		if !ok {
+			var err error
+			if tmpl, err = parseTemplate(text); err != nil {
+				return "", err
+			}
+		}
+		return executeTemplate(tmpl, text, data)
+	}
+}
+
+// CheckTemplates renders every template in the response using an empty GET request, to find templates which refer to fields or methods that do not exist
+func (res Response) CheckTemplates() error {
+	if !res.Template {
+		return nil
+	}
+
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		return err
+	}
+
+	data, err := newTemplateData(r)
+	if err != nil {
+		return err
+	}
+
+	render := res.renderer(data)
+
+	if res.statusTemplate != "" {
+		if _, err := render(res.statusTemplate); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range res.Headers {
+		if _, err := render(v); err != nil {
+			return err
+		}
+	}
+	return walkStrings(res.Body, render)
+}
+
+// Render returns a copy of the response with every template in its status, headers and body rendered using the request. Responses which are not templated are returned unchanged.
+func (res Response) Render(r *http.Request) (Response, error) {
+	if !res.Template {
+		return res, nil
+	}
+
+	data, err := newTemplateData(r)
+	if err != nil {
+		return res, err
+	}
+
+	render := res.renderer(data)
+
+	rendered := Response{Status: res.Status, BodyFile: res.BodyFile, Base64Body: res.Base64Body, Delay: res.Delay, Fault: res.Fault, Proxy: res.Proxy, Template: res.Template, raw: res.raw}
+	if res.statusTemplate != "" {
+		status, err := render(res.statusTemplate)
+		if err != nil {
+			return res, err
+		}
+
+		if rendered.Status, err = strconv.Atoi(strings.TrimSpace(status)); err != nil {
+			return res, fmt.Errorf(`expected response template '%v' to render a status code, but was '%v'`, res.statusTemplate, status)
+		}
+	}
+
+	if res.Headers != nil {
+		rendered.Headers = make(map[string]string)
+		for k, v := range res.Headers {
+			if rendered.Headers[k], err = render(v); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	rendered.Body = res.Body
+	if err := walkStrings(&rendered.Body, render); err != nil {
+		return res, err
+	}
+	return rendered, nil
+}
+
+// walkStrings calls fn for every string in the body. If v is a pointer, each string is replaced by the result of fn, without modifying the original body.
+func walkStrings(v interface{}, fn func(string) (string, error)) error {
+	if ptr, ok := v.(*interface{}); ok {
+		out, err := replaceStrings(*ptr, fn)
+		if err != nil {
+			return err
+		}
+		*ptr = out
+		return nil
+	}
+
+	_, err := replaceStrings(v, fn)
+	return err
+}
+
+func replaceStrings(v interface{}, fn func(string) (string, error)) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return fn(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{})
+		for k, child := range val {
+			replaced, err := replaceStrings(child, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = replaced
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, child := range val {
+			replaced, err := replaceStrings(child, fn)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = replaced
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+// now returns the current time in RFC3339 format, or the provided Go time layout
+func now(layout ...string) string {
+	if len(layout) > 0 {
+		return time.Now().UTC().Format(layout[0])
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// randomInt returns a random integer between min and max inclusive
+func randomInt(min int, max int) (int, error) {
+	if max < min {
+		return 0, fmt.Errorf(`expected max '%v' to be greater than min '%v'`, max, min)
+	}
+
+	// the range is calculated with big integers, as max-min+1 overflows an int when the bounds are far apart
+	lower := big.NewInt(int64(min))
+	size := new(big.Int).Sub(big.NewInt(int64(max)), lower)
+	size.Add(size, big.NewInt(1))
+
+	n, err := rand.Int(rand.Reader, size)
+	if err != nil {
+		return 0, err
+	}
+	return int(n.Add(n, lower).Int64()), nil
+}
+
+const randomStringCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// maxRandomStringLength stops a fixture from allocating an unbounded string on every request
+const maxRandomStringLength = 64 * 1024
+
+// randomString returns a random alphanumeric string of length n
+func randomString(n int) (string, error) {
+	if n < 0 || n > maxRandomStringLength {
+		return "", fmt.Errorf(`expected length '%v' to be between 0 and %v`, n, maxRandomStringLength)
+	}
+
+	out := make([]byte, n)
+	for i := range out {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(randomStringCharacters))))
+		if err != nil {
+			return "", err
+		}
+		out[i] = randomStringCharacters[j.Int64()]
+	}
+	return string(out), nil
+}
@@ -0,0 +1,183 @@
+package v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const templatedFixturesTestYAML = `
+/content/{uuid}:
+  put:
+    template: true
+    status: '{{ .Request.Query "status" }}'
+    headers:
+      X-Request-Id: '{{ .Request.Header "X-Request-Id" }}'
+    body:
+      id: '{{ .Request.PathParam "uuid" }}'
+      title: '{{ .Request.JSON "$.title" }}'
+      method: '{{ .Request.Method }}'
+      tags:
+        - '{{ .Request.JSON "tags.0" }}'
+      count: 1
+`
+
+func TestRender(t *testing.T) {
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(templatedFixturesTestYAML), &f))
+
+	router := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	MockPaths(router, &f, Options{})
+
+	req := httptest.NewRequest("PUT", "/content/1234?status=201", strings.NewReader(`{"title":"Example Title","tags":["news"]}`))
+	req.Header.Set("X-Request-Id", "tid_1234")
+	w := httptest.NewRecorder()
+	router.handlers["PUT /content/:uuid"](w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "tid_1234", w.Header().Get("X-Request-Id"))
+	assert.JSONEq(t, `{"id":"1234","title":"Example Title","method":"PUT","tags":["news"],"count":1}`, w.Body.String())
+}
+
+func TestRender__DoesNotModifyFixture(t *testing.T) {
+	res := Response{Status: 200, Template: true, Body: map[string]interface{}{"path": "{{ .Request.Path }}"}}
+
+	rendered, err := res.Render(httptest.NewRequest("GET", "/content", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"path": "/content"}, rendered.Body)
+	assert.Equal(t, map[string]interface{}{"path": "{{ .Request.Path }}"}, res.Body)
+}
+
+func TestRender__NotTemplated(t *testing.T) {
+	res := Response{Status: 200, Body: "{{ .Request.Path }}"}
+
+	rendered, err := res.Render(httptest.NewRequest("GET", "/content", nil))
+	require.NoError(t, err)
+	assert.Equal(t, "{{ .Request.Path }}", rendered.Body)
+}
+
+func TestRender__Helpers(t *testing.T) {
+	res := Response{Status: 200, Template: true, Body: map[string]interface{}{
+		"now":    `{{ now "2006-01-02" }}`,
+		"uuid":   "{{ uuid }}",
+		"int":    "{{ randomInt 5 10 }}",
+		"string": "{{ randomString 8 }}",
+	}}
+
+	rendered, err := res.Render(httptest.NewRequest("GET", "/", nil))
+	require.NoError(t, err)
+
+	body := rendered.Body.(map[string]interface{})
+	assert.Equal(t, time.Now().UTC().Format("2006-01-02"), body["now"])
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), body["uuid"])
+	assert.Regexp(t, regexp.MustCompile(`^(5|6|7|8|9|10)$`), body["int"])
+	assert.Regexp(t, regexp.MustCompile(`^[a-zA-Z0-9]{8}$`), body["string"])
+}
+
+func TestRender__InvalidStatus(t *testing.T) {
+	res := Response{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{template: true, status: '{{ .Request.Query "status" }}'}`), &res))
+
+	_, err := res.Render(httptest.NewRequest("GET", "/?status=nope", nil))
+	assert.Error(t, err)
+
+	w := httptest.NewRecorder()
+	writeMockResponse(res, w, httptest.NewRequest("GET", "/?status=nope", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestResponseUnmarshal__InvalidTemplate(t *testing.T) {
+	res := Response{}
+	err := yaml.Unmarshal([]byte(`{template: true, status: 200, body: {id: '{{ .Request.PathParam "uuid" '}}`), &res)
+	assert.Error(t, err)
+}
+
+func TestResponseUnmarshal__TemplatedStatusRequiresTemplate(t *testing.T) {
+	res := Response{}
+	err := yaml.Unmarshal([]byte(`{status: '{{ .Request.Query "status" }}'}`), &res)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected status to be a number")
+}
+
+func TestResourceUnmarshal__InvalidTemplate(t *testing.T) {
+	r := Resource{}
+	err := yaml.Unmarshal([]byte(`{template: true, status: 200, headers: {X-Id: '{{ .Request.Header }'}}`), &r)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid response template")
+}
+
+func TestCheckTemplates(t *testing.T) {
+	res := Response{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{template: true, status: '{{ .Request.Query "status" }}', body: {id: '{{ .Request.PathParam "uuid" }}'}}`), &res))
+	assert.NoError(t, res.CheckTemplates())
+
+	require.NoError(t, yaml.Unmarshal([]byte(`{template: true, status: 200, body: {id: '{{ .Request.Uuid }}'}}`), &res))
+	err := res.CheckTemplates()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Uuid")
+}
+
+func TestResponseUnmarshal__ParsesTemplatesOnce(t *testing.T) {
+	res := Response{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{template: true, status: '{{ .Request.Query "status" }}', headers: {X-Id: '{{ uuid }}'}, body: {id: '{{ uuid }}', tags: ['{{ .Request.Path }}']}}`), &res))
+
+	assert.Len(t, res.templates, 3)
+	for _, text := range []string{`{{ .Request.Query "status" }}`, "{{ uuid }}", "{{ .Request.Path }}"} {
+		assert.NotNil(t, res.templates[text], text)
+	}
+
+	notTemplated := Response{}
+	require.NoError(t, yaml.Unmarshal([]byte(`{status: 200, body: {id: '{{ uuid }}'}}`), &notTemplated))
+	assert.Nil(t, notTemplated.templates)
+}
+
+func TestRender__HelperErrors(t *testing.T) {
+	for _, text := range []string{"{{ randomString -1 }}", "{{ randomString 2000000000 }}", "{{ randomInt 10 5 }}"} {
+		res := Response{}
+		require.NoError(t, yaml.Unmarshal([]byte(`{template: true, status: 200, body: '`+text+`'}`), &res))
+
+		_, err := res.Render(httptest.NewRequest("GET", "/", nil))
+		assert.Error(t, err, text)
+	}
+}
+
+func TestRandomInt__ExtremeBounds(t *testing.T) {
+	max := int(^uint(0) >> 1)
+	min := -max - 1
+
+	n, err := randomInt(min, max)
+	require.NoError(t, err)
+	assert.True(t, n >= min && n <= max)
+
+	n, err = randomInt(max, max)
+	require.NoError(t, err)
+	assert.Equal(t, max, n)
+
+	n, err = randomInt(min, min+1)
+	require.NoError(t, err)
+	assert.True(t, n == min || n == min+1)
+}
+
+func TestRandomString__Length(t *testing.T) {
+	_, err := randomString(-1)
+	assert.EqualError(t, err, "expected length '-1' to be between 0 and 65536")
+
+	_, err = randomString(2000000000)
+	assert.EqualError(t, err, "expected length '2000000000' to be between 0 and 65536")
+
+	s, err := randomString(65536)
+	require.NoError(t, err)
+	assert.Len(t, s, 65536)
+
+	s, err = randomString(0)
+	require.NoError(t, err)
+	assert.Empty(t, s)
+}
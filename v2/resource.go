@@ -1,20 +1,34 @@
 package v2
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"mime"
 	"net/textproto"
 	"net/url"
 )
 
 func (r *Resource) UnmarshalJSON(d []byte) error {
+	seq := Sequence{}
+	err := json.Unmarshal(d, &seq)
+	if err == nil && !seq.IsEmpty() {
+		r.Sequence = seq
+		return nil
+	}
+
 	resp := &Response{}
-	err := json.Unmarshal(d, resp)
+	err = json.Unmarshal(d, resp)
 
 	if err == nil {
 		r.Response = *resp
 		return nil
 	}
 
+	if !bytes.HasPrefix(bytes.TrimSpace(d), []byte("[")) {
+		return err
+	}
+
 	arr := make([]Discriminator, 0)
 	err = json.Unmarshal(d, &arr)
 	if err != nil {
@@ -25,6 +39,55 @@ func (r *Resource) UnmarshalJSON(d []byte) error {
 	return nil
 }
 
+// UnmarshalJSON allows the status of a templated response to be a template, and parses every template
+func (r *Response) UnmarshalJSON(d []byte) error {
+	type plain Response
+	aux := struct {
+		*plain
+		Status json.RawMessage `json:"status"`
+	}{plain: (*plain)(r)}
+
+	if err := json.Unmarshal(d, &aux); err != nil {
+		return err
+	}
+
+	if err := r.decodeBase64Body(); err != nil {
+		return err
+	}
+
+	if err := r.validateBody(); err != nil {
+		return err
+	}
+
+	if len(aux.Status) > 0 {
+		if err := json.Unmarshal(aux.Status, &r.Status); err != nil {
+			status := ""
+			if json.Unmarshal(aux.Status, &status) != nil || !r.Template {
+				return fmt.Errorf(`expected status to be a number, but was '%v'`, string(aux.Status))
+			}
+			r.statusTemplate = status
+		}
+	}
+
+	if !r.Template {
+		return nil
+	}
+	return r.parseTemplates()
+}
+
+// validateBody rejects bodies which cannot be sent with the content-type header, rather than failing every request
+func (r *Response) validateBody() error {
+	if _, isText := r.Body.(string); r.Body == nil || isText {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Headers["content-type"])
+	if err == nil && mediaType == "text/plain" {
+		return ErrTextBody
+	}
+	return nil
+}
+
 // UnmarshalJSON creates a textproto.MIMEHeader compliant struct using provided map values
 func (h *Headers) UnmarshalJSON(d []byte) error {
 	headers := make(map[string]string)
@@ -33,8 +96,16 @@ func (h *Headers) UnmarshalJSON(d []byte) error {
 		return err
 	}
 
-	templated, remainder := ParseRequestValues(headers)
-	h.TemplatedValues = templated
+	templated, remainder, err := ParseRequestValues(headers)
+	if err != nil {
+		return err
+	}
+	h.TemplatedValues = make(TemplatedValues)
+	h.Expressions = make(Expressions)
+	for k, fn := range templated {
+		h.TemplatedValues[textproto.CanonicalMIMEHeaderKey(k)] = fn
+		h.Expressions[textproto.CanonicalMIMEHeaderKey(k)] = headers[k]
+	}
 
 	h.MIMEHeader = textproto.MIMEHeader{}
 	for k, v := range remainder {
@@ -51,8 +122,12 @@ func (q *QueryParams) UnmarshalJSON(d []byte) error {
 		return err
 	}
 
-	templated, remainder := ParseRequestValues(query)
+	templated, remainder, err := ParseRequestValues(query)
+	if err != nil {
+		return err
+	}
 	q.TemplatedValues = templated
+	q.Expressions = NewExpressions(query, templated)
 
 	q.Values = url.Values{}
 	for k, v := range remainder {
@@ -60,3 +135,8 @@ func (q *QueryParams) UnmarshalJSON(d []byte) error {
 	}
 	return nil
 }
+
+// UnmarshalJSON creates the expected path parameters in the same way as query parameters
+func (p *PathParams) UnmarshalJSON(d []byte) error {
+	return (*QueryParams)(p).UnmarshalJSON(d)
+}
@@ -86,3 +86,52 @@ func TestResourceUnmarshal__WithTemplatedValues(t *testing.T) {
 	assert.True(t, ok)
 	assert.True(t, missing(""))
 }
+
+const resourceWithPathParamsTestYAML = `
+- when:
+    pathParams:
+      uuid: ${exists}
+    queryParams:
+      q: ${missing}
+  response:
+    status: 200
+`
+
+func TestResourceUnmarshal__WithPathParams(t *testing.T) {
+	r := Resource{}
+	err := yaml.Unmarshal([]byte(resourceWithPathParamsTestYAML), &r)
+
+	assert.NoError(t, err)
+	assert.Len(t, r.Discriminators, 1)
+
+	exists, ok := r.Discriminators[0].When.PathParams.TemplatedValues["uuid"]
+	assert.True(t, ok)
+	assert.True(t, exists("1234"))
+
+	missing, ok := r.Discriminators[0].When.QueryParams.TemplatedValues["q"]
+	assert.True(t, ok)
+	assert.True(t, missing(""))
+}
+
+const resourceWithInvalidTemplatedValueTestYAML = `
+- when:
+    headers:
+      Authorization: ${regex:^Bearer (}
+  response:
+    status: 200
+`
+
+func TestResourceUnmarshal__WithInvalidTemplatedValue(t *testing.T) {
+	r := Resource{}
+	err := yaml.Unmarshal([]byte(resourceWithInvalidTemplatedValueTestYAML), &r)
+	assert.Error(t, err)
+}
+
+func TestResourceUnmarshal__WithNonTextBodyForTextPlain(t *testing.T) {
+	r := Resource{}
+	err := yaml.Unmarshal([]byte(`{status: 200, headers: {content-type: text/plain; charset=utf-8}, body: {title: Example}}`), &r)
+	assert.EqualError(t, err, "error unmarshaling JSON: while decoding JSON: expected the body to be a string to be sent as text/plain")
+
+	r = Resource{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`{status: 200, headers: {content-type: text/plain}, body: Example}`), &r))
+}
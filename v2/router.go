@@ -2,60 +2,235 @@ package v2
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"mime"
 	"net/http"
+	"sort"
+	"strings"
 
+	"github.com/husobee/vestigo"
+	"github.com/peteclark-ft/ersatz/journal"
 	log "github.com/sirupsen/logrus"
 	yaml "gopkg.in/yaml.v2"
 )
 
-// MockPaths adds endpoints to the provided router as per the ersatz-fixtures.yml
-func MockPaths(r Router, paths *Fixtures) {
+// Methods are the standard http methods. Fixtures may also use any other method, i.e. purge.
+var Methods = []string{"get", "head", "post", "put", "patch", "delete", "options", "connect", "trace"}
+
+// MockPaths adds endpoints to the provided router as per the ersatz-fixtures.yml, and returns the State shared by their scenarios and sequences (which is the State in the options, if provided). Paths with a get fixture also respond to HEAD requests, and every path responds to OPTIONS requests with the methods it allows, unless they have their own fixtures.
+func MockPaths(r Router, paths *Fixtures, opts Options) *State {
+	state := opts.State
+	if state == nil {
+		state = NewState()
+	}
+
 	for p, path := range *paths {
+		route := ParseRoute(p)
 		for method, resource := range path {
-			switch method {
-			case "get":
-				r.Get(p, mockResource(resource))
-			case "post":
-				r.Post(p, mockResource(resource))
-			case "put":
-				r.Put(p, mockResource(resource))
-			case "delete":
-				r.Delete(p, mockResource(resource))
-			}
+			resource.id = resourceID(opts.Host, method, p)
+			resource.state = state
+			resource.options = opts
+
+			r.Add(strings.ToUpper(method), route.String(), mockResource(resource), capturePathParams(route), JournalFixture(p, method))
+		}
+
+		if get, ok := path["get"]; ok && !path.has("head") {
+			get.id = resourceID(opts.Host, "get", p)
+			get.state = state
+			get.options = opts
+			r.Add(http.MethodHead, route.String(), mockResource(get), capturePathParams(route), JournalFixture(p, "get"))
+		}
+
+		if !path.has("options") {
+			r.Add(http.MethodOptions, route.String(), Allow(path.Allowed()), JournalFixture(p, "options"))
+		}
+	}
+	return state
+}
+
+// resourceID identifies the resource's sequences in the State
+func resourceID(host string, method string, path string) string {
+	if host == "" {
+		return method + " " + path
+	}
+	return host + " " + method + " " + path
+}
+
+// Allowed returns the methods the path responds to in upper case, including HEAD if it has a get fixture, and OPTIONS
+func (p Path) Allowed() []string {
+	methods := make([]string, 0, len(p))
+	for method := range p {
+		methods = append(methods, method)
+	}
+	return AllowedMethods(methods)
+}
+
+// AllowedMethods returns the methods a path with fixtures for the methods (in any case) responds to in upper case, including HEAD if it has a get fixture, and OPTIONS
+func AllowedMethods(methods []string) []string {
+	allowed := []string{http.MethodOptions}
+	for _, method := range methods {
+		if m := strings.ToUpper(method); !Contains(m, allowed...) {
+			allowed = append(allowed, m)
+		}
+	}
+
+	if Contains(http.MethodGet, allowed...) && !Contains(http.MethodHead, allowed...) {
+		allowed = append(allowed, http.MethodHead)
+	}
+
+	sort.Strings(allowed)
+	return allowed
+}
+
+// has returns true if the path has a fixture for the method, in any case
+func (p Path) has(method string) bool {
+	for m := range p {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow responds to OPTIONS requests with the allowed methods, i.e. from AllowedMethods
+func Allow(methods []string) http.HandlerFunc {
+	allowed := strings.Join(methods, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allowed)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ValidMethod returns true if the fixture method is a valid http method token
+func ValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+
+	for _, c := range method {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// JournalFixture is router middleware which records the fixture used to respond to the request in the request journal
+func JournalFixture(path string, method string) vestigo.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			journal.SetFixture(r, path, method)
+			next(w, r)
 		}
 	}
 }
 
 func mockResource(res Resource) func(w http.ResponseWriter, r *http.Request) {
+	state := res.state
+	if state == nil {
+		state = NewState()
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
-		if res.Discriminators == nil {
-			writeMockResponse(res.Response, w, r)
+		if res.Discriminators == nil && !res.Sequence.IsEmpty() {
+			writeMockResponse(res.withDefaults(state.Next(res.id, res.Sequence)), w, r)
 			return
 		}
 
-		if res.Discriminators != nil && !res.Discriminators.AtLeastOneDiscriminatorIsSatisfied(r) {
-			w.WriteHeader(http.StatusNotImplemented)
+		if res.Discriminators == nil {
+			writeMockResponse(res.withDefaults(res.Response), w, r)
 			return
 		}
 
-		for _, d := range res.Discriminators {
-			if d.When.SatisfiesDiscriminator(r) {
-				writeMockResponse(d.Response, w, r)
+		for i, d := range res.Discriminators {
+			if d.When.SatisfiesDiscriminator(r) && state.Transition(d.ScenarioName(), d.RequiredState, d.NewState) {
+				journal.SetDiscriminator(r, i)
+				writeMockResponse(res.withDefaults(d.respond(state, res.id, i)), w, r)
 				return
 			}
 		}
+
+		report := res.Discriminators.Diagnose(r, state)
+		if res.options.Proxy != nil {
+			journal.SetDiagnostics(r, report)
+			res.options.Proxy.ServeHTTP(w, r)
+			return
+		}
+
+		writeNotImplemented(report, w, r)
+	}
+}
+
+// withDefaults applies the options shared by every fixture to the response
+func (res Resource) withDefaults(resp Response) Response {
+	if resp.Delay == nil {
+		resp.Delay = res.options.DefaultDelay
+	}
+	return resp
+}
+
+// writeNotImplemented responds with a report explaining why none of the discriminators matched the request
+func writeNotImplemented(report Report, w http.ResponseWriter, r *http.Request) {
+	journal.SetDiagnostics(r, report)
+	log.WithField("method", r.Method).WithField("path", r.URL.Path).WithField("diagnostics", report).Warn("No discriminators matched the request")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotImplemented)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.WithError(err).Error("Failed to write diagnostics report")
 	}
 }
 
 func writeMockResponse(res Response, w http.ResponseWriter, r *http.Request) {
+	res, err := res.Render(r)
+	if err != nil {
+		log.WithError(err).Error("Failed to render response template")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Delay.Wait(r.Context())
+
+	if res.Proxy != nil {
+		res.Proxy.ServeHTTP(w, r)
+		return
+	}
+
 	for k, v := range res.Headers {
 		w.Header().Add(k, v)
 	}
 
+	output, err := marshalBody(res)
+	if err != nil {
+		log.WithError(err).Error("Failed to marshal body")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if res.Fault != nil {
+		writeFault(res.Fault, res.Status, output, w, r)
+		return
+	}
+
 	w.WriteHeader(res.Status)
+	if output != nil {
+		w.Write(output)
+	}
+}
+
+// ErrTextBody is returned for text/plain responses which do not have a string body, when they are loaded or served
+var ErrTextBody = errors.New("expected the body to be a string to be sent as text/plain")
+
+// marshalBody serialises the response body using the Content-Type header, which defaults to json. Bodies from a bodyFile or base64Body are sent as they are.
+func marshalBody(res Response) ([]byte, error) {
+	if res.raw != nil {
+		return res.raw, nil
+	}
+
 	if res.Body == nil {
-		return
+		return nil, nil
 	}
 
 	contentType, ok := res.Headers["content-type"]
@@ -65,25 +240,24 @@ func writeMockResponse(res Response, w http.ResponseWriter, r *http.Request) {
 
 	mediaType, _, err := mime.ParseMediaType(contentType)
 	if err != nil {
-		log.WithError(err).Error("Failed to parse media type")
-		return
+		return nil, fmt.Errorf(`failed to parse media type '%v': %v`, contentType, err)
 	}
 
-	var output []byte
-
-	switch mediaType {
-	case "application/json":
-		output, err = json.Marshal(res.Body)
-	case "application/x-yaml":
-		output, err = yaml.Marshal(res.Body)
-	case "text/plain":
-		output = []byte(res.Body.(string))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return json.Marshal(res.Body)
+	case mediaType == "application/x-yaml":
+		return yaml.Marshal(res.Body)
+	case mediaType == "text/plain":
+		text, ok := res.Body.(string)
+		if !ok {
+			return nil, ErrTextBody
+		}
+		return []byte(text), nil
 	}
 
-	if err != nil {
-		log.WithError(err).Error("Failed to marshal body")
-		return
+	if text, ok := res.Body.(string); ok { // i.e. text/html or application/xml
+		return []byte(text), nil
 	}
-
-	w.Write(output)
+	return nil, nil
 }
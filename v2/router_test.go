@@ -1,13 +1,16 @@
 package v2
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/husobee/vestigo"
+	"github.com/peteclark-ft/ersatz/journal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMockPaths(t *testing.T) {
@@ -22,12 +25,14 @@ func TestMockPaths(t *testing.T) {
 	p["delete"] = r
 
 	mockRouter := new(MockRouter)
-	mockRouter.On("Get", "/example", mock.Anything)
-	mockRouter.On("Post", "/example", mock.Anything)
-	mockRouter.On("Put", "/example", mock.Anything)
-	mockRouter.On("Delete", "/example", mock.Anything)
+	mockRouter.On("Add", "GET", "/example", mock.Anything)
+	mockRouter.On("Add", "POST", "/example", mock.Anything)
+	mockRouter.On("Add", "PUT", "/example", mock.Anything)
+	mockRouter.On("Add", "DELETE", "/example", mock.Anything)
+	mockRouter.On("Add", "HEAD", "/example", mock.Anything)
+	mockRouter.On("Add", "OPTIONS", "/example", mock.Anything)
 
-	MockPaths(mockRouter, &f)
+	MockPaths(mockRouter, &f, Options{})
 	mockRouter.AssertExpectations(t)
 }
 
@@ -42,6 +47,17 @@ func TestMockResourcePlaintextResponse(t *testing.T) {
 	assert.Equal(t, http.StatusTeapot, w.Code)
 }
 
+func TestMockResourcePlaintextResponse__WithNonTextBody(t *testing.T) {
+	res := Resource{Response: Response{Status: http.StatusOK, Body: map[string]interface{}{"title": "Example"}, Headers: map[string]string{"content-type": "text/plain"}}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	mockResource(res)(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "expected the body to be a string to be sent as text/plain")
+}
+
 func TestMockResourceJSONResponseIsDefault(t *testing.T) {
 	res := Resource{Response: Response{Status: http.StatusTeapot, Body: "OK"}}
 
@@ -82,6 +98,17 @@ func TestMockResourceYAMLResponse(t *testing.T) {
 	assert.Equal(t, http.StatusTeapot, w.Code)
 }
 
+func TestMockResourceJSONSuffixResponse(t *testing.T) {
+	res := Resource{Response: Response{Status: http.StatusOK, Body: map[string]interface{}{"title": "Example"}, Headers: map[string]string{"content-type": "application/hal+json"}}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	mockResource(res)(w, r)
+	assert.JSONEq(t, `{"title":"Example"}`, w.Body.String())
+	assert.Equal(t, "application/hal+json", w.Header().Get("content-type"))
+}
+
 func TestMockResourceNoBody(t *testing.T) {
 	res := Resource{Response: Response{Status: http.StatusAccepted}}
 
@@ -131,21 +158,167 @@ func TestMockResource__WithDiscriminatorButNotSatisfied(t *testing.T) {
 
 	mockResource(res)(w, r)
 	assert.Equal(t, http.StatusNotImplemented, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	report := Report{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	require.Len(t, report.Candidates, 1)
+	assert.Equal(t, []Mismatch{{Location: "headers", Name: "X-Testing", Expected: "value", Actual: ""}}, report.Candidates[0].Mismatches)
+}
+
+func TestMockResource__JournalsDiagnostics(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Headers.Add("x-testing", "value")
+
+	res := Resource{Discriminators: []Discriminator{{When: d, Response: Response{Status: http.StatusAccepted}}}}
+
+	j := journal.New(0)
+	handler := j.Record(http.HandlerFunc(JournalFixture("/example", "get")(mockResource(res))))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/example", nil))
+
+	entries := j.Entries(journal.Filter{Unmatched: true})
+	require.Len(t, entries, 1)
+	assert.IsType(t, Report{}, entries[0].Diagnostics)
+	assert.Nil(t, entries[0].Fixture.Discriminator)
+}
+
+func TestMockPaths__WithPathParams(t *testing.T) {
+	f := make(Fixtures)
+	f["/content/{uuid}"] = Path{"get": Resource{Response: Response{Status: http.StatusOK}}}
+	f["/static/*"] = Path{"get": Resource{Response: Response{Status: http.StatusOK}}}
+
+	mockRouter := new(MockRouter)
+	mockRouter.On("Add", "GET", "/content/:uuid", mock.Anything)
+	mockRouter.On("Add", "GET", "/static/*", mock.Anything)
+	mockRouter.On("Add", "HEAD", mock.Anything, mock.Anything)
+	mockRouter.On("Add", "OPTIONS", mock.Anything, mock.Anything)
+
+	MockPaths(mockRouter, &f, Options{})
+	mockRouter.AssertExpectations(t)
+}
+
+func TestMockResource__WithPathParamDiscriminator(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.PathParams.Add("uuid", "1234")
+
+	res := Resource{
+		Discriminators: []Discriminator{
+			{
+				When:     d,
+				Response: Response{Status: http.StatusOK},
+			},
+		},
+	}
+
+	handler := capturePathParams(ParseRoute("/content/{uuid}"))(mockResource(res))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/content/1234", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/content/5678", nil))
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestMockResource__JournalsMatchedDiscriminator(t *testing.T) {
+	d := NewRequestDiscriminator()
+	d.Headers.Add("x-testing", "value")
+
+	res := Resource{
+		Discriminators: []Discriminator{
+			{When: NewRequestDiscriminator(), Response: Response{Status: http.StatusAccepted}},
+		},
+	}
+	res.Discriminators[0].When.Headers.Add("x-testing", "other")
+	res.Discriminators = append(res.Discriminators, Discriminator{When: d, Response: Response{Status: http.StatusOK}})
+
+	j := journal.New(0)
+	handler := j.Record(http.HandlerFunc(JournalFixture("/example", "get")(mockResource(res))))
+
+	r := httptest.NewRequest("GET", "/example", nil)
+	r.Header.Add("x-testing", "value")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	entries := j.Entries(journal.Filter{})
+	require.Len(t, entries, 1)
+	require.NotNil(t, entries[0].Fixture)
+	assert.Equal(t, "/example", entries[0].Fixture.Path)
+	assert.Equal(t, "get", entries[0].Fixture.Method)
+	require.NotNil(t, entries[0].Fixture.Discriminator)
+	assert.Equal(t, 1, *entries[0].Fixture.Discriminator)
+	assert.Equal(t, http.StatusOK, entries[0].Status)
 }
 
 type MockRouter struct {
 	mock.Mock
 }
 
-func (m *MockRouter) Get(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+func (m *MockRouter) Add(method string, path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
+	m.Called(method, path, handler)
 }
-func (m *MockRouter) Put(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+
+// testRouter records the handlers registered for each method and path, with their middleware applied
+type testRouter struct {
+	handlers map[string]http.HandlerFunc
 }
-func (m *MockRouter) Post(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+
+func (t *testRouter) Add(method string, path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
+	for _, m := range middleware {
+		handler = m(handler)
+	}
+	t.handlers[method+" "+path] = handler
 }
-func (m *MockRouter) Delete(path string, handler http.HandlerFunc, middleware ...vestigo.Middleware) {
-	m.Called(path, handler)
+
+func TestMockPaths__AllMethods(t *testing.T) {
+	f := Fixtures{
+		"/content/{uuid}": Path{
+			"get":   Resource{Response: Response{Status: http.StatusOK, Body: map[string]interface{}{"title": "Example"}}},
+			"patch": Resource{Response: Response{Status: http.StatusNoContent}},
+			"purge": Resource{Response: Response{Status: http.StatusAccepted}},
+		},
+		"/__health": Path{
+			"head":    Resource{Response: Response{Status: http.StatusServiceUnavailable}},
+			"get":     Resource{Response: Response{Status: http.StatusOK}},
+			"options": Resource{Response: Response{Status: http.StatusTeapot}},
+		},
+	}
+
+	router := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	MockPaths(router, &f, Options{})
+
+	serve := func(method string, path string, route string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		require.Contains(t, router.handlers, method+" "+route)
+		router.handlers[method+" "+route](w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve("PATCH", "/content/1234", "/content/:uuid").Code)
+	assert.Equal(t, http.StatusAccepted, serve("PURGE", "/content/1234", "/content/:uuid").Code)
+
+	head := serve("HEAD", "/content/1234", "/content/:uuid")
+	assert.Equal(t, http.StatusOK, head.Code)
+
+	options := serve("OPTIONS", "/content/1234", "/content/:uuid")
+	assert.Equal(t, http.StatusNoContent, options.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS, PATCH, PURGE", options.Header().Get("Allow"))
+
+	assert.Equal(t, http.StatusServiceUnavailable, serve("HEAD", "/__health", "/__health").Code)
+	assert.Equal(t, http.StatusTeapot, serve("OPTIONS", "/__health", "/__health").Code)
+}
+
+func TestPathAllowed(t *testing.T) {
+	assert.Equal(t, []string{"OPTIONS", "POST"}, Path{"post": Resource{}}.Allowed())
+	assert.Equal(t, []string{"GET", "HEAD", "OPTIONS"}, Path{"get": Resource{}}.Allowed())
+	assert.Equal(t, []string{"GET", "HEAD", "OPTIONS"}, Path{"get": Resource{}, "options": Resource{}, "head": Resource{}}.Allowed())
+}
+
+func TestValidMethod(t *testing.T) {
+	assert.True(t, ValidMethod("get"))
+	assert.True(t, ValidMethod("PROPFIND"))
+	assert.True(t, ValidMethod("x-custom"))
+	assert.False(t, ValidMethod(""))
+	assert.False(t, ValidMethod("ge t"))
+	assert.False(t, ValidMethod("get/"))
 }
@@ -0,0 +1,141 @@
+package v2
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/husobee/vestigo"
+)
+
+// Wildcard is the name under which the remainder of a path matched by a trailing * is captured
+const Wildcard = "*"
+
+type pathParamsKey struct{}
+
+// Route is a fixture path, which may contain named parameters (i.e. /content/:uuid or /content/{uuid}) and a trailing * wildcard
+type Route struct {
+	segments []segment
+}
+
+type segment struct {
+	literal  string
+	param    string
+	wildcard bool
+}
+
+// ParseRoute splits the fixture path into its literal, parameter and wildcard segments
+func ParseRoute(path string) Route {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	route := Route{segments: make([]segment, 0, len(parts))}
+
+	for i, p := range parts {
+		switch {
+		case p == Wildcard && i == len(parts)-1:
+			route.segments = append(route.segments, segment{wildcard: true})
+		case strings.HasPrefix(p, ":") && len(p) > 1:
+			route.segments = append(route.segments, segment{param: p[1:]})
+		case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") && len(p) > 2:
+			route.segments = append(route.segments, segment{param: p[1 : len(p)-1]})
+		default:
+			route.segments = append(route.segments, segment{literal: p})
+		}
+	}
+	return route
+}
+
+// String returns the route in the syntax expected by the router, i.e. /content/{uuid} becomes /content/:uuid
+func (r Route) String() string {
+	parts := make([]string, 0, len(r.segments))
+	for _, s := range r.segments {
+		switch {
+		case s.wildcard:
+			parts = append(parts, Wildcard)
+		case s.param != "":
+			parts = append(parts, ":"+s.param)
+		default:
+			parts = append(parts, s.literal)
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// Expand builds a request path from the route, using the provided value for each parameter and the wildcard. It returns the name of the first parameter without a value if the path cannot be built.
+func (r Route) Expand(params map[string]string) (string, string) {
+	parts := make([]string, 0, len(r.segments))
+	for _, s := range r.segments {
+		name := s.param
+		if s.wildcard {
+			name = Wildcard
+		}
+
+		if name == "" {
+			parts = append(parts, s.literal)
+			continue
+		}
+
+		v, ok := params[name]
+		if !ok || v == "" {
+			return "", name
+		}
+		parts = append(parts, v)
+	}
+	return "/" + strings.Join(parts, "/"), ""
+}
+
+// Match checks the path against the route, returning the values captured by any parameters or wildcard
+func (r Route) Match(path string) (url.Values, bool) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	params := make(url.Values)
+
+	for i, s := range r.segments {
+		if s.wildcard {
+			params.Set(Wildcard, strings.Join(parts[i:], "/"))
+			return params, true
+		}
+
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		if s.param != "" {
+			params.Set(s.param, parts[i])
+			continue
+		}
+
+		if s.literal != parts[i] {
+			return nil, false
+		}
+	}
+
+	if len(parts) != len(r.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// capturePathParams is router middleware which makes the values captured by the route available to discriminators
+func capturePathParams(route Route) vestigo.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			params, ok := route.Match(r.URL.Path)
+			if !ok {
+				next(w, r)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), pathParamsKey{}, params)
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// PathParamsFromRequest returns the path parameters captured for the request, or empty values if there are none
+func PathParamsFromRequest(r *http.Request) url.Values {
+	params, ok := r.Context().Value(pathParamsKey{}).(url.Values)
+	if !ok {
+		return make(url.Values)
+	}
+	return params
+}
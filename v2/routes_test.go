@@ -0,0 +1,91 @@
+package v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRoute__Literal(t *testing.T) {
+	r := ParseRoute("/__health")
+	assert.Equal(t, "/__health", r.String())
+
+	params, ok := r.Match("/__health")
+	assert.True(t, ok)
+	assert.Empty(t, params)
+
+	_, ok = r.Match("/__gtg")
+	assert.False(t, ok)
+}
+
+func TestParseRoute__BraceParams(t *testing.T) {
+	r := ParseRoute("/content/{uuid}/annotations")
+	assert.Equal(t, "/content/:uuid/annotations", r.String())
+
+	params, ok := r.Match("/content/85be197c-4fda-407b-8ae3-28bd81978616/annotations")
+	assert.True(t, ok)
+	assert.Equal(t, "85be197c-4fda-407b-8ae3-28bd81978616", params.Get("uuid"))
+}
+
+func TestParseRoute__ColonParams(t *testing.T) {
+	r := ParseRoute("/content/:uuid")
+	assert.Equal(t, "/content/:uuid", r.String())
+
+	params, ok := r.Match("/content/1234")
+	assert.True(t, ok)
+	assert.Equal(t, "1234", params.Get("uuid"))
+
+	_, ok = r.Match("/content/1234/annotations")
+	assert.False(t, ok)
+
+	_, ok = r.Match("/content")
+	assert.False(t, ok)
+}
+
+func TestParseRoute__Wildcard(t *testing.T) {
+	r := ParseRoute("/static/*")
+	assert.Equal(t, "/static/*", r.String())
+
+	params, ok := r.Match("/static/css/main.css")
+	assert.True(t, ok)
+	assert.Equal(t, "css/main.css", params.Get(Wildcard))
+}
+
+func TestParseRoute__WildcardOnlyAtEnd(t *testing.T) {
+	r := ParseRoute("/static/*/main.css")
+
+	_, ok := r.Match("/static/css/main.css")
+	assert.False(t, ok)
+
+	_, ok = r.Match("/static/*/main.css")
+	assert.True(t, ok)
+}
+
+func TestRouteExpand(t *testing.T) {
+	r := ParseRoute("/content/{uuid}/:type/*")
+
+	path, missing := r.Expand(map[string]string{"uuid": "1234", "type": "annotations", "*": "a/b"})
+	assert.Equal(t, "/content/1234/annotations/a/b", path)
+	assert.Empty(t, missing)
+
+	_, missing = r.Expand(map[string]string{"uuid": "1234"})
+	assert.Equal(t, "type", missing)
+}
+
+func TestCapturePathParams(t *testing.T) {
+	var captured string
+	handler := capturePathParams(ParseRoute("/content/{uuid}"))(func(w http.ResponseWriter, r *http.Request) {
+		captured = PathParamsFromRequest(r).Get("uuid")
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/content/1234", nil))
+	assert.Equal(t, "1234", captured)
+}
+
+func TestPathParamsFromRequest__NoParams(t *testing.T) {
+	params := PathParamsFromRequest(httptest.NewRequest("GET", "/content/1234", nil))
+	assert.NotNil(t, params)
+	assert.Empty(t, params)
+}
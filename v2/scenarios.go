@@ -0,0 +1,126 @@
+package v2
+
+import (
+	"strconv"
+	"sync"
+)
+
+// DefaultScenario is the scenario used by discriminators which declare a requiredState or newState without naming a scenario
+const DefaultScenario = "default"
+
+// StartedState is the state every scenario begins in
+const StartedState = "Started"
+
+// Sequence is a list of responses which are served in order. Once the last response has been served, it is served for every subsequent request, unless Loop is set, in which case the sequence starts again.
+type Sequence struct {
+	Responses []Response `json:"sequence"`
+	Loop      bool       `json:"loop"`
+}
+
+// State holds the current state of every scenario, and the position of every sequence, for a single set of fixtures
+type State struct {
+	sync.Mutex
+	scenarios map[string]string
+	sequences map[string]int
+}
+
+// NewState creates a State with every scenario in the StartedState
+func NewState() *State {
+	return &State{scenarios: make(map[string]string), sequences: make(map[string]int)}
+}
+
+// Scenarios returns the current state of every scenario which has been used
+func (s *State) Scenarios() map[string]string {
+	s.Lock()
+	defer s.Unlock()
+
+	scenarios := make(map[string]string)
+	for k, v := range s.scenarios {
+		scenarios[k] = v
+	}
+	return scenarios
+}
+
+// Scenario returns the current state of the scenario
+func (s *State) Scenario(scenario string) string {
+	s.Lock()
+	defer s.Unlock()
+	return s.current(scenario)
+}
+
+// SetScenario moves the scenario into the provided state
+func (s *State) SetScenario(scenario string, state string) {
+	s.Lock()
+	defer s.Unlock()
+	s.scenarios[scenario] = state
+}
+
+// Reset returns every scenario to the StartedState, and every sequence to its first response
+func (s *State) Reset() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.scenarios = make(map[string]string)
+	s.sequences = make(map[string]int)
+}
+
+// Transition moves the scenario to newState, provided it is currently in requiredState. An empty requiredState matches any state, and an empty newState leaves the state unchanged.
+func (s *State) Transition(scenario string, requiredState string, newState string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if requiredState != "" && s.current(scenario) != requiredState {
+		return false
+	}
+
+	if newState != "" {
+		s.scenarios[scenario] = newState
+	}
+	return true
+}
+
+// Next returns the next response in the sequence with the given id
+func (s *State) Next(id string, seq Sequence) Response {
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.sequences[id]
+	if i >= len(seq.Responses) {
+		i = len(seq.Responses) - 1
+		if seq.Loop {
+			i = 0
+		}
+	}
+
+	s.sequences[id] = i + 1
+	return seq.Responses[i]
+}
+
+func (s *State) current(scenario string) string {
+	state, ok := s.scenarios[scenario]
+	if !ok {
+		return StartedState
+	}
+	return state
+}
+
+// IsEmpty returns true if the sequence has no responses
+func (seq Sequence) IsEmpty() bool {
+	return len(seq.Responses) == 0
+}
+
+// ScenarioName returns the scenario the discriminator uses, which is the DefaultScenario unless another is named
+func (d Discriminator) ScenarioName() string {
+	if d.Scenario == "" {
+		return DefaultScenario
+	}
+	return d.Scenario
+}
+
+// respond selects the response for the discriminator, advancing its sequence if it has one
+func (d Discriminator) respond(state *State, id string, index int) Response {
+	if d.Sequence.IsEmpty() {
+		return d.Response
+	}
+	return state.Next(id+"#"+strconv.Itoa(index), d.Sequence)
+}
@@ -0,0 +1,206 @@
+package v2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateTransition(t *testing.T) {
+	s := NewState()
+	assert.Equal(t, StartedState, s.Scenario("content"))
+
+	assert.False(t, s.Transition("content", "Updated", ""))
+	assert.True(t, s.Transition("content", StartedState, "Updated"))
+	assert.Equal(t, "Updated", s.Scenario("content"))
+
+	assert.True(t, s.Transition("content", "", ""))
+	assert.Equal(t, "Updated", s.Scenario("content"))
+	assert.Equal(t, map[string]string{"content": "Updated"}, s.Scenarios())
+
+	s.Reset()
+	assert.Equal(t, StartedState, s.Scenario("content"))
+	assert.Empty(t, s.Scenarios())
+}
+
+func TestStateSetScenario(t *testing.T) {
+	s := NewState()
+	s.SetScenario("content", "Deleted")
+	assert.Equal(t, "Deleted", s.Scenario("content"))
+}
+
+func TestStateNext__SticksOnLast(t *testing.T) {
+	s := NewState()
+	seq := Sequence{Responses: []Response{{Status: 202}, {Status: 200}}}
+
+	statuses := make([]int, 0)
+	for i := 0; i < 4; i++ {
+		statuses = append(statuses, s.Next("poll", seq).Status)
+	}
+	assert.Equal(t, []int{202, 200, 200, 200}, statuses)
+
+	s.Reset()
+	assert.Equal(t, 202, s.Next("poll", seq).Status)
+}
+
+func TestStateNext__Loops(t *testing.T) {
+	s := NewState()
+	seq := Sequence{Responses: []Response{{Status: 503}, {Status: 200}}, Loop: true}
+
+	statuses := make([]int, 0)
+	for i := 0; i < 5; i++ {
+		statuses = append(statuses, s.Next("retry", seq).Status)
+	}
+	assert.Equal(t, []int{503, 200, 503, 200, 503}, statuses)
+}
+
+const sequenceResourceTestYAML = `
+sequence:
+  - status: 202
+  - status: 202
+  - status: 200
+    body:
+      done: true
+`
+
+func TestResourceUnmarshal__WithSequence(t *testing.T) {
+	r := Resource{}
+	require.NoError(t, yaml.Unmarshal([]byte(sequenceResourceTestYAML), &r))
+
+	assert.Nil(t, r.Discriminators)
+	require.Len(t, r.Sequence.Responses, 3)
+	assert.False(t, r.Sequence.Loop)
+	assert.Equal(t, 200, r.Sequence.Responses[2].Status)
+}
+
+func TestMockResource__WithSequence(t *testing.T) {
+	r := Resource{}
+	require.NoError(t, yaml.Unmarshal([]byte(sequenceResourceTestYAML), &r))
+
+	handler := mockResource(r)
+	statuses := make([]int, 0)
+	for i := 0; i < 4; i++ {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/poll", nil))
+		statuses = append(statuses, w.Code)
+	}
+	assert.Equal(t, []int{202, 202, 200, 200}, statuses)
+}
+
+const scenarioFixturesTestYAML = `
+/content/{uuid}:
+  put:
+    - when:
+        body:
+          json:
+            $.title: ${exists}
+      scenario: content
+      newState: Updated
+      response:
+        status: 200
+  get:
+    - scenario: content
+      requiredState: Updated
+      response:
+        status: 200
+        body:
+          title: Updated Title
+    - response:
+        status: 200
+        body:
+          title: Original Title
+/retry:
+  get:
+    - when:
+        headers:
+          X-Retry: ${exists}
+      sequence:
+        - status: 503
+        - status: 200
+      loop: true
+`
+
+func TestMockPaths__WithScenarios(t *testing.T) {
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(scenarioFixturesTestYAML), &f))
+
+	router := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	state := MockPaths(router, &f, Options{})
+	require.NotNil(t, state)
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		router.handlers["GET /content/:uuid"](w, httptest.NewRequest("GET", "/content/1234", nil))
+		return w.Body.String()
+	}
+
+	assert.Equal(t, `{"title":"Original Title"}`, get())
+
+	w := httptest.NewRecorder()
+	router.handlers["PUT /content/:uuid"](w, httptest.NewRequest("PUT", "/content/1234", strings.NewReader(`{"title":"Updated Title"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Updated", state.Scenario("content"))
+
+	assert.Equal(t, `{"title":"Updated Title"}`, get())
+
+	state.Reset()
+	assert.Equal(t, `{"title":"Original Title"}`, get())
+}
+
+func TestMockPaths__WithDiscriminatorSequence(t *testing.T) {
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(scenarioFixturesTestYAML), &f))
+
+	router := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	MockPaths(router, &f, Options{})
+
+	statuses := make([]int, 0)
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/retry", nil)
+		r.Header.Set("X-Retry", "true")
+		router.handlers["GET /retry"](w, r)
+		statuses = append(statuses, w.Code)
+	}
+	assert.Equal(t, []int{503, 200, 503}, statuses)
+}
+
+func TestMockPaths__SharedState(t *testing.T) {
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(scenarioFixturesTestYAML), &f))
+
+	state := NewState()
+	content := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	people := &testRouter{handlers: make(map[string]http.HandlerFunc)}
+	assert.Equal(t, state, MockPaths(content, &f, Options{State: state, Host: "content-api"}))
+	assert.Equal(t, state, MockPaths(people, &f, Options{State: state, Host: "people-api"}))
+
+	retry := func(router *testRouter) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/retry", nil)
+		r.Header.Set("X-Retry", "true")
+		router.handlers["GET /retry"](w, r)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, retry(content))
+	assert.Equal(t, http.StatusServiceUnavailable, retry(people), "sequences are counted separately for each host")
+	assert.Equal(t, http.StatusOK, retry(content))
+
+	w := httptest.NewRecorder()
+	content.handlers["PUT /content/:uuid"](w, httptest.NewRequest("PUT", "/content/1234", strings.NewReader(`{"title":"Updated Title"}`)))
+	assert.Equal(t, "Updated", state.Scenario("content"), "scenarios are shared by every host")
+}
+
+func TestDiscriminatorsDiagnose__WithScenario(t *testing.T) {
+	d := Discriminators{{Scenario: "content", RequiredState: "Updated"}}
+
+	report := d.Diagnose(httptest.NewRequest("GET", "/", nil), NewState())
+	require.Len(t, report.Candidates, 1)
+	assert.Equal(t, []Mismatch{{Location: "scenario", Name: "content", Expected: "Updated", Actual: StartedState}}, report.Candidates[0].Mismatches)
+}
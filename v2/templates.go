@@ -1,6 +1,11 @@
 package v2
 
-import "net/textproto"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
 
 func Exists(value string) bool {
 	return value != ""
@@ -10,19 +15,165 @@ func Missing(value string) bool {
 	return value == ""
 }
 
-func ParseRequestValues(rawValues map[string]string) (TemplatedValues, map[string]string) {
+// Regex returns a TemplatedFunction which matches values against the regular expression
+func Regex(re *regexp.Regexp) TemplatedFunction {
+	return re.MatchString
+}
+
+// Prefix returns a TemplatedFunction which matches values starting with the prefix
+func Prefix(prefix string) TemplatedFunction {
+	return func(value string) bool {
+		return strings.HasPrefix(value, prefix)
+	}
+}
+
+// Substring returns a TemplatedFunction which matches values containing the substring
+func Substring(substr string) TemplatedFunction {
+	return func(value string) bool {
+		return strings.Contains(value, substr)
+	}
+}
+
+// OneOf returns a TemplatedFunction which matches values equal to any of the options
+func OneOf(options ...string) TemplatedFunction {
+	return func(value string) bool {
+		return Contains(value, options...)
+	}
+}
+
+// Not returns a TemplatedFunction which matches values the provided function does not
+func Not(fn TemplatedFunction) TemplatedFunction {
+	return func(value string) bool {
+		return !fn(value)
+	}
+}
+
+// GreaterThan returns a TemplatedFunction which matches numeric values greater than n
+func GreaterThan(n float64) TemplatedFunction {
+	return func(value string) bool {
+		f, err := strconv.ParseFloat(value, 64)
+		return err == nil && f > n
+	}
+}
+
+// LessThan returns a TemplatedFunction which matches numeric values less than n
+func LessThan(n float64) TemplatedFunction {
+	return func(value string) bool {
+		f, err := strconv.ParseFloat(value, 64)
+		return err == nil && f < n
+	}
+}
+
+// EqualsIgnoreCase returns a TemplatedFunction which matches values equal to the expected value under case-folding
+func EqualsIgnoreCase(expected string) TemplatedFunction {
+	return func(value string) bool {
+		return strings.EqualFold(value, expected)
+	}
+}
+
+// IsTemplated returns true if the value uses the ${...} syntax
+func IsTemplated(value string) bool {
+	return strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}")
+}
+
+// ParseTemplatedFunction parses a ${...} value into its TemplatedFunction. Values such as ${not:...} may contain either a literal value or another templated value.
+func ParseTemplatedFunction(value string) (TemplatedFunction, error) {
+	if !IsTemplated(value) {
+		return nil, fmt.Errorf(`'%v' is not a templated value`, value)
+	}
+
+	expr := value[2 : len(value)-1]
+	name, arg := expr, ""
+	hasArg := false
+	if i := strings.Index(expr, ":"); i != -1 {
+		name, arg, hasArg = expr[:i], expr[i+1:], true
+	}
+
+	switch name {
+	case "exists":
+		if !hasArg {
+			return Exists, nil
+		}
+	case "missing":
+		if !hasArg {
+			return Missing, nil
+		}
+	case "regex":
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid regex in '%v': %v`, value, err)
+		}
+		return Regex(re), nil
+	case "prefix":
+		return Prefix(arg), nil
+	case "contains":
+		return Substring(arg), nil
+	case "oneOf":
+		options := strings.Split(arg, ",")
+		for i, o := range options {
+			options[i] = strings.TrimSpace(o)
+		}
+		return OneOf(options...), nil
+	case "not":
+		if !IsTemplated(arg) {
+			return Not(OneOf(arg)), nil
+		}
+
+		fn, err := ParseTemplatedFunction(arg)
+		if err != nil {
+			return nil, err
+		}
+		return Not(fn), nil
+	case "gt", "lt":
+		n, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+		if err != nil {
+			return nil, fmt.Errorf(`expected a number in '%v'`, value)
+		}
+
+		if name == "gt" {
+			return GreaterThan(n), nil
+		}
+		return LessThan(n), nil
+	case "equalsIgnoreCase":
+		return EqualsIgnoreCase(arg), nil
+	}
+
+	return nil, fmt.Errorf(`unsupported templated value '%v'`, value)
+}
+
+// NewExpressions picks out the original ${...} syntax for each of the templated values
+func NewExpressions(rawValues map[string]string, templated TemplatedValues) Expressions {
+	e := make(Expressions)
+	for k := range templated {
+		e[k] = rawValues[k]
+	}
+	return e
+}
+
+// Describe returns the original ${...} syntax for the templated value, if it is known
+func (e Expressions) Describe(key string) string {
+	if expr, ok := e[key]; ok {
+		return expr
+	}
+	return "templated value"
+}
+
+// ParseRequestValues separates the templated values from the values which must match exactly
+func ParseRequestValues(rawValues map[string]string) (TemplatedValues, map[string]string, error) {
 	t := make(TemplatedValues)
 	remainder := make(map[string]string)
 
 	for k, v := range rawValues {
-		switch v {
-		case "${exists}":
-			t[textproto.CanonicalMIMEHeaderKey(k)] = Exists
-		case "${missing}":
-			t[textproto.CanonicalMIMEHeaderKey(k)] = Missing
-		default:
+		if !IsTemplated(v) {
 			remainder[k] = v
+			continue
+		}
+
+		fn, err := ParseTemplatedFunction(v)
+		if err != nil {
+			return nil, nil, err
 		}
+		t[k] = fn
 	}
-	return t, remainder
+	return t, remainder, nil
 }
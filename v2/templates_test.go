@@ -0,0 +1,69 @@
+package v2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplatedFunction(t *testing.T) {
+	tests := []struct {
+		template string
+		matches  []string
+		rejects  []string
+	}{
+		{"${exists}", []string{"anything"}, []string{""}},
+		{"${missing}", []string{""}, []string{"anything"}},
+		{"${regex:^Bearer [a-z0-9]+$}", []string{"Bearer abc123"}, []string{"Basic abc123", ""}},
+		{"${regex:^a{2}$}", []string{"aa"}, []string{"a"}},
+		{"${prefix:tid_}", []string{"tid_1234"}, []string{"1234"}},
+		{"${contains:ersatz}", []string{"hello ersatz!"}, []string{"hello"}},
+		{"${oneOf:a, b,c}", []string{"a", "b", "c"}, []string{"d", ""}},
+		{"${not:value}", []string{"other", ""}, []string{"value"}},
+		{"${not:${exists}}", []string{""}, []string{"value"}},
+		{"${not:${oneOf:a,b}}", []string{"c"}, []string{"a"}},
+		{"${gt:10}", []string{"11", "10.5"}, []string{"10", "9", "ten", ""}},
+		{"${lt:-1.5}", []string{"-2"}, []string{"-1.5", "0"}},
+		{"${equalsIgnoreCase:Application/JSON}", []string{"application/json"}, []string{"application/xml"}},
+	}
+
+	for _, test := range tests {
+		fn, err := ParseTemplatedFunction(test.template)
+		require.NoError(t, err, test.template)
+
+		for _, v := range test.matches {
+			assert.True(t, fn(v), "expected %v to match %v", test.template, v)
+		}
+
+		for _, v := range test.rejects {
+			assert.False(t, fn(v), "expected %v not to match %v", test.template, v)
+		}
+	}
+}
+
+func TestParseTemplatedFunction__Errors(t *testing.T) {
+	for _, template := range []string{"${regex:[}", "${gt:ten}", "${lt:}", "${unknown}", "${exists:value}", "${not:${unknown}}", "not templated"} {
+		_, err := ParseTemplatedFunction(template)
+		assert.Error(t, err, template)
+	}
+}
+
+func TestParseRequestValues(t *testing.T) {
+	templated, remainder, err := ParseRequestValues(map[string]string{
+		"x-exact":   "value",
+		"x-prefix":  "${prefix:tid_}",
+		"x-missing": "${missing}",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"x-exact": "value"}, remainder)
+	assert.Len(t, templated, 2)
+	assert.Contains(t, templated, "x-prefix")
+	assert.Contains(t, templated, "x-missing")
+}
+
+func TestParseRequestValues__Error(t *testing.T) {
+	_, _, err := ParseRequestValues(map[string]string{"x-regex": "${regex:(}"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,160 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/peteclark-ft/ersatz/v2"
+)
+
+var ErrNoPayload = errors.New("expected a message to have exactly one of text, json or binary")
+
+// Fixtures maps each WebSocket path (which may have :name or {name} segments) to the script played to every connection
+type Fixtures map[string]Script
+
+// Script is played to each connection: the OnConnect messages are sent as soon as it is accepted, Replies respond to the messages received, Periodic messages are sent until it closes, and Close closes it
+type Script struct {
+	// Subprotocol is accepted if the client offers it, i.e. graphql-ws
+	Subprotocol string `json:"subprotocol"`
+	// Headers are added to the handshake response
+	Headers   map[string]string `json:"headers"`
+	OnConnect []Message         `json:"onConnect"`
+	Replies   []Reply           `json:"replies"`
+	Periodic  []Periodic        `json:"periodic"`
+	Close     *Close            `json:"close"`
+}
+
+// Message is a text, JSON or binary (base64 encoded) message, which is sent after its delay
+type Message struct {
+	Text   string      `json:"text"`
+	JSON   interface{} `json:"json"`
+	Binary string      `json:"binary"`
+	Delay  *v2.Delay   `json:"delay"`
+
+	opcode  byte
+	payload []byte
+}
+
+// Reply sends messages (and optionally closes the connection) in response to each received message which satisfies its when. Only the first reply a message satisfies is used.
+type Reply struct {
+	When  When      `json:"when"`
+	Send  []Message `json:"send"`
+	Close *Close    `json:"close"`
+}
+
+// When matches received messages using the same text and JSON matchers as body discriminators. A reply without any matchers replies to every message.
+type When struct {
+	JSON v2.JSONFields `json:"json"`
+	Text v2.Text       `json:"text"`
+}
+
+// Periodic sends its message every interval, Count times or until the connection closes if Count is 0
+type Periodic struct {
+	Message
+	Every v2.Duration `json:"every"`
+	Count int         `json:"count"`
+}
+
+// Close closes the connection with the code and reason, after waiting for After
+type Close struct {
+	Code   int         `json:"code"`
+	Reason string      `json:"reason"`
+	After  v2.Duration `json:"after"`
+}
+
+// UnmarshalJSON encodes the payload of the message, keeping the numbers in a JSON message as they are written
+func (m *Message) UnmarshalJSON(d []byte) error {
+	type plain Message
+	aux := struct {
+		*plain
+		JSON json.RawMessage `json:"json"`
+	}{plain: (*plain)(m)}
+
+	if err := json.Unmarshal(d, &aux); err != nil {
+		return err
+	}
+
+	payloads := 0
+	m.opcode, m.payload = opText, []byte(m.Text)
+	if m.Text != "" {
+		payloads++
+	}
+
+	m.JSON = nil
+	if len(aux.JSON) > 0 && string(aux.JSON) != "null" {
+		payloads++
+		decoder := json.NewDecoder(bytes.NewReader(aux.JSON))
+		decoder.UseNumber()
+		if err := decoder.Decode(&m.JSON); err != nil {
+			return err
+		}
+
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, aux.JSON); err != nil {
+			return err
+		}
+		m.payload = compact.Bytes()
+	}
+
+	if m.Binary != "" {
+		payloads++
+		binary, err := base64.StdEncoding.DecodeString(m.Binary)
+		if err != nil {
+			return fmt.Errorf("expected binary to be base64 encoded: %v", err)
+		}
+		m.opcode, m.payload = opBinary, binary
+	}
+
+	if payloads != 1 {
+		return ErrNoPayload
+	}
+	return nil
+}
+
+// UnmarshalJSON defaults the code to 1000 (normal closure), and rejects codes which cannot be sent in a close frame
+func (c *Close) UnmarshalJSON(d []byte) error {
+	type plain Close
+	if err := json.Unmarshal(d, (*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Code == 0 {
+		c.Code = CloseNormal
+	}
+
+	if c.Code < 1000 || c.Code > 4999 || c.Code == 1004 || c.Code == 1005 || c.Code == 1006 || c.Code == 1015 {
+		return fmt.Errorf("invalid close code %v, expected a code such as 1000 (normal closure), 1001 (going away), 1011 (internal error) or 4000-4999", c.Code)
+	}
+
+	if len(c.Reason) > maxControlPayload-2 {
+		return fmt.Errorf("the close reason must be at most %v bytes", maxControlPayload-2)
+	}
+	return nil
+}
+
+// UnmarshalJSON requires an interval, so messages are not sent in a busy loop
+func (p *Periodic) UnmarshalJSON(d []byte) error {
+	aux := struct {
+		Every v2.Duration `json:"every"`
+		Count int         `json:"count"`
+	}{}
+
+	if err := json.Unmarshal(d, &aux); err != nil {
+		return err
+	}
+
+	if aux.Every <= 0 {
+		return fmt.Errorf("expected every to be the interval between periodic messages, i.e. 5s")
+	}
+
+	p.Every, p.Count = aux.Every, aux.Count
+	return json.Unmarshal(d, &p.Message)
+}
+
+// matches returns true if the received message satisfies every matcher
+func (w When) matches(payload []byte) bool {
+	return len(w.JSON.Diagnose(payload)) == 0 && len(w.Text.Diagnose(payload)) == 0
+}
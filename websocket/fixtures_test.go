@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage__Payloads(t *testing.T) {
+	m := Message{}
+	require.NoError(t, yaml.Unmarshal([]byte(`text: hello`), &m))
+	assert.Equal(t, opText, m.opcode)
+	assert.Equal(t, "hello", string(m.payload))
+
+	m = Message{}
+	require.NoError(t, yaml.Unmarshal([]byte(`json: {id: 9007199254740993, tags: [a, b]}`), &m))
+	assert.Equal(t, opText, m.opcode)
+	assert.Equal(t, `{"id":9007199254740993,"tags":["a","b"]}`, string(m.payload), "large numbers should be sent as they are written")
+
+	m = Message{}
+	require.NoError(t, yaml.Unmarshal([]byte("binary: AQID\ndelay: 10ms"), &m))
+	assert.Equal(t, opBinary, m.opcode)
+	assert.Equal(t, []byte{1, 2, 3}, m.payload)
+	assert.Equal(t, 10*time.Millisecond, m.Delay.Duration())
+}
+
+func TestMessage__InvalidPayloads(t *testing.T) {
+	m := Message{}
+	assert.Equal(t, ErrNoPayload, json.Unmarshal([]byte(`{"delay":"10ms"}`), &m))
+	assert.Equal(t, ErrNoPayload, json.Unmarshal([]byte(`{"text":"hello","binary":"AQID"}`), &m))
+	assert.EqualError(t, json.Unmarshal([]byte(`{"binary":"not base64!"}`), &m), "expected binary to be base64 encoded: illegal base64 data at input byte 3")
+}
+
+func TestClose__Codes(t *testing.T) {
+	c := Close{}
+	require.NoError(t, yaml.Unmarshal([]byte(`reason: done`), &c))
+	assert.Equal(t, CloseNormal, c.Code)
+
+	require.NoError(t, yaml.Unmarshal([]byte("code: 4001\nafter: 1s"), &c))
+	assert.Equal(t, 4001, c.Code)
+	assert.Equal(t, v2.Duration(time.Second), c.After)
+
+	for _, code := range []string{"999", "1005", "1006", "1015", "5000"} {
+		assert.Error(t, yaml.Unmarshal([]byte("code: "+code), &c), code)
+	}
+}
+
+func TestClose__LongReason(t *testing.T) {
+	c := Close{}
+	assert.EqualError(t, json.Unmarshal([]byte(`{"reason":"`+strings.Repeat("a", 124)+`"}`), &c), "the close reason must be at most 123 bytes")
+}
+
+func TestPeriodic__RequiresEvery(t *testing.T) {
+	p := Periodic{}
+	require.NoError(t, yaml.Unmarshal([]byte("every: 5s\ncount: 2\ntext: beat"), &p))
+	assert.Equal(t, v2.Duration(5*time.Second), p.Every)
+	assert.Equal(t, 2, p.Count)
+	assert.Equal(t, "beat", string(p.payload))
+
+	assert.Error(t, yaml.Unmarshal([]byte(`text: beat`), &p))
+}
+
+func TestWhen__Matches(t *testing.T) {
+	w := When{}
+	assert.True(t, w.matches([]byte("anything")), "a reply without matchers should reply to every message")
+
+	require.NoError(t, yaml.Unmarshal([]byte("json:\n  $.type: subscribe\ntext:\n  contains: markets"), &w))
+	assert.True(t, w.matches([]byte(`{"type":"subscribe","topic":"markets"}`)))
+	assert.False(t, w.matches([]byte(`{"type":"subscribe","topic":"companies"}`)))
+	assert.False(t, w.matches([]byte(`not json, but markets`)))
+}
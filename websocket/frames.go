@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The opcodes of RFC 6455 frames
+const (
+	opContinuation byte = 0x0
+	opText         byte = 0x1
+	opBinary       byte = 0x2
+	opClose        byte = 0x8
+	opPing         byte = 0x9
+	opPong         byte = 0xa
+)
+
+// The close codes ersatz sends when the client breaks the protocol
+const (
+	CloseNormal          = 1000
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	CloseMessageTooLarge = 1009
+)
+
+// maxMessageSize is the largest message ersatz accepts from a client
+const maxMessageSize = 1 << 20
+
+// maxControlPayload is the largest payload of a close, ping or pong frame
+const maxControlPayload = 125
+
+// acceptGUID is appended to the client's key to prove the server understands WebSockets
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var ErrUnmasked = errors.New("client frames must be masked")
+
+var ErrMessageTooLarge = errors.New("the message is larger than 1MB")
+
+var ErrFragmentedControl = errors.New("control frames must not be fragmented")
+
+// IsWebSocket returns true if the request is a WebSocket opening handshake
+func IsWebSocket(r *http.Request) bool {
+	return r.Method == "GET" && headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func headerContains(h http.Header, name string, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acceptKey returns the Sec-WebSocket-Accept of the client's Sec-WebSocket-Key
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// frame is a single WebSocket frame, whose payload has been unmasked
+type frame struct {
+	fin     bool
+	opcode  byte
+	payload []byte
+}
+
+func (f frame) isControl() bool {
+	return f.opcode&0x8 != 0
+}
+
+// readFrame reads a frame sent by a client, which must be masked
+func readFrame(r *bufio.Reader) (frame, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return frame{}, err
+	}
+
+	f := frame{fin: header[0]&0x80 != 0, opcode: header[0] & 0x0f}
+	if header[1]&0x80 == 0 {
+		return f, ErrUnmasked
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return f, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(r, ext); err != nil {
+			return f, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if f.isControl() && (!f.fin || length > maxControlPayload) {
+		return f, ErrFragmentedControl
+	}
+
+	if length > maxMessageSize {
+		return f, ErrMessageTooLarge
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(r, mask); err != nil {
+		return f, err
+	}
+
+	f.payload = make([]byte, length)
+	if _, err := io.ReadFull(r, f.payload); err != nil {
+		return f, err
+	}
+
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+	return f, nil
+}
+
+// appendFrame appends a single, unmasked frame sent by the server
+func appendFrame(b []byte, opcode byte, payload []byte) []byte {
+	b = append(b, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		b = append(b, byte(n))
+	case n <= 0xffff:
+		b = append(b, 126, byte(n>>8), byte(n))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(n))
+		b = append(append(b, 127), ext...)
+	}
+	return append(b, payload...)
+}
+
+// closePayload encodes the code and reason of a close frame
+func closePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+// parseClose decodes the code and reason of a close frame. A close frame without a payload has no code.
+func parseClose(payload []byte) (int, string) {
+	if len(payload) < 2 {
+		return 0, ""
+	}
+	return int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptKey(t *testing.T) {
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func TestReadFrame(t *testing.T) {
+	// the masked "Hello" from RFC 6455
+	f, err := readFrame(bufio.NewReader(bytes.NewReader([]byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58})))
+	require.NoError(t, err)
+	assert.Equal(t, frame{fin: true, opcode: opText, payload: []byte("Hello")}, f)
+}
+
+func TestReadFrame__ExtendedLength(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 300)
+	// a zero mask leaves the payload unchanged
+	b := []byte{0x82, 0x80 | 126, 0x01, 0x2c, 0, 0, 0, 0}
+
+	f, err := readFrame(bufio.NewReader(bytes.NewReader(append(b, payload...))))
+	require.NoError(t, err)
+	assert.Equal(t, opBinary, f.opcode)
+	assert.Equal(t, payload, f.payload)
+}
+
+func TestReadFrame__Unmasked(t *testing.T) {
+	_, err := readFrame(bufio.NewReader(bytes.NewReader(appendFrame(nil, opText, []byte("Hello")))))
+	assert.Equal(t, ErrUnmasked, err)
+}
+
+func TestReadFrame__FragmentedControl(t *testing.T) {
+	_, err := readFrame(bufio.NewReader(bytes.NewReader([]byte{0x09, 0x80, 0, 0, 0, 0})))
+	assert.Equal(t, ErrFragmentedControl, err)
+}
+
+func TestReadFrame__TooLarge(t *testing.T) {
+	_, err := readFrame(bufio.NewReader(bytes.NewReader([]byte{0x82, 0x80 | 127, 0, 0, 0, 0, 0, 0x20, 0, 0})))
+	assert.Equal(t, ErrMessageTooLarge, err)
+}
+
+func TestAppendFrame(t *testing.T) {
+	assert.Equal(t, []byte{0x81, 0x05, 'H', 'e', 'l', 'l', 'o'}, appendFrame(nil, opText, []byte("Hello")))
+
+	b := appendFrame(nil, opBinary, bytes.Repeat([]byte("a"), 256))
+	assert.Equal(t, []byte{0x82, 126, 0x01, 0x00}, b[:4])
+	assert.Len(t, b, 260)
+
+	b = appendFrame(nil, opBinary, bytes.Repeat([]byte("a"), 1<<16))
+	assert.Equal(t, []byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0, 0}, b[:10])
+}
+
+func TestCloseFrame(t *testing.T) {
+	code, reason := parseClose(closePayload(4000, "goodbye"))
+	assert.Equal(t, 4000, code)
+	assert.Equal(t, "goodbye", reason)
+
+	code, reason = parseClose([]byte{})
+	assert.Equal(t, 0, code)
+	assert.Empty(t, reason)
+}
+
+func TestHeaderContains(t *testing.T) {
+	h := http.Header{}
+	h.Set("Sec-WebSocket-Protocol", "chat, Notifications.v1")
+
+	assert.True(t, headerContains(h, "Sec-WebSocket-Protocol", "notifications.v1"))
+	assert.False(t, headerContains(h, "Sec-WebSocket-Protocol", "graphql-ws"))
+}
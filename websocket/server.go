@@ -0,0 +1,393 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/peteclark-ft/ersatz/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// closeTimeout is how long ersatz waits for the client to acknowledge a close frame, before closing the connection anyway
+const closeTimeout = time.Second
+
+// Server accepts WebSocket connections on the paths of the fixtures, and plays their scripts
+type Server struct {
+	paths    []string
+	routes   map[string]v2.Route
+	fixtures Fixtures
+}
+
+// NewServer creates a server for the fixtures. A path which is the same as the request path is preferred to one which matches it with parameters.
+func NewServer(f Fixtures) *Server {
+	s := &Server{routes: make(map[string]v2.Route), fixtures: f}
+	for path := range f {
+		s.paths = append(s.paths, path)
+		s.routes[path] = v2.ParseRoute(path)
+	}
+	sort.Strings(s.paths)
+	return s
+}
+
+// Middleware serves the WebSocket handshakes for the paths of the fixtures, and passes every other request to next
+func (s *Server) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsWebSocket(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		path, ok := s.match(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		s.serve(path, w, r)
+	})
+}
+
+func (s *Server) match(r *http.Request) (string, bool) {
+	if _, ok := s.fixtures[r.URL.Path]; ok {
+		return r.URL.Path, true
+	}
+
+	for _, path := range s.paths {
+		if _, ok := s.routes[path].Match(r.URL.Path); ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// serve completes the opening handshake, and plays the script until the connection closes
+func (s *Server) serve(path string, w http.ResponseWriter, r *http.Request) {
+	journal.SetFixture(r, path, r.Method)
+	script := s.fixtures[path]
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "ersatz only supports version 13 of the WebSocket protocol", http.StatusUpgradeRequired)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "the WebSocket handshake must have a Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are only supported over HTTP/1.1", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.WithError(err).Warn("Failed to accept the WebSocket connection")
+		return
+	}
+	defer conn.Close()
+
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if script.Subprotocol != "" && headerContains(r.Header, "Sec-WebSocket-Protocol", script.Subprotocol) {
+		response += "Sec-WebSocket-Protocol: " + script.Subprotocol + "\r\n"
+	}
+	for k, v := range script.Headers {
+		response += http.CanonicalHeaderKey(k) + ": " + v + "\r\n"
+	}
+
+	if _, err := conn.Write([]byte(response + "\r\n")); err != nil {
+		log.WithError(err).Warn("Failed to complete the WebSocket handshake")
+		return
+	}
+	journal.SetStatus(r, http.StatusSwitchingProtocols)
+
+	sess := newSession(conn, rw.Reader)
+	sess.play(script)
+	journal.SetFrames(r, sess.frames)
+}
+
+// session is a single connection playing a script. Writes and the journalled frames are guarded by the mutex.
+type session struct {
+	sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	frames []journal.Frame
+
+	// closing is closed once ersatz has sent a close frame (or the connection has failed), and closed is closed once the client has acknowledged it
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+func newSession(conn net.Conn, reader *bufio.Reader) *session {
+	return &session{conn: conn, reader: reader, closing: make(chan struct{}), closed: make(chan struct{})}
+}
+
+// play sends the messages of the script, and replies to the messages received, until either side closes the connection
+func (s *session) play(script Script) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.read(script.Replies)
+	}()
+
+	for _, p := range script.Periodic {
+		wg.Add(1)
+		go func(p Periodic) {
+			defer wg.Done()
+			s.periodic(p)
+		}(p)
+	}
+
+	for _, msg := range script.OnConnect {
+		if !s.send(msg) {
+			break
+		}
+	}
+
+	if script.Close != nil {
+		s.closeAfter(*script.Close)
+	}
+
+	<-s.closing
+	select {
+	case <-s.closed:
+	case <-time.After(closeTimeout):
+	}
+
+	s.conn.Close()
+	wg.Wait()
+}
+
+// read receives messages until the connection closes, replying to each with the first reply it satisfies
+func (s *session) read(replies []Reply) {
+	defer s.acknowledged()
+
+	var message []byte
+	var opcode byte
+	for {
+		f, err := readFrame(s.reader)
+		switch {
+		case err == ErrUnmasked || err == ErrFragmentedControl:
+			s.close(CloseProtocolError, err.Error())
+			return
+		case err == ErrMessageTooLarge:
+			s.close(CloseMessageTooLarge, err.Error())
+			return
+		case err != nil:
+			s.abort()
+			return
+		}
+
+		switch f.opcode {
+		case opClose:
+			code, reason := parseClose(f.payload)
+			s.received("close", reason, code, nil)
+			s.close(code, "")
+			return
+		case opPing:
+			s.received("ping", string(f.payload), 0, nil)
+			s.write(opPong, f.payload, "pong", string(f.payload), 0)
+			continue
+		case opPong:
+			s.received("pong", string(f.payload), 0, nil)
+			continue
+		case opText, opBinary:
+			if message != nil {
+				s.close(CloseProtocolError, "expected a continuation frame")
+				return
+			}
+			opcode, message = f.opcode, f.payload
+		case opContinuation:
+			if message == nil {
+				s.close(CloseProtocolError, "unexpected continuation frame")
+				return
+			}
+			message = append(message, f.payload...)
+		default:
+			s.close(CloseProtocolError, fmt.Sprintf("unknown opcode %v", f.opcode))
+			return
+		}
+
+		if len(message) > maxMessageSize {
+			s.close(CloseMessageTooLarge, ErrMessageTooLarge.Error())
+			return
+		}
+
+		if !f.fin {
+			continue
+		}
+
+		if opcode == opText && !utf8.Valid(message) {
+			s.close(CloseInvalidPayload, "text messages must be UTF-8")
+			return
+		}
+
+		s.reply(replies, opcode, message)
+		message = nil
+	}
+}
+
+// reply records the received message, and sends the first reply it satisfies
+func (s *session) reply(replies []Reply, opcode byte, message []byte) {
+	typ, data := "text", string(message)
+	if opcode == opBinary {
+		typ, data = "binary", base64.StdEncoding.EncodeToString(message)
+	}
+
+	for i, reply := range replies {
+		if !reply.When.matches(message) {
+			continue
+		}
+
+		index := i
+		s.received(typ, data, 0, &index)
+		for _, msg := range reply.Send {
+			if !s.send(msg) {
+				return
+			}
+		}
+
+		if reply.Close != nil {
+			s.closeAfter(*reply.Close)
+		}
+		return
+	}
+	s.received(typ, data, 0, nil)
+}
+
+// periodic sends the message every interval, until it has been sent Count times or the connection closes
+func (s *session) periodic(p Periodic) {
+	ticker := time.NewTicker(time.Duration(p.Every))
+	defer ticker.Stop()
+
+	for sent := 0; p.Count == 0 || sent < p.Count; sent++ {
+		select {
+		case <-ticker.C:
+		case <-s.closing:
+			return
+		}
+
+		if !s.send(p.Message) {
+			return
+		}
+	}
+}
+
+// send waits for the delay of the message, then sends it. It returns false if the connection is closing.
+func (s *session) send(msg Message) bool {
+	if wait := msg.Delay.Duration(); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+		case <-s.closing:
+			return false
+		}
+	}
+
+	typ, data := "text", string(msg.payload)
+	if msg.opcode == opBinary {
+		typ, data = "binary", msg.Binary
+	}
+	return s.write(msg.opcode, msg.payload, typ, data, 0)
+}
+
+// closeAfter closes the connection with the code and reason once its delay has passed, unless it has already closed
+func (s *session) closeAfter(c Close) {
+	if c.After <= 0 {
+		s.close(c.Code, c.Reason)
+		return
+	}
+
+	go func() {
+		t := time.NewTimer(time.Duration(c.After))
+		defer t.Stop()
+
+		select {
+		case <-t.C:
+			s.close(c.Code, c.Reason)
+		case <-s.closing:
+		}
+	}()
+}
+
+// close sends a close frame, unless one has already been sent. A code of 0 sends a close frame without a payload.
+func (s *session) close(code int, reason string) {
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case <-s.closing:
+		return
+	default:
+	}
+
+	payload := []byte{}
+	if code != 0 {
+		payload = closePayload(code, reason)
+	}
+
+	s.writeLocked(opClose, payload, "close", reason, code)
+	close(s.closing)
+}
+
+// abort stops the session without a closing handshake, i.e. when the client has disconnected
+func (s *session) abort() {
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case <-s.closing:
+	default:
+		close(s.closing)
+	}
+}
+
+// acknowledged is called once nothing more will be read from the client
+func (s *session) acknowledged() {
+	s.abort()
+	close(s.closed)
+}
+
+// write sends a single frame and records it, unless the connection is closing. It returns false if the frame was not sent.
+func (s *session) write(opcode byte, payload []byte, typ string, data string, code int) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	select {
+	case <-s.closing:
+		return false
+	default:
+	}
+	return s.writeLocked(opcode, payload, typ, data, code)
+}
+
+func (s *session) writeLocked(opcode byte, payload []byte, typ string, data string, code int) bool {
+	s.conn.SetWriteDeadline(time.Now().Add(closeTimeout))
+	if _, err := s.conn.Write(appendFrame(nil, opcode, payload)); err != nil {
+		log.WithError(err).Warn("Failed to write to the WebSocket connection")
+		return false
+	}
+
+	s.frames = append(s.frames, journal.Frame{Time: time.Now(), Direction: "sent", Type: typ, Data: data, Code: code})
+	return true
+}
+
+// received records a frame received from the client, along with the index of the reply it matched
+func (s *session) received(typ string, data string, code int, reply *int) {
+	s.Lock()
+	defer s.Unlock()
+	s.frames = append(s.frames, journal.Frame{Time: time.Now(), Direction: "received", Type: typ, Data: data, Code: code, Reply: reply})
+}
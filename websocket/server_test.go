@@ -0,0 +1,353 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/peteclark-ft/ersatz/journal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const websocketFixturesTestYAML = `
+/notifications/{user}:
+  subprotocol: notifications.v1
+  headers:
+    x-request-id: tid_testing
+  onConnect:
+    - json:
+        type: welcome
+        count: 2
+  replies:
+    - when:
+        json:
+          $.type: subscribe
+      send:
+        - json: {type: subscribed}
+        - text: ready
+    - when:
+        text:
+          regex: ^bye
+      close:
+        code: 4000
+        reason: goodbye
+    - when:
+        text:
+          equals: binary
+      send:
+        - binary: AQID
+/heartbeat:
+  periodic:
+    - every: 10ms
+      count: 3
+      text: beat
+/closing:
+  onConnect:
+    - text: closing soon
+  close:
+    code: 1001
+    reason: going away
+`
+
+// client is a minimal WebSocket client, which sends masked frames
+type client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *journal.Journal) {
+	f := Fixtures{}
+	require.NoError(t, yaml.Unmarshal([]byte(websocketFixturesTestYAML), &f))
+
+	j := journal.New(10)
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return httptest.NewServer(j.Record(NewServer(f).Middleware(notFound))), j
+}
+
+// dial sends the opening handshake, and returns the client along with the handshake response
+func dial(t *testing.T, srv *httptest.Server, path string, headers map[string]string) (*client, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	req := "GET " + path + " HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n"
+	for k, v := range headers {
+		req += k + ": " + v + "\r\n"
+	}
+	_, err = conn.Write([]byte(req + "\r\n"))
+	require.NoError(t, err)
+
+	c := &client{conn: conn, reader: bufio.NewReader(conn)}
+	res, err := http.ReadResponse(c.reader, nil)
+	require.NoError(t, err)
+	return c, res
+}
+
+func (c *client) write(t *testing.T, fin bool, opcode byte, payload []byte) {
+	header := []byte{opcode, 0x80 | byte(len(payload))}
+	if fin {
+		header[0] |= 0x80
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+
+	_, err := c.conn.Write(append(append(header, mask...), masked...))
+	require.NoError(t, err)
+}
+
+func (c *client) send(t *testing.T, text string) {
+	c.write(t, true, opText, []byte(text))
+}
+
+// read reads an unmasked frame sent by the server
+func (c *client) read(t *testing.T) (byte, []byte) {
+	header := make([]byte, 2)
+	_, err := c.reader.Read(header[:1])
+	require.NoError(t, err)
+	header[1], err = c.reader.ReadByte()
+	require.NoError(t, err)
+	require.Equal(t, byte(0), header[1]&0x80, "server frames must not be masked")
+
+	length := int(header[1] & 0x7f)
+	if length == 126 {
+		ext := make([]byte, 2)
+		_, err := c.reader.Read(ext)
+		require.NoError(t, err)
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+
+	payload := make([]byte, length)
+	for read := 0; read < length; {
+		n, err := c.reader.Read(payload[read:])
+		require.NoError(t, err)
+		read += n
+	}
+	return header[0] & 0x0f, payload
+}
+
+func (c *client) expect(t *testing.T, opcode byte, payload string) {
+	actualOpcode, actual := c.read(t)
+	assert.Equal(t, opcode, actualOpcode)
+	assert.Equal(t, payload, string(actual))
+}
+
+func (c *client) close(t *testing.T, code int) {
+	c.write(t, true, opClose, closePayload(code, "done"))
+	opcode, payload := c.read(t)
+	assert.Equal(t, opClose, opcode)
+
+	actual, _ := parseClose(payload)
+	assert.Equal(t, code, actual)
+	c.conn.Close()
+}
+
+// entries waits for the connection to be journalled once it has closed
+func entries(t *testing.T, j *journal.Journal) []journal.Entry {
+	for i := 0; i < 100; i++ {
+		if e := j.Entries(journal.Filter{}); len(e) > 0 {
+			return e
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Fail(t, "the connection was not journalled")
+	return nil
+}
+
+func TestServer__Replies(t *testing.T) {
+	srv, j := newTestServer(t)
+	defer srv.Close()
+
+	c, res := dial(t, srv, "/notifications/1234", map[string]string{"Sec-WebSocket-Protocol": "chat, notifications.v1"})
+	assert.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-WebSocket-Accept"))
+	assert.Equal(t, "notifications.v1", res.Header.Get("Sec-WebSocket-Protocol"))
+	assert.Equal(t, "tid_testing", res.Header.Get("X-Request-Id"))
+
+	c.expect(t, opText, `{"count":2,"type":"welcome"}`)
+
+	c.send(t, `{"type":"subscribe","topic":"markets"}`)
+	c.expect(t, opText, `{"type":"subscribed"}`)
+	c.expect(t, opText, "ready")
+
+	c.send(t, "binary")
+	c.expect(t, opBinary, "\x01\x02\x03")
+
+	c.send(t, "unmatched")
+	c.write(t, true, opPing, []byte("ping"))
+	c.expect(t, opPong, "ping")
+
+	c.close(t, CloseNormal)
+
+	e := entries(t, j)
+	require.Len(t, e, 1)
+	assert.Equal(t, http.StatusSwitchingProtocols, e[0].Status)
+	assert.Equal(t, "/notifications/{user}", e[0].Fixture.Path)
+
+	frames := e[0].Frames
+	require.Len(t, frames, 11)
+	assert.Equal(t, journal.Frame{Direction: "sent", Type: "text", Data: `{"count":2,"type":"welcome"}`}, withoutTime(frames[0]))
+	assert.Equal(t, "received", frames[1].Direction)
+	assert.Equal(t, 0, *frames[1].Reply)
+	assert.Equal(t, journal.Frame{Direction: "sent", Type: "binary", Data: "AQID"}, withoutTime(frames[5]))
+	assert.Equal(t, journal.Frame{Direction: "received", Type: "text", Data: "unmatched"}, withoutTime(frames[6]))
+	assert.Equal(t, journal.Frame{Direction: "received", Type: "ping", Data: "ping"}, withoutTime(frames[7]))
+	assert.Equal(t, journal.Frame{Direction: "received", Type: "close", Data: "done", Code: CloseNormal}, withoutTime(frames[9]))
+	assert.Equal(t, journal.Frame{Direction: "sent", Type: "close", Code: CloseNormal}, withoutTime(frames[10]))
+}
+
+func withoutTime(f journal.Frame) journal.Frame {
+	f.Time = time.Time{}
+	return f
+}
+
+func TestServer__ReplyCloses(t *testing.T) {
+	srv, j := newTestServer(t)
+	defer srv.Close()
+
+	c, res := dial(t, srv, "/notifications/1234", nil)
+	assert.Empty(t, res.Header.Get("Sec-WebSocket-Protocol"), "the subprotocol is only accepted if the client offers it")
+	c.expect(t, opText, `{"count":2,"type":"welcome"}`)
+
+	c.send(t, "bye for now")
+	opcode, payload := c.read(t)
+	assert.Equal(t, opClose, opcode)
+
+	code, reason := parseClose(payload)
+	assert.Equal(t, 4000, code)
+	assert.Equal(t, "goodbye", reason)
+
+	c.write(t, true, opClose, payload)
+	frames := entries(t, j)[0].Frames
+	assert.Equal(t, journal.Frame{Direction: "sent", Type: "close", Data: "goodbye", Code: 4000}, withoutTime(frames[2]))
+	assert.Equal(t, journal.Frame{Direction: "received", Type: "close", Data: "goodbye", Code: 4000}, withoutTime(frames[3]))
+}
+
+func TestServer__Periodic(t *testing.T) {
+	srv, _ := newTestServer(t)
+	defer srv.Close()
+
+	c, _ := dial(t, srv, "/heartbeat", nil)
+	for i := 0; i < 3; i++ {
+		c.expect(t, opText, "beat")
+	}
+
+	c.send(t, "anything")
+	c.close(t, CloseNormal)
+}
+
+func TestServer__Close(t *testing.T) {
+	srv, j := newTestServer(t)
+	defer srv.Close()
+
+	c, _ := dial(t, srv, "/closing", nil)
+	c.expect(t, opText, "closing soon")
+
+	opcode, payload := c.read(t)
+	assert.Equal(t, opClose, opcode)
+	assert.Equal(t, closePayload(1001, "going away"), payload)
+
+	// the client never acknowledges the close, so the connection is closed after the timeout
+	e := entries(t, j)
+	assert.Len(t, e[0].Frames, 2)
+}
+
+func TestServer__FragmentedMessage(t *testing.T) {
+	srv, _ := newTestServer(t)
+	defer srv.Close()
+
+	c, _ := dial(t, srv, "/notifications/1234", nil)
+	c.expect(t, opText, `{"count":2,"type":"welcome"}`)
+
+	c.write(t, false, opText, []byte("bi"))
+	c.write(t, true, opPing, []byte{})
+	c.expect(t, opPong, "")
+	c.write(t, true, opContinuation, []byte("nary"))
+	c.expect(t, opBinary, "\x01\x02\x03")
+	c.close(t, CloseNormal)
+}
+
+func TestServer__ProtocolErrors(t *testing.T) {
+	srv, _ := newTestServer(t)
+	defer srv.Close()
+
+	c, _ := dial(t, srv, "/notifications/1234", nil)
+	c.expect(t, opText, `{"count":2,"type":"welcome"}`)
+
+	_, err := c.conn.Write([]byte{0x81, 0x01, 'a'})
+	require.NoError(t, err)
+
+	opcode, payload := c.read(t)
+	assert.Equal(t, opClose, opcode)
+	code, reason := parseClose(payload)
+	assert.Equal(t, CloseProtocolError, code)
+	assert.Equal(t, ErrUnmasked.Error(), reason)
+
+	c, _ = dial(t, srv, "/notifications/1234", nil)
+	c.expect(t, opText, `{"count":2,"type":"welcome"}`)
+
+	c.write(t, true, opText, []byte{0xff, 0xfe})
+	_, payload = c.read(t)
+	code, _ = parseClose(payload)
+	assert.Equal(t, CloseInvalidPayload, code)
+}
+
+func TestServer__InvalidHandshake(t *testing.T) {
+	srv, _ := newTestServer(t)
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL+"/notifications/1234", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "8")
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusUpgradeRequired, res.StatusCode)
+	assert.Equal(t, "13", res.Header.Get("Sec-WebSocket-Version"))
+
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestMiddleware__PassesOtherRequests(t *testing.T) {
+	srv, _ := newTestServer(t)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/notifications/1234")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "requests which are not handshakes should be passed on")
+
+	_, res = dial(t, srv, "/missing", nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "handshakes for other paths should be passed on")
+}
+
+func TestIsWebSocket(t *testing.T) {
+	r := httptest.NewRequest("GET", "/notifications", nil)
+	assert.False(t, IsWebSocket(r))
+
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "WebSocket")
+	assert.True(t, IsWebSocket(r))
+
+	r.Method = "POST"
+	assert.False(t, IsWebSocket(r))
+}